@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"stresstest/internal/usecase/run"
+
+	"github.com/spf13/cobra"
+)
+
+// tokenEnv is where the agent, the API and the controller read their token from when it isn't given as a flag
+const tokenEnv = "STRESSTEST_TOKEN"
+
+func newAgentCmd(usecase *run.RunUseCase) *cobra.Command {
+	var listen string
+	var token string
+
+	agentCmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Run your share of a distributed stress test 🤝",
+		Run: func(cmd *cobra.Command, args []string) {
+			if token == "" {
+				token = os.Getenv(tokenEnv)
+			}
+			if token == "" {
+				fmt.Fprintf(os.Stderr, "Erro: o agente precisa de um token, use --token ou a variável %s\n", tokenEnv)
+				os.Exit(1)
+			}
+			agent := run.NewAgent(usecase, token)
+			fmt.Printf("Agente aguardando testes em %s\n", listen)
+			if err := http.ListenAndServe(listen, agent.Handler()); err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	agentCmd.Flags().StringVar(&listen, "listen", "127.0.0.1:7000", "Endereço em que o agente recebe os testes do controlador, use 0.0.0.0:7000 para aceitar outras máquinas")
+	agentCmd.Flags().StringVar(&token, "token", "", "Token que o controlador deve enviar, lido de "+tokenEnv+" quando omitido")
+
+	return agentCmd
+}
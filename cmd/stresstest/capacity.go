@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"stresstest/internal/presenters"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newFindCapacityCmd(usecase *run.RunUseCase) *cobra.Command {
+	var request requestFlags
+	var input run.CapacityInputDTO
+	var cooldown time.Duration
+	var output string
+
+	capacityCmd := &cobra.Command{
+		Use:   "find-capacity",
+		Short: "Find the highest concurrency your service handles within an SLO 📈",
+		Run: func(cmd *cobra.Command, args []string) {
+			var err error
+			input.Run, err = request.input()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+			input.CooldownInMs = int(cooldown.Milliseconds())
+
+			report, err := usecase.FindCapacity(cmd.Context(), input)
+			if len(report.Steps) > 0 {
+				// Exibir dados, mesmo de uma busca interrompida
+				presenters.PrintCapacityReport(report)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Os formatos do --out gravam o relatório de um teste, a busca tem um por nível e só a tabela deles tem json e md
+			if output != "" {
+				base := strings.TrimSuffix(output, ".json")
+				err := presenters.SaveCapacityAsJSON(report, base+".json")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao salvar arquivo JSON: %v\n", err)
+				}
+				err = os.WriteFile(base+".md", []byte(presenters.CapacityToMarkdown(report)), 0644)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao salvar arquivo Markdown: %v\n", err)
+				}
+			}
+		},
+	}
+
+	capacityCmd.Flags().StringArrayVar(&input.SLO, "slo", []string{"p95<500", "error_rate<1%"}, "Limite que cada nível deve respeitar, no formato do --threshold (pode repetir)")
+	capacityCmd.Flags().StringVar(&input.Strategy, "strategy", run.CapacityStrategyStep, "Como os níveis são escolhidos: step (sobe de --step em --step) ou binary (busca binária entre --start e --max)")
+	capacityCmd.Flags().IntVar(&input.Start, "start", 1, "Concorrência do primeiro nível")
+	capacityCmd.Flags().IntVar(&input.Step, "step", 5, "Incremento da concorrência, ou a precisão da busca binária")
+	capacityCmd.Flags().IntVar(&input.Max, "max", 100, "Maior concorrência testada")
+	capacityCmd.Flags().IntVar(&input.RequestsPerVU, "requests-per-vu", 50, "Requests de cada usuário virtual em cada nível")
+	capacityCmd.Flags().IntVar(&input.WarmupRequests, "warmup-requests", 0, "Aquecimento de cada nível, nas mesmas conexões e fora do relatório (HTTP e GraphQL)")
+	capacityCmd.Flags().DurationVar(&cooldown, "cooldown", 5*time.Second, "Pausa entre os níveis, para o serviço se recuperar")
+	capacityCmd.Flags().StringVarP(&output, "output", "o", "", "Nome dos arquivos de saída, gera nome.json e nome.md")
+	request.register(capacityCmd.Flags())
+
+	capacityCmd.MarkFlagsOneRequired("url", "curl")
+
+	return capacityCmd
+}
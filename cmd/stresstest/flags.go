@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"stresstest/internal/curl"
+	"stresstest/internal/sinks"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// authFlags holds the auth flags shared by every command that sends requests
+type authFlags struct {
+	basic        string
+	token        string
+	tokenEnv     string
+	tokenFile    string
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+}
+
+func (a *authFlags) register(flags *pflag.FlagSet) {
+	flags.StringVar(&a.basic, "auth-basic", "", "Basic auth no formato usuario:senha")
+	flags.StringVar(&a.token, "bearer-token", "", "Bearer token enviado em todas as requisições")
+	flags.StringVar(&a.tokenEnv, "bearer-token-env", "", "Variável de ambiente com o bearer token")
+	flags.StringVar(&a.tokenFile, "bearer-token-file", "", "Arquivo com o bearer token")
+	flags.StringVar(&a.tokenURL, "oauth2-token-url", "", "URL de token OAuth2 (client credentials)")
+	flags.StringVar(&a.clientID, "oauth2-client-id", "", "Client ID OAuth2")
+	flags.StringVar(&a.clientSecret, "oauth2-client-secret", "", "Client secret OAuth2")
+	flags.StringSliceVar(&a.scopes, "oauth2-scopes", nil, "Scopes OAuth2 separados por vírgula")
+}
+
+// dto converts the flags into the auth options of a run, the auth type is inferred from the flags used
+func (a *authFlags) dto() run.AuthDTO {
+	switch {
+	case a.tokenURL != "":
+		return run.AuthDTO{Type: "oauth2", TokenURL: a.tokenURL, ClientID: a.clientID, ClientSecret: a.clientSecret, Scopes: a.scopes}
+	case a.token != "" || a.tokenEnv != "" || a.tokenFile != "":
+		return run.AuthDTO{Type: "bearer", Token: a.token, TokenEnv: a.tokenEnv, TokenFile: a.tokenFile}
+	case a.basic != "":
+		username, password, _ := strings.Cut(a.basic, ":")
+		return run.AuthDTO{Type: "basic", Username: username, Password: password}
+	default:
+		return run.AuthDTO{}
+	}
+}
+
+// transportFlags holds the connection flags shared by every command that sends requests
+type transportFlags struct {
+	insecure     bool
+	certFile     string
+	keyFile      string
+	caFile       string
+	serverName   string
+	minVersion   string
+	maxVersion   string
+	cipherSuites []string
+	protocol     string
+}
+
+func (t *transportFlags) register(flags *pflag.FlagSet) {
+	flags.BoolVar(&t.insecure, "insecure", false, "Não verifica o certificado do servidor")
+	flags.StringVar(&t.certFile, "cert", "", "Certificado de cliente (PEM) para mTLS")
+	flags.StringVar(&t.keyFile, "key", "", "Chave privada do certificado de cliente (PEM)")
+	flags.StringVar(&t.caFile, "cacert", "", "Bundle de CAs (PEM) usado no lugar das CAs do sistema")
+	flags.StringVar(&t.serverName, "sni", "", "Sobrescreve o server name (SNI) enviado no handshake")
+	flags.StringVar(&t.minVersion, "tls-min", "", "Versão mínima de TLS: 1.0, 1.1, 1.2 ou 1.3")
+	flags.StringVar(&t.maxVersion, "tls-max", "", "Versão máxima de TLS: 1.0, 1.1, 1.2 ou 1.3")
+	flags.StringSliceVar(&t.cipherSuites, "tls-ciphers", nil, "Cipher suites permitidas, separadas por vírgula (TLS 1.2 ou anterior)")
+	flags.StringVar(&t.protocol, "protocol", "", "Protocolo HTTP: http1, h2, h2c ou h3 (padrão: negociado via ALPN)")
+}
+
+func (t *transportFlags) dto() run.TransportDTO {
+	return run.TransportDTO{
+		Insecure:      t.insecure,
+		CertFile:      t.certFile,
+		KeyFile:       t.keyFile,
+		CAFile:        t.caFile,
+		ServerName:    t.serverName,
+		MinTLSVersion: t.minVersion,
+		MaxTLSVersion: t.maxVersion,
+		CipherSuites:  t.cipherSuites,
+		Protocol:      t.protocol,
+	}
+}
+
+// sinkFlags holds the flags of the metrics sinks that receive the results while the run goes
+type sinkFlags struct {
+	influxURL   string
+	influxToken string
+	statsdAddr  string
+	dogStatsD   bool
+	otlpURL     string
+	otlpHeaders []string
+	sampleRatio float64
+	flush       time.Duration
+	batch       int
+	tags        []string
+}
+
+func (s *sinkFlags) register(flags *pflag.FlagSet) {
+	flags.StringVar(&s.influxURL, "influx-url", "", "Endpoint de escrita do InfluxDB que recebe os resultados durante o teste, ex: http://localhost:8086/api/v2/write?org=acme&bucket=load")
+	flags.StringVar(&s.influxToken, "influx-token", "", "Token enviado ao InfluxDB")
+	flags.StringVar(&s.statsdAddr, "statsd-addr", "", "Endereço UDP do StatsD que recebe os resultados durante o teste, ex: localhost:8125")
+	flags.BoolVar(&s.dogStatsD, "dogstatsd", false, "Envia as tags no formato do DogStatsD")
+	flags.StringVar(&s.otlpURL, "otlp-endpoint", "", "Endpoint OTLP/HTTP do coletor OpenTelemetry que recebe spans e métricas durante o teste, ex: http://localhost:4318")
+	flags.StringArrayVar(&s.otlpHeaders, "otlp-header", nil, "Header enviado ao coletor OTLP, no formato Nome: valor (pode repetir)")
+	flags.Float64Var(&s.sampleRatio, "trace-sample-ratio", 1, "Fração das requisições exportadas como spans com --otlp-endpoint, de 0 a 1")
+	flags.DurationVar(&s.flush, "sink-flush", sinks.DefaultFlushInterval, "Intervalo entre os envios para os sinks")
+	flags.IntVar(&s.batch, "sink-batch", sinks.DefaultBatchSize, "Máximo de medições por envio, um lote cheio é enviado antes do intervalo")
+	flags.StringArrayVar(&s.tags, "sink-tag", nil, "Tag enviada com as medições, no formato chave=valor (pode repetir)")
+}
+
+func (s *sinkFlags) enabled() bool {
+	return s.influxURL != "" || s.statsdAddr != "" || s.otlpURL != ""
+}
+
+// traced tells if the requests carry a traceparent header, which they do when spans are exported
+func (s *sinkFlags) traced() bool {
+	return s.otlpURL != ""
+}
+
+// start starts a pusher for every sink configured, tagging the points with the run id, the URL and the custom tags
+func (s *sinkFlags) start(runID, url, method string) ([]*sinks.Pusher, error) {
+	if s.sampleRatio < 0 || s.sampleRatio > 1 {
+		return nil, fmt.Errorf("--trace-sample-ratio deve estar entre 0 e 1")
+	}
+	otlpHeaders := make(map[string]string)
+	for _, header := range s.otlpHeaders {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("header OTLP inválido %q, use o formato Nome: valor", header)
+		}
+		otlpHeaders[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	tags := map[string]string{"run_id": runID, "url": url}
+	for _, tag := range s.tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("tag inválida %q, use o formato chave=valor", tag)
+		}
+		tags[key] = value
+	}
+	opts := sinks.Options{FlushInterval: s.flush, BatchSize: s.batch, Tags: tags}
+
+	var pushers []*sinks.Pusher
+	if s.influxURL != "" {
+		pushers = append(pushers, sinks.NewPusher(sinks.NewInfluxDB(s.influxURL, s.influxToken), opts))
+	}
+	if s.otlpURL != "" {
+		pushers = append(pushers, sinks.NewPusher(sinks.NewOTLP(s.otlpURL, otlpHeaders, method), opts))
+	}
+	if s.statsdAddr != "" {
+		statsd, err := sinks.NewStatsD(s.statsdAddr, s.dogStatsD)
+		if err != nil {
+			for _, pusher := range pushers {
+				pusher.Close()
+			}
+			return nil, err
+		}
+		pushers = append(pushers, sinks.NewPusher(statsd, opts))
+	}
+	return pushers, nil
+}
+
+// requestFlags holds the flags that describe the requests of a run, shared by the commands that start runs
+type requestFlags struct {
+	url              string
+	curlCommand      string
+	headers          []string
+	auth             authFlags
+	transport        transportFlags
+	session          run.SessionDTO
+	webSocket        run.WebSocketDTO
+	wsMessagesFile   string
+	grpc             run.GRPCDTO
+	grpcTimeout      time.Duration
+	sse              run.SSEDTO
+	sseDuration      time.Duration
+	graphQL          run.GraphQLDTO
+	graphQLQueryFile string
+	connections      int
+	socket           run.SocketDTO
+	socketTimeout    time.Duration
+	thinkTime        string
+	pacing           time.Duration
+	retry            run.RetryDTO
+	retryBaseDelay   time.Duration
+	retryMaxDelay    time.Duration
+}
+
+func (r *requestFlags) register(flags *pflag.FlagSet) {
+	flags.StringVarP(&r.url, "url", "u", "", "URL do serviço a ser testado (obrigatório sem --curl)")
+	flags.StringVar(&r.curlCommand, "curl", "", "Comando curl que define método, headers e body da requisição")
+	flags.StringVar(&r.session.Mode, "session", "", "Sessão dos usuários virtuais: shared (um cookie jar para todos) ou isolated (um por usuário); sem ela os cookies não são guardados")
+	flags.BoolVar(&r.session.PoolPerVU, "pool-per-vu", false, "Cada usuário virtual usa seu próprio pool de conexões (exige --session isolated)")
+	flags.StringArrayVar(&r.webSocket.Messages, "ws-message", nil, "Mensagem enviada pelas conexões WebSocket (pode repetir)")
+	flags.StringVar(&r.wsMessagesFile, "ws-messages-file", "", "Arquivo com as mensagens WebSocket, uma por linha")
+	flags.Float64Var(&r.webSocket.Rate, "ws-rate", 0, "Mensagens por segundo por conexão WebSocket (0 espera cada resposta)")
+	flags.StringArrayVarP(&r.headers, "header", "H", nil, "Header enviado em todas as requisições, no formato Nome: valor (pode repetir, vira metadata no gRPC)")
+	flags.StringVar(&r.grpc.Method, "grpc-method", "", "Método gRPC chamado, no formato pacote.Servico/Metodo")
+	flags.StringVar(&r.grpc.Data, "grpc-data", "", "Mensagem gRPC em JSON, ou um array de mensagens para client streaming")
+	flags.StringArrayVar(&r.grpc.ProtoFiles, "proto", nil, "Arquivo .proto com o serviço (sem ele usa server reflection)")
+	flags.StringArrayVar(&r.grpc.ImportPaths, "import-path", nil, "Diretório usado para resolver os imports dos arquivos .proto")
+	flags.DurationVar(&r.grpcTimeout, "grpc-timeout", 0, "Deadline de cada chamada gRPC, ex: 500ms")
+	flags.BoolVar(&r.sse.Enabled, "sse", false, "Mantém um stream Server-Sent Events aberto por usuário virtual")
+	flags.DurationVar(&r.sseDuration, "sse-duration", 30*time.Second, "Por quanto tempo os streams SSE ficam abertos")
+	flags.StringVar(&r.graphQLQueryFile, "graphql-query", "", "Arquivo com a query GraphQL enviada em cada request")
+	flags.StringVar(&r.graphQL.Variables, "graphql-variables", "", "Variáveis GraphQL em JSON ou @arquivo, renderizadas como template a cada request")
+	flags.StringVar(&r.graphQL.OperationName, "graphql-operation", "", "Operação executada quando a query tem mais de uma")
+	flags.IntVar(&r.connections, "connections", 0, "Número fixo de conexões compartilhadas pelos usuários virtuais (com h2, multiplexa os streams)")
+	flags.StringVar(&r.socket.PayloadHex, "payload-hex", "", "Payload enviado em cada request tcp:// ou udp://, em hex")
+	flags.StringVar(&r.socket.PayloadFile, "payload-file", "", "Arquivo com o payload enviado em cada request tcp:// ou udp://")
+	flags.IntVar(&r.socket.ResponseLength, "response-length", 0, "Tamanho em bytes da resposta esperada em cada request tcp:// ou udp://")
+	flags.StringVar(&r.socket.ResponseDelimiterHex, "response-delimiter", "", "Bytes em hex que terminam a resposta de cada request tcp:// ou udp://, ex: 0d0a")
+	flags.DurationVar(&r.socketTimeout, "socket-timeout", 10*time.Second, "Timeout da conexão e de cada resposta tcp:// ou udp://")
+	flags.StringVar(&r.thinkTime, "think-time", "", "Pausa de cada usuário virtual após cada request: 500ms, uniform:200ms-800ms, normal:500ms,100ms ou exponential:500ms")
+	flags.DurationVar(&r.pacing, "pacing", 0, "Cada usuário virtual inicia uma request a cada intervalo, ex: 2s")
+	flags.IntVar(&r.retry.MaxRetries, "retries", 0, "Máximo de novas tentativas de cada request que recebe um status de --retry-status (0 desliga)")
+	flags.IntSliceVar(&r.retry.Statuses, "retry-status", []int{429, 503}, "Status que fazem a request ser tentada de novo, separados por vírgula (0 para falhas sem resposta)")
+	flags.DurationVar(&r.retryBaseDelay, "retry-base-delay", 100*time.Millisecond, "Espera base do backoff exponencial com jitter, dobrada a cada tentativa")
+	flags.DurationVar(&r.retryMaxDelay, "retry-max-delay", 30*time.Second, "Maior espera entre tentativas, também limita o Retry-After")
+	r.auth.register(flags)
+	r.transport.register(flags)
+}
+
+// input builds the input of a run from the flags, reading the files they point to and the curl command,
+// requests and concurrency are left for the command to set
+func (r *requestFlags) input() (run.RunInputDTO, error) {
+	input := run.RunInputDTO{
+		Url:       r.url,
+		Auth:      r.auth.dto(),
+		Transport: r.transport.dto(),
+		Session:   r.session,
+		WebSocket: r.webSocket,
+		GRPC:      r.grpc,
+		SSE:       r.sse,
+		GraphQL:   r.graphQL,
+		Socket:    r.socket,
+	}
+	input.GRPC.TimeoutInMs = int(r.grpcTimeout.Milliseconds())
+	input.SSE.DurationInMs = int(r.sseDuration.Milliseconds())
+	input.Transport.Connections = r.connections
+	input.Socket.TimeoutInMs = int(r.socketTimeout.Milliseconds())
+	input.PacingInMs = int(r.pacing.Milliseconds())
+	input.Retry = r.retry
+	input.Retry.BaseDelayInMs = int(r.retryBaseDelay.Milliseconds())
+	input.Retry.MaxDelayInMs = int(r.retryMaxDelay.Milliseconds())
+
+	thinkTime, err := run.ParseThinkTime(r.thinkTime)
+	if err != nil {
+		return input, fmt.Errorf("think time %q: %w", r.thinkTime, err)
+	}
+	input.ThinkTime = thinkTime
+
+	// Mensagens WebSocket lidas de arquivo, uma por linha
+	if r.wsMessagesFile != "" {
+		content, err := os.ReadFile(r.wsMessagesFile)
+		if err != nil {
+			return input, fmt.Errorf("falha ao ler mensagens WebSocket: %w", err)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				input.WebSocket.Messages = append(input.WebSocket.Messages, line)
+			}
+		}
+	}
+
+	// Query GraphQL e variáveis lidas de arquivo
+	if r.graphQLQueryFile != "" {
+		content, err := os.ReadFile(r.graphQLQueryFile)
+		if err != nil {
+			return input, fmt.Errorf("falha ao ler query GraphQL: %w", err)
+		}
+		input.GraphQL.Query = string(content)
+	}
+	if path, ok := strings.CutPrefix(input.GraphQL.Variables, "@"); ok {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return input, fmt.Errorf("falha ao ler variáveis GraphQL: %w", err)
+		}
+		input.GraphQL.Variables = string(content)
+	}
+
+	// Requisição importada de um comando curl
+	if r.curlCommand != "" {
+		parsed, err := curl.Parse(r.curlCommand)
+		if err != nil {
+			return input, fmt.Errorf("falha ao interpretar comando curl: %w", err)
+		}
+		for _, warning := range parsed.Warnings {
+			fmt.Fprintf(os.Stderr, "Aviso (curl): %s\n", warning)
+		}
+		if input.Url == "" {
+			input.Url = parsed.Url
+		}
+		input.Method = parsed.Method
+		input.Headers = parsed.Headers
+		input.Body = parsed.Body
+		input.Transport.Insecure = input.Transport.Insecure || parsed.Insecure
+		input.Transport.DisableCompression = !parsed.Compressed
+	}
+
+	// Headers da flag têm prioridade sobre os do curl, com o nome canônico para substituir o mesmo header
+	for _, header := range r.headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return input, fmt.Errorf("header inválido %q, use o formato Nome: valor", header)
+		}
+		if input.Headers == nil {
+			input.Headers = make(map[string]string)
+		}
+		input.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return input, nil
+}
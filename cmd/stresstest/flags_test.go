@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HeaderFlagsMustOverrideCurlHeadersWhateverTheirCase(t *testing.T) {
+	// Arrange
+	var request requestFlags
+	flags := pflag.NewFlagSet("run", pflag.ContinueOnError)
+	request.register(flags)
+	err := flags.Parse([]string{
+		"--curl", `curl http://example.com -H 'Authorization: Bearer from-curl'`,
+		"-H", "authorization: Bearer from-flag",
+		"-H", "host: api.example.com",
+	})
+	assert.NoError(t, err)
+
+	// Act
+	input, err := request.input()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer from-flag", "Host": "api.example.com"}, input.Headers)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"stresstest/internal/presenters"
+	"stresstest/internal/usecase/run"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newReplayCmd(usecase *run.RunUseCase) *cobra.Command {
+	var input run.ReplayInputDTO
+	var output string
+	var authOpts authFlags
+	var transportOpts transportFlags
+
+	replayCmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Replay an access log against another host 🔁",
+		Run: func(cmd *cobra.Command, args []string) {
+			input.Auth = authOpts.dto()
+			input.Transport = transportOpts.dto()
+			report, err := usecase.Replay(cmd.Context(), input)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+
+			// Exibir dados
+			presenters.PrintReport(report.RunOutputDTO)
+			presenters.PrintReplayComparison(report)
+
+			if output != "" {
+				base := strings.TrimSuffix(output, ".json")
+				err := presenters.SaveReplayAsJSON(report, base+".json")
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao salvar arquivo JSON: %v\n", err)
+				}
+				err = os.WriteFile(base+".md", []byte(presenters.ReplayToMarkdown(report)), 0644)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao salvar arquivo Markdown: %v\n", err)
+				}
+			}
+		},
+	}
+
+	replayCmd.Flags().StringVarP(&input.LogFile, "log", "l", "", "Arquivo de access log (nginx/Apache combined ou JSON)")
+	replayCmd.Flags().StringVar(&input.Format, "format", "auto", "Formato do log: auto, combined ou json")
+	replayCmd.Flags().StringVarP(&input.Target, "target", "t", "", "Host que recebe o replay, ex: https://staging.example.com")
+	replayCmd.Flags().IntVarP(&input.Concurrency, "concurrency", "c", 1, "Número de chamadas simultâneas")
+	replayCmd.Flags().BoolVar(&input.KeepTiming, "keep-timing", false, "Mantém o intervalo original entre as requisições do log")
+	replayCmd.Flags().Float64Var(&input.Speed, "speed", 1, "Fator de aceleração do intervalo original (com --keep-timing)")
+	replayCmd.Flags().BoolVarP(&input.ShowData, "showdata", "s", false, "Exibir dados de cada request")
+	replayCmd.Flags().StringVarP(&output, "output", "o", "", "Nome dos arquivos de saída, gera nome.json e nome.md")
+	authOpts.register(replayCmd.Flags())
+	transportOpts.register(replayCmd.Flags())
+
+	replayCmd.MarkFlagRequired("log")
+	replayCmd.MarkFlagRequired("target")
+
+	return replayCmd
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"stresstest/internal/metrics"
+	"stresstest/internal/presenters"
+	"stresstest/internal/samples"
+	"stresstest/internal/sinks"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// Formats of the file written with --output
+const (
+	outputJSON  = "json"
+	outputJUnit = "junit"
+)
+
+func newRunCmd(usecase *run.RunUseCase, use string) *cobra.Command {
+	var request requestFlags
+	var requests int
+	var concurrency int
+	var showData bool
+	var output string
+	var warmup string
+	var expectedInterval time.Duration
+	var agents []string
+	var agentToken string
+	var metricsAddr string
+	var sinkOpts sinkFlags
+	var thresholdExpressions []string
+	var outs []string
+	var outputFormat string
+	var samplesPath string
+
+	runCmd := &cobra.Command{
+		Use:   use,
+		Short: "Stress test your services like a pro 💪",
+		Run: func(cmd *cobra.Command, args []string) {
+			// Aqui você chama sua função principal
+
+			input, err := request.input()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+			input.Requests = requests
+			input.Concurrency = concurrency
+			input.ShowData = showData
+			// O intervalo vai em ms para o teste, valores menores virariam zero
+			if expectedInterval != 0 && expectedInterval < time.Millisecond {
+				fmt.Fprintf(os.Stderr, "Erro: o --expected-interval precisa ser de pelo menos 1ms, recebido %s\n", expectedInterval)
+				os.Exit(1)
+			}
+			input.ExpectedIntervalInMs = int(expectedInterval.Milliseconds())
+			input.Warmup, err = run.ParseWarmup(warmup)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro no aquecimento %q: %v\n", warmup, err)
+				os.Exit(1)
+			}
+
+			// Agentes só aceitam testes com o token deles
+			if len(agents) > 0 {
+				if agentToken == "" {
+					agentToken = os.Getenv(tokenEnv)
+				}
+				if agentToken == "" {
+					fmt.Fprintf(os.Stderr, "Erro: os agentes precisam de um token, use --agent-token ou a variável %s\n", tokenEnv)
+					os.Exit(1)
+				}
+			}
+
+			// Thresholds lidos antes do teste, para falhar cedo
+			var thresholds []run.Threshold
+			for _, expression := range thresholdExpressions {
+				threshold, err := run.ParseThreshold(expression)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro no threshold %q: %v\n", expression, err)
+					os.Exit(1)
+				}
+				thresholds = append(thresholds, threshold)
+			}
+
+			// Arquivos de saída, o --output é um atalho para json e md, ou junit com --output-format junit
+			switch {
+			case outputFormat != outputJSON && outputFormat != outputJUnit:
+				fmt.Fprintf(os.Stderr, "Erro: formato de saída %q inválido, use json ou junit\n", outputFormat)
+				os.Exit(1)
+			case output == "":
+			case outputFormat == outputJUnit:
+				outs = append([]string{"junit=" + strings.TrimSuffix(output, ".xml") + ".xml"}, outs...)
+			default:
+				base := strings.TrimSuffix(output, ".json")
+				outs = append([]string{"json=" + base + ".json", "md=" + base + ".md"}, outs...)
+			}
+			// O --samples é um atalho para csv ou ndjson, conforme a extensão
+			if samplesPath != "" {
+				format, err := samples.FormatOf(samplesPath)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro no arquivo de amostras %q: %v\n", samplesPath, err)
+					os.Exit(1)
+				}
+				outs = append(outs, format+"="+samplesPath)
+			}
+			var outputs []presenters.Output
+			for _, spec := range outs {
+				out, err := presenters.ParseOutput(spec)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro na saída %q: %v\n", spec, err)
+					os.Exit(1)
+				}
+				outputs = append(outputs, out)
+			}
+
+			// Os agentes só enviam resumos, nada chegaria às métricas, aos sinks nem às saídas gravadas durante o teste
+			if len(agents) > 0 {
+				streamed := metricsAddr != "" || sinkOpts.enabled()
+				for _, out := range outputs {
+					streamed = streamed || out.Format.Stream != nil
+				}
+				if streamed {
+					fmt.Fprintln(os.Stderr, "Erro: --metrics-addr, os sinks e as saídas csv e ndjson não funcionam com --agents")
+					os.Exit(1)
+				}
+			}
+
+			ctx := cmd.Context()
+
+			// Métricas Prometheus expostas enquanto o teste roda
+			if metricsAddr != "" {
+				prometheus := metrics.NewPrometheus()
+				ctx = run.WithObserver(ctx, prometheus.Observer())
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", prometheus)
+				go func() {
+					if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+						fmt.Fprintf(os.Stderr, "Erro ao expor métricas: %v\n", err)
+					}
+				}()
+			}
+
+			// Resultados enviados aos sinks enquanto o teste roda
+			var pushers []*sinks.Pusher
+			if sinkOpts.enabled() {
+				if input.Id == "" {
+					input.Id = uuid.New().String()
+				}
+				var err error
+				// Names the spans of the requests
+				method := input.Method
+				switch {
+				case input.GraphQL.Query != "":
+					method = http.MethodPost
+				case method == "":
+					method = http.MethodGet
+				}
+				pushers, err = sinkOpts.start(input.Id, input.Url, method)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao configurar sinks: %v\n", err)
+					os.Exit(1)
+				}
+				for _, pusher := range pushers {
+					ctx = run.WithObserver(ctx, pusher.Observer())
+				}
+				if sinkOpts.traced() {
+					ctx = run.WithTracing(ctx, sinkOpts.sampleRatio)
+				}
+			}
+
+			// Saídas que gravam cada medição assim que termina
+			var closers []func() error
+			for _, out := range outputs {
+				if out.Format.Stream == nil {
+					continue
+				}
+				observer, closeFile, err := out.Format.Stream(out.Path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao criar arquivo %s: %v\n", out.Path, err)
+					os.Exit(1)
+				}
+				ctx = run.WithObserver(ctx, observer)
+				closers = append(closers, closeFile)
+			}
+
+			var report run.RunOutputDTO
+			if len(agents) > 0 {
+				report, err = usecase.RunDistributed(ctx, input, agents, agentToken)
+			} else {
+				report, err = usecase.Run(ctx, input)
+			}
+			for _, closeFile := range closers {
+				if err := closeFile(); err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao gravar medições: %v\n", err)
+				}
+			}
+			for _, pusher := range pushers {
+				if failures, lastError := pusher.Close(); failures > 0 {
+					fmt.Fprintf(os.Stderr, "Aviso: %d envios para o sink falharam, o último com: %v\n", failures, lastError)
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+
+			if len(thresholds) > 0 {
+				report.Thresholds = run.EvaluateThresholds(report, thresholds)
+			}
+
+			// Exibir dados
+			presenters.PrintReport(report)
+
+			for _, out := range outputs {
+				if out.Format.Save == nil {
+					continue
+				}
+				if err := out.Format.Save(report, out.Path); err != nil {
+					fmt.Fprintf(os.Stderr, "Erro ao salvar arquivo %s: %v\n", out.Path, err)
+				}
+			}
+
+			failed := 0
+			for _, threshold := range report.Thresholds {
+				if !threshold.Passed {
+					failed++
+				}
+			}
+			if failed > 0 {
+				fmt.Fprintf(os.Stderr, "Erro: %d de %d thresholds falharam\n", failed, len(report.Thresholds))
+				os.Exit(1)
+			}
+		},
+	}
+
+	runCmd.Flags().IntVarP(&requests, "requests", "r", 1, "Número total de requests")
+	runCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 1, "Número de chamadas simultâneas")
+	runCmd.Flags().BoolVarP(&showData, "showdata", "s", false, "Exibir dados de cada request")
+	runCmd.Flags().DurationVar(&expectedInterval, "expected-interval", 0, "Intervalo esperado entre as requests de um usuário virtual, de pelo menos 1ms, corrige os percentis para coordinated omission, ex: 10ms")
+	runCmd.Flags().StringVar(&warmup, "warmup", "", "Aquecimento enviado antes do teste e reportado à parte, em requests (ex: 500) ou duração (ex: 15s)")
+	runCmd.Flags().StringVarP(&output, "output", "o", "", "Nome dos arquivos de saída, atalho para --out json=nome.json --out md=nome.md")
+	runCmd.Flags().StringVar(&outputFormat, "output-format", outputJSON, "Formato dos arquivos do --output: json (com o relatório em Markdown) ou junit, atalho para --out junit=nome.xml")
+	runCmd.Flags().StringVar(&samplesPath, "samples", "", "Arquivo .csv ou .ndjson que recebe cada medição assim que termina, atalho para --out csv=arquivo.csv")
+	runCmd.Flags().StringArrayVar(&outs, "out", nil, "Arquivo de saída no formato formato=caminho, com formato "+strings.Join(presenters.Formats(), ", ")+" (pode repetir)")
+	runCmd.Flags().StringArrayVar(&thresholdExpressions, "threshold", nil, "Limite que o teste deve respeitar, ex: p95<500, avg<=200ms ou error_rate<1% (pode repetir)")
+	runCmd.Flags().StringSliceVar(&agents, "agents", nil, "Agentes que dividem o teste, separados por vírgula, ex: host1:7000,host2:7000")
+	runCmd.Flags().StringVar(&agentToken, "agent-token", "", "Token enviado aos agentes, lido de "+tokenEnv+" quando omitido")
+	runCmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Endereço em que as métricas Prometheus ficam expostas durante o teste, ex: :9100")
+	request.register(runCmd.Flags())
+	sinkOpts.register(runCmd.Flags())
+
+	runCmd.MarkFlagsOneRequired("url", "curl")
+
+	return runCmd
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"stresstest/internal/repository"
+	"stresstest/internal/server"
+	"stresstest/internal/usecase/run"
+
+	"github.com/spf13/cobra"
+)
+
+func newServeCmd(usecase *run.RunUseCase, repo repository.RepositoryInterface) *cobra.Command {
+	var listen string
+	var maxRuns int
+	var keepRuns int
+	var token string
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Launch and monitor stress tests over an HTTP API 🛰️",
+		Run: func(cmd *cobra.Command, args []string) {
+			if token == "" {
+				token = os.Getenv(tokenEnv)
+			}
+			if token == "" {
+				fmt.Fprintf(os.Stderr, "Erro: a API precisa de um token, use --token ou a variável %s\n", tokenEnv)
+				os.Exit(1)
+			}
+			api := server.NewServer(usecase, repo, maxRuns, keepRuns, token)
+			fmt.Printf("API aguardando testes em %s\n", listen)
+			if err := http.ListenAndServe(listen, api.Handler()); err != nil {
+				fmt.Fprintf(os.Stderr, "Erro: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	}
+
+	serveCmd.Flags().StringVar(&listen, "listen", "127.0.0.1:8080", "Endereço em que a API recebe as requisições, use 0.0.0.0:8080 para aceitar outras máquinas")
+	serveCmd.Flags().IntVar(&maxRuns, "max-runs", 2, "Número máximo de testes executando ao mesmo tempo")
+	serveCmd.Flags().IntVar(&keepRuns, "keep-runs", 100, "Número de testes finalizados mantidos com status e relatório")
+	serveCmd.Flags().StringVar(&token, "token", "", "Token que os clientes devem enviar, lido de "+tokenEnv+" quando omitido")
+
+	return serveCmd
+}
@@ -0,0 +1,221 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"stresstest/internal/entity"
+	"strings"
+	"time"
+)
+
+const (
+	FormatAuto     = "auto"
+	FormatCombined = "combined"
+	FormatJSON     = "json"
+
+	ErrUnknownFormat = "unknown access log format, must be auto, combined or json"
+)
+
+// Entry is a single request read from an access log
+type Entry struct {
+	Time       time.Time
+	Method     string
+	Path       string
+	Status     int
+	Latency    time.Duration
+	HasLatency bool
+}
+
+// combinedRegex matches the nginx/Apache combined format, anything after the user agent is kept for the latency
+var combinedRegex = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) \S+(?: "(?:[^"\\]|\\.)*" "(?:[^"\\]|\\.)*")?(.*)$`)
+
+const combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ParseFile reads every entry of the access log at path
+func ParseFile(path, format string) ([]Entry, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	return Parse(file, format)
+}
+
+// Parse reads every entry of an access log and returns them along with how many lines were skipped
+// Lines that can't be parsed are skipped instead of failing the whole replay
+func Parse(r io.Reader, format string) ([]Entry, int, error) {
+	if format == "" {
+		format = FormatAuto
+	}
+	if format != FormatAuto && format != FormatCombined && format != FormatJSON {
+		return nil, 0, errors.New(ErrUnknownFormat)
+	}
+
+	var entries []Entry
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		lineFormat := format
+		if lineFormat == FormatAuto {
+			lineFormat = FormatCombined
+			if strings.HasPrefix(line, "{") {
+				lineFormat = FormatJSON
+			}
+		}
+
+		var entry Entry
+		var err error
+		if lineFormat == FormatJSON {
+			entry, err = ParseJSONLine(line)
+		} else {
+			entry, err = ParseCombinedLine(line)
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, skipped, scanner.Err()
+}
+
+// ParseCombinedLine parses a line in the combined format
+// A latency appended after the user agent is understood as seconds when it has a decimal point (nginx $request_time)
+// and as microseconds otherwise (Apache %D)
+func ParseCombinedLine(line string) (Entry, error) {
+	match := combinedRegex.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, fmt.Errorf("line is not in the combined format: %q", line)
+	}
+
+	t, err := time.Parse(combinedTimeLayout, match[1])
+	if err != nil {
+		return Entry{}, err
+	}
+	method, path, err := parseRequestLine(match[2])
+	if err != nil {
+		return Entry{}, err
+	}
+	status, _ := strconv.Atoi(match[3])
+
+	entry := Entry{Time: t, Method: method, Path: path, Status: status}
+	if fields := strings.Fields(match[4]); len(fields) > 0 {
+		entry.Latency, entry.HasLatency = parseTrailingLatency(fields[len(fields)-1])
+	}
+	return entry, nil
+}
+
+// ParseJSONLine parses a JSON access log line
+// Common key names from nginx, Apache and log shippers are recognized
+func ParseJSONLine(line string) (Entry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return Entry{}, err
+	}
+
+	var entry Entry
+	if raw, ok := firstKey(fields, "request"); ok {
+		method, path, err := parseRequestLine(fmt.Sprint(raw))
+		if err != nil {
+			return Entry{}, err
+		}
+		entry.Method, entry.Path = method, path
+	}
+	if raw, ok := firstKey(fields, "method", "request_method"); ok {
+		entry.Method = strings.ToUpper(fmt.Sprint(raw))
+	}
+	if raw, ok := firstKey(fields, "path", "uri", "request_uri", "url"); ok {
+		entry.Path = fmt.Sprint(raw)
+		if query, ok := firstKey(fields, "query_string", "args"); ok && query != "" && !strings.Contains(entry.Path, "?") {
+			entry.Path += "?" + fmt.Sprint(query)
+		}
+	}
+	if entry.Method == "" {
+		entry.Method = "GET"
+	}
+	if entry.Path == "" {
+		return Entry{}, errors.New("json line has no request path")
+	}
+	if !entity.IsValidMethod(entry.Method) {
+		return Entry{}, fmt.Errorf("invalid method %q", entry.Method)
+	}
+
+	if raw, ok := firstKey(fields, "status", "status_code"); ok {
+		entry.Status, _ = strconv.Atoi(fmt.Sprint(raw))
+	}
+	if raw, ok := firstKey(fields, "time", "timestamp", "@timestamp", "time_iso8601", "time_local"); ok {
+		entry.Time = parseTime(raw)
+	}
+
+	// Seconds for the nginx and generic names, milliseconds when the key says so
+	if raw, ok := firstKey(fields, "request_time", "duration", "latency"); ok {
+		if seconds, err := strconv.ParseFloat(fmt.Sprint(raw), 64); err == nil {
+			entry.Latency, entry.HasLatency = time.Duration(seconds*float64(time.Second)), true
+		}
+	} else if raw, ok := firstKey(fields, "duration_ms", "latency_ms", "request_time_ms"); ok {
+		if ms, err := strconv.ParseFloat(fmt.Sprint(raw), 64); err == nil {
+			entry.Latency, entry.HasLatency = time.Duration(ms*float64(time.Millisecond)), true
+		}
+	}
+	return entry, nil
+}
+
+func parseRequestLine(request string) (method, path string, err error) {
+	parts := strings.Fields(request)
+	if len(parts) < 2 || !entity.IsValidMethod(parts[0]) || !strings.HasPrefix(parts[1], "/") {
+		return "", "", fmt.Errorf("invalid request line %q", request)
+	}
+	return parts[0], parts[1], nil
+}
+
+func parseTrailingLatency(field string) (time.Duration, bool) {
+	if _, value, ok := strings.Cut(field, "="); ok {
+		field = value
+	}
+	if strings.Contains(field, ".") {
+		seconds, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	micros, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(micros) * time.Microsecond, true
+}
+
+func parseTime(raw interface{}) time.Time {
+	if seconds, ok := raw.(float64); ok {
+		return time.Unix(0, int64(seconds*float64(time.Second)))
+	}
+	value := fmt.Sprint(raw)
+	for _, layout := range []string{time.RFC3339Nano, combinedTimeLayout} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func firstKey(fields map[string]interface{}, keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok && value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
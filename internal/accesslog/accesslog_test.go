@@ -0,0 +1,80 @@
+package accesslog_test
+
+import (
+	"stresstest/internal/accesslog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCombinedLine_NginxRequestTime(t *testing.T) {
+	line := `10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET /items?page=2 HTTP/1.1" 200 512 "-" "curl/8.0" 0.125`
+
+	entry, err := accesslog.ParseCombinedLine(line)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", entry.Method)
+	assert.Equal(t, "/items?page=2", entry.Path)
+	assert.Equal(t, 200, entry.Status)
+	assert.True(t, entry.HasLatency)
+	assert.Equal(t, 125*time.Millisecond, entry.Latency)
+	assert.Equal(t, 2025, entry.Time.Year())
+}
+
+func TestParseCombinedLine_ApacheMicroseconds(t *testing.T) {
+	line := `10.0.0.1 - frank [26/Mar/2025:10:23:51 -0300] "POST /login HTTP/1.1" 302 - "https://example.com/" "Mozilla/5.0 \"quoted\"" 45000`
+
+	entry, err := accesslog.ParseCombinedLine(line)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", entry.Method)
+	assert.Equal(t, 302, entry.Status)
+	assert.Equal(t, 45*time.Millisecond, entry.Latency)
+}
+
+func TestParseCombinedLine_WithoutLatency(t *testing.T) {
+	line := `10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET / HTTP/1.1" 404 0`
+
+	entry, err := accesslog.ParseCombinedLine(line)
+
+	assert.NoError(t, err)
+	assert.False(t, entry.HasLatency)
+}
+
+func TestParseJSONLine(t *testing.T) {
+	line := `{"time":"2025-03-26T10:23:51Z","request_method":"DELETE","uri":"/items/1","args":"force=true","status":"204","request_time":"0.010"}`
+
+	entry, err := accesslog.ParseJSONLine(line)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE", entry.Method)
+	assert.Equal(t, "/items/1?force=true", entry.Path)
+	assert.Equal(t, 204, entry.Status)
+	assert.Equal(t, 10*time.Millisecond, entry.Latency)
+	assert.False(t, entry.Time.IsZero())
+}
+
+func TestParse_SkipsInvalidLines(t *testing.T) {
+	log := strings.Join([]string{
+		`10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET /a HTTP/1.1" 200 1 "-" "-"`,
+		`not a log line`,
+		`{"request":"GET /b HTTP/1.1","status":200,"duration_ms":12}`,
+		``,
+	}, "\n")
+
+	entries, skipped, err := accesslog.Parse(strings.NewReader(log), accesslog.FormatAuto)
+
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 1, skipped)
+	assert.Equal(t, "/b", entries[1].Path)
+	assert.Equal(t, 12*time.Millisecond, entries[1].Latency)
+}
+
+func TestParse_UnknownFormat(t *testing.T) {
+	_, _, err := accesslog.Parse(strings.NewReader(""), "xml")
+
+	assert.EqualError(t, err, accesslog.ErrUnknownFormat)
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+const (
+	ErrMissingToken = "bearer auth needs a token, a token env var or a token file"
+	ErrEmptyToken   = "bearer token is empty"
+)
+
+// Authenticator provides the Authorization header sent with every request of a run
+type Authenticator interface {
+	// Authorization returns the current value of the Authorization header
+	Authorization() string
+	// Stats returns how the authenticator behaved during the run
+	Stats() Stats
+}
+
+// Stats describes the token fetches an authenticator made
+type Stats struct {
+	TokenFetches    int
+	RefreshFailures int
+	LastError       string
+}
+
+// Static is an authenticator whose header never changes
+type Static string
+
+func (s Static) Authorization() string {
+	return string(s)
+}
+
+func (s Static) Stats() Stats {
+	return Stats{}
+}
+
+// Basic builds a basic auth authenticator
+func Basic(username, password string) Static {
+	return Static("Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+// Bearer builds a bearer authenticator reading the token from the first source given: the value itself,
+// an environment variable or a file
+func Bearer(token, tokenEnv, tokenFile string) (Static, error) {
+	switch {
+	case token != "":
+	case tokenEnv != "":
+		token = os.Getenv(tokenEnv)
+	case tokenFile != "":
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", err
+		}
+		token = string(content)
+	default:
+		return "", errors.New(ErrMissingToken)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New(ErrEmptyToken)
+	}
+	return Static("Bearer " + token), nil
+}
@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"stresstest/internal/auth"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasic(t *testing.T) {
+	assert.Equal(t, "Basic dXNlcjpwYXNz", auth.Basic("user", "pass").Authorization())
+}
+
+func TestBearer_Sources(t *testing.T) {
+	t.Setenv("STRESSTEST_TOKEN", "from-env")
+	path := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0600))
+
+	fromFlag, err := auth.Bearer("from-flag", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer from-flag", fromFlag.Authorization())
+
+	fromEnv, err := auth.Bearer("", "STRESSTEST_TOKEN", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer from-env", fromEnv.Authorization())
+
+	fromFile, err := auth.Bearer("", "", path)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer from-file", fromFile.Authorization())
+}
+
+func TestBearer_MissingToken(t *testing.T) {
+	_, err := auth.Bearer("", "", "")
+	assert.EqualError(t, err, auth.ErrMissingToken)
+
+	_, err = auth.Bearer("", "STRESSTEST_UNSET_TOKEN", "")
+	assert.EqualError(t, err, auth.ErrEmptyToken)
+}
+
+func TestClientCredentials_FetchesAndRefreshes(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		assert.Equal(t, "client", id)
+		assert.Equal(t, "secret", secret)
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "read write", r.FormValue("scope"))
+
+		n := fetches.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":1}`, n)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	credentials := auth.NewClientCredentials(server.Client(), auth.ClientCredentialsConfig{
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"read", "write"},
+	})
+
+	err := credentials.Start(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token-1", credentials.Authorization())
+	assert.Eventually(t, func() bool {
+		return credentials.Authorization() != "Bearer token-1"
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, credentials.Stats().TokenFetches, 2)
+}
+
+func TestClientCredentials_CountsRefreshFailures(t *testing.T) {
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"first","expires_in":1}`)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	credentials := auth.NewClientCredentials(server.Client(), auth.ClientCredentialsConfig{TokenURL: server.URL})
+
+	err := credentials.Start(ctx)
+
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return credentials.Stats().RefreshFailures > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Bearer first", credentials.Authorization())
+	assert.Contains(t, credentials.Stats().LastError, "500")
+}
+
+func TestClientCredentials_FailsWhenFirstFetchFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	credentials := auth.NewClientCredentials(server.Client(), auth.ClientCredentialsConfig{TokenURL: server.URL})
+
+	err := credentials.Start(context.Background())
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshMargin is how long before expiry the token is refreshed
+	refreshMargin = 30 * time.Second
+	// retryInterval is how long to wait before retrying a failed refresh
+	retryInterval = time.Second
+)
+
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// ClientCredentials fetches a token with the OAuth2 client credentials grant and refreshes it in the background
+// Fetches happen outside the requests of the run, so they never count towards the measured latency
+type ClientCredentials struct {
+	client *http.Client
+	config ClientCredentialsConfig
+
+	mu            sync.RWMutex
+	authorization string
+	expiry        time.Time
+	stats         Stats
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func NewClientCredentials(client *http.Client, config ClientCredentialsConfig) *ClientCredentials {
+	return &ClientCredentials{client: client, config: config}
+}
+
+// Start fetches the first token and keeps refreshing it until ctx is done
+func (c *ClientCredentials) Start(ctx context.Context) error {
+	if err := c.fetch(ctx); err != nil {
+		return fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	go c.refreshLoop(ctx)
+	return nil
+}
+
+func (c *ClientCredentials) Authorization() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.authorization
+}
+
+func (c *ClientCredentials) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+func (c *ClientCredentials) refreshLoop(ctx context.Context) {
+	for {
+		c.mu.RLock()
+		expiry := c.expiry
+		c.mu.RUnlock()
+
+		// Tokens without expiry never need a refresh
+		if expiry.IsZero() {
+			return
+		}
+
+		// Short lived tokens are refreshed halfway through their lifetime instead
+		wait := time.Until(expiry) - refreshMargin
+		if wait <= 0 {
+			wait = time.Until(expiry) / 2
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		// The old token keeps being used while the refresh is retried
+		for {
+			err := c.fetch(ctx)
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			c.mu.Lock()
+			c.stats.RefreshFailures++
+			c.stats.LastError = err.Error()
+			c.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryInterval):
+			}
+		}
+	}
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) error {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.config.ClientID), url.QueryEscape(c.config.ClientSecret))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return err
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("token endpoint returned no access_token")
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authorization = tokenType + " " + token.AccessToken
+	c.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	c.stats.TokenFetches++
+	return nil
+}
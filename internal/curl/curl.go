@@ -0,0 +1,309 @@
+package curl
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	ErrEmptyCommand    = "curl command is empty"
+	ErrNotCurl         = "command must start with curl"
+	ErrUnclosedQuote   = "curl command has an unclosed quote"
+	ErrMissingURL      = "curl command has no url"
+	ErrMissingArgument = "curl flag is missing its argument"
+)
+
+// Command is the result of parsing a curl command line
+// Only the parts the stress test knows how to reproduce are kept, everything else ends up in Warnings
+type Command struct {
+	Method     string
+	Url        string
+	Headers    map[string]string
+	Body       string
+	Insecure   bool
+	Compressed bool
+	Warnings   []string
+}
+
+// short flags that take an argument and are supported, the unsupported ones are in unsupportedWithArgument
+var shortWithArgument = map[string]bool{"-X": true, "-H": true, "-d": true, "-u": true, "-b": true, "-A": true, "-e": true}
+
+// flags that take an argument but are not supported, so their value must be skipped
+var unsupportedWithArgument = map[string]bool{
+	"-o": true, "--output": true,
+	"-w": true, "--write-out": true,
+	"-F": true, "--form": true,
+	"-T": true, "--upload-file": true,
+	"-x": true, "--proxy": true,
+	"-c": true, "--cookie-jar": true,
+	"-m": true, "--max-time": true,
+	"-E": true, "--cert": true,
+	"--key": true, "--cacert": true,
+	"--connect-timeout": true, "--retry": true,
+	"--resolve": true, "--limit-rate": true,
+}
+
+// Parse turns a curl command line, as copied from a terminal or a browser, into a Command
+func Parse(command string) (*Command, error) {
+	args, err := splitArgs(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New(ErrEmptyCommand)
+	}
+	if args[0] != "curl" {
+		return nil, errors.New(ErrNotCurl)
+	}
+	args = expandFlags(args)
+
+	cmd := &Command{Headers: make(map[string]string)}
+	var data []string
+	head := false
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+
+		// Every supported flag but the boolean ones needs the next argument
+		value := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("%s: %s", ErrMissingArgument, arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch arg {
+		case "-X", "--request":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Method = strings.ToUpper(v)
+		case "-H", "--header":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			name, val, ok := strings.Cut(v, ":")
+			if !ok {
+				cmd.Warnings = append(cmd.Warnings, fmt.Sprintf("ignoring malformed header %q", v))
+				continue
+			}
+			cmd.Headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(val)
+		case "-d", "--data", "--data-ascii", "--data-raw", "--data-binary":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			// --data-raw never reads files, the other variants do when the value starts with @
+			if arg != "--data-raw" && strings.HasPrefix(v, "@") {
+				content, err := os.ReadFile(v[1:])
+				if err != nil {
+					return nil, err
+				}
+				v = string(content)
+				if arg != "--data-binary" {
+					v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+				}
+			}
+			data = append(data, v)
+		case "-u", "--user":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(v))
+		case "-b", "--cookie":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			// Without a "=" curl treats the value as a cookie file
+			if !strings.Contains(v, "=") {
+				cmd.Warnings = append(cmd.Warnings, fmt.Sprintf("cookie files are not supported, ignoring %q", v))
+				continue
+			}
+			cmd.Headers["Cookie"] = v
+		case "-A", "--user-agent":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Headers["User-Agent"] = v
+		case "-e", "--referer":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Headers["Referer"] = v
+		case "--url":
+			v, err := value()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Url = v
+		case "--compressed":
+			cmd.Compressed = true
+		case "-k", "--insecure":
+			cmd.Insecure = true
+		case "-I", "--head":
+			head = true
+		case "-s", "--silent", "-S", "--show-error", "-v", "--verbose", "-i", "--include", "-L", "--location":
+			// Output related flags make no difference for a stress test
+		default:
+			if strings.HasPrefix(arg, "-") {
+				if unsupportedWithArgument[arg] && i+1 < len(args) {
+					i++
+				}
+				cmd.Warnings = append(cmd.Warnings, fmt.Sprintf("unsupported flag %s was ignored", arg))
+				continue
+			}
+			if cmd.Url != "" {
+				cmd.Warnings = append(cmd.Warnings, fmt.Sprintf("only one url is supported, ignoring %q", arg))
+				continue
+			}
+			cmd.Url = arg
+		}
+	}
+
+	if cmd.Url == "" {
+		return nil, errors.New(ErrMissingURL)
+	}
+
+	if len(data) > 0 {
+		cmd.Body = strings.Join(data, "&")
+		if _, ok := cmd.Headers["Content-Type"]; !ok {
+			cmd.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+		}
+	}
+
+	if cmd.Method == "" {
+		switch {
+		case head:
+			cmd.Method = http.MethodHead
+		case len(data) > 0:
+			cmd.Method = http.MethodPost
+		default:
+			cmd.Method = http.MethodGet
+		}
+	}
+
+	return cmd, nil
+}
+
+// expandFlags separates what curl accepts glued together, like -XPOST, -sSL, -HName: v and --data=v,
+// into one argument per flag and value
+func expandFlags(args []string) []string {
+	expanded := make([]string, 0, len(args))
+	// pending is set while the next argument is the value of a flag, kept as it is even when it starts with a dash
+	pending := false
+	for _, arg := range args {
+		switch {
+		case pending:
+			expanded = append(expanded, arg)
+			pending = false
+		case strings.HasPrefix(arg, "--"):
+			if name, value, ok := strings.Cut(arg, "="); ok {
+				expanded = append(expanded, name, value)
+				continue
+			}
+			expanded = append(expanded, arg)
+			pending = takesArgument(arg)
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for i := 1; i < len(arg); i++ {
+				flag := "-" + arg[i:i+1]
+				expanded = append(expanded, flag)
+				if takesArgument(flag) {
+					// The rest of the argument is the value, or the next argument when there's nothing left
+					if i+1 < len(arg) {
+						expanded = append(expanded, arg[i+1:])
+					} else {
+						pending = true
+					}
+					break
+				}
+			}
+		default:
+			expanded = append(expanded, arg)
+		}
+	}
+	return expanded
+}
+
+// takesArgument tells if the flag is followed by a value
+func takesArgument(flag string) bool {
+	switch flag {
+	case "--request", "--header", "--data", "--data-ascii", "--data-raw", "--data-binary",
+		"--user", "--cookie", "--user-agent", "--referer", "--url":
+		return true
+	}
+	return shortWithArgument[flag] || unsupportedWithArgument[flag]
+}
+
+// splitArgs splits a command line the way a POSIX shell would, handling quotes, escapes and line continuations
+func splitArgs(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]):
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			default:
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				// A backslash before a line break only continues the command
+				if runes[i] == '\n' || runes[i] == '\r' {
+					continue
+				}
+				current.WriteRune(runes[i])
+				inArg = true
+			}
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New(ErrUnclosedQuote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,95 @@
+package curl_test
+
+import (
+	"os"
+	"path/filepath"
+	"stresstest/internal/curl"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_FullCommand(t *testing.T) {
+	command := `curl -X PUT 'https://api.example.com/items/1' \
+  -H 'Content-Type: application/json' \
+  -H "Authorization: Bearer abc" \
+  --data-raw '{"name":"item"}' \
+  -b 'session=123' --compressed -k`
+
+	cmd, err := curl.Parse(command)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "PUT", cmd.Method)
+	assert.Equal(t, "https://api.example.com/items/1", cmd.Url)
+	assert.Equal(t, "application/json", cmd.Headers["Content-Type"])
+	assert.Equal(t, "Bearer abc", cmd.Headers["Authorization"])
+	assert.Equal(t, "session=123", cmd.Headers["Cookie"])
+	assert.Equal(t, `{"name":"item"}`, cmd.Body)
+	assert.True(t, cmd.Compressed)
+	assert.True(t, cmd.Insecure)
+	assert.Empty(t, cmd.Warnings)
+}
+
+func TestParse_AttachedAndCombinedFlags(t *testing.T) {
+	command := `curl -sSL -XPOST '-HContent-Type: application/json' --header='Accept: text/plain' --data='{"a":1}' -d -1 --url=http://example.com -kI`
+
+	cmd, err := curl.Parse(command)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", cmd.Method)
+	assert.Equal(t, "http://example.com", cmd.Url)
+	assert.Equal(t, "application/json", cmd.Headers["Content-Type"])
+	assert.Equal(t, "text/plain", cmd.Headers["Accept"])
+	assert.Equal(t, `{"a":1}&-1`, cmd.Body)
+	assert.True(t, cmd.Insecure)
+	assert.Empty(t, cmd.Warnings)
+}
+
+func TestParse_DataDefaultsToPostForm(t *testing.T) {
+	cmd, err := curl.Parse(`curl http://example.com -d a=1 -d b=2`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", cmd.Method)
+	assert.Equal(t, "a=1&b=2", cmd.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", cmd.Headers["Content-Type"])
+}
+
+func TestParse_DataBinaryFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "body.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{\n\"a\": 1\n}"), 0644))
+
+	cmd, err := curl.Parse(`curl http://example.com --data-binary @` + path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n\"a\": 1\n}", cmd.Body)
+}
+
+func TestParse_BasicAuth(t *testing.T) {
+	cmd, err := curl.Parse(`curl -u user:pass http://example.com`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", cmd.Method)
+	assert.Equal(t, "Basic dXNlcjpwYXNz", cmd.Headers["Authorization"])
+}
+
+func TestParse_UnsupportedFlagsProduceWarnings(t *testing.T) {
+	cmd, err := curl.Parse(`curl --max-time 10 -F file=@a.txt http://example.com`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com", cmd.Url)
+	assert.Len(t, cmd.Warnings, 2)
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := curl.Parse(``)
+	assert.EqualError(t, err, curl.ErrEmptyCommand)
+
+	_, err = curl.Parse(`wget http://example.com`)
+	assert.EqualError(t, err, curl.ErrNotCurl)
+
+	_, err = curl.Parse(`curl 'http://example.com`)
+	assert.EqualError(t, err, curl.ErrUnclosedQuote)
+
+	_, err = curl.Parse(`curl -X POST`)
+	assert.EqualError(t, err, curl.ErrMissingURL)
+}
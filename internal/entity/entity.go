@@ -2,16 +2,19 @@ package entity
 
 import (
 	"errors"
+	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const (
-	ErrInvalidURL             = "invalid url, must be in the format http://example.com or https://example.com"
+	ErrInvalidURL             = "invalid url, must be in the format http://example.com, https://example.com, ws://example.com, wss://example.com, grpc://example.com:50051, grpcs://example.com:443, tcp://example.com:9000 or udp://example.com:9000"
 	ErrNonNegativeRequests    = "requests must be greater than zero"
 	ErrNonNegativeConcurrency = "concurrency must be greater than zero"
+	ErrInvalidMethod          = "invalid http method"
 )
 
 type TestRun struct {
@@ -19,34 +22,56 @@ type TestRun struct {
 	Url         string
 	Requests    int
 	Concurrency int
+	Method      string
+	Headers     map[string]string
+	Body        string
 	Timestamp   time.Time
 }
 
 type TestRunOptions struct {
+	Id          string // a new one is generated when empty
 	Requests    int
 	Concurrency int
+	Method      string
+	Headers     map[string]string
+	Body        string
 }
 
 func NewTestRun(url string, opts *TestRunOptions) (*TestRun, error) {
 	requests := 100   // default
 	concurrency := 10 // default
+	method := http.MethodGet
+	id := uuid.New().String()
+	var headers map[string]string
+	var body string
 	if opts != nil {
+		if opts.Id != "" {
+			id = opts.Id
+		}
 		if opts.Requests != 0 {
 			requests = opts.Requests
 		}
 		if opts.Concurrency != 0 {
 			concurrency = opts.Concurrency
 		}
+		if opts.Method != "" {
+			method = strings.ToUpper(opts.Method)
+		}
+		headers = opts.Headers
+		body = opts.Body
 	}
 	if concurrency > requests {
 		concurrency = requests
 	}
 
 	tr := &TestRun{
-		Id:          uuid.New().String(),
+		Id:          id,
 		Url:         url,
 		Requests:    requests,
 		Concurrency: concurrency,
+		Method:      method,
+		Headers:     headers,
+		Body:        body,
 		Timestamp:   time.Now(),
 	}
 
@@ -68,10 +93,60 @@ func (tr *TestRun) Validate() error {
 	if tr.Concurrency <= 0 {
 		return errors.New(ErrNonNegativeConcurrency)
 	}
+	if !IsValidMethod(tr.Method) {
+		return errors.New(ErrInvalidMethod)
+	}
 	return nil
 }
 
+// SupportedSchemes are the URL schemes a test run can target
+var SupportedSchemes = map[string]bool{
+	"http":  true,
+	"https": true,
+	"ws":    true,
+	"wss":   true,
+	"grpc":  true,
+	"grpcs": true,
+	"tcp":   true,
+	"udp":   true,
+}
+
 func IsValidURL(str string) bool {
 	u, err := url.ParseRequestURI(str)
-	return err == nil && u.Scheme != "" && u.Host != ""
+	if err != nil || !SupportedSchemes[u.Scheme] || u.Host == "" {
+		return false
+	}
+	// Raw sockets have no default port to fall back to
+	return !IsSocketURL(str) || u.Port() != ""
+}
+
+// IsWebSocketURL checks if the URL targets a WebSocket endpoint
+func IsWebSocketURL(str string) bool {
+	u, err := url.Parse(str)
+	return err == nil && (u.Scheme == "ws" || u.Scheme == "wss")
+}
+
+// IsGRPCURL checks if the URL targets a gRPC server, grpcs:// meaning it uses TLS
+func IsGRPCURL(str string) bool {
+	u, err := url.Parse(str)
+	return err == nil && (u.Scheme == "grpc" || u.Scheme == "grpcs")
+}
+
+// IsSocketURL checks if the URL targets a raw TCP or UDP port
+func IsSocketURL(str string) bool {
+	u, err := url.Parse(str)
+	return err == nil && (u.Scheme == "tcp" || u.Scheme == "udp")
+}
+
+// IsValidMethod checks the method is made of uppercase letters only, so custom methods are still allowed
+func IsValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, r := range method {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
 }
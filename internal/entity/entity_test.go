@@ -58,3 +58,49 @@ func TestNewTestRun_DefaultValues(t *testing.T) {
 	assert.Equal(t, 100, tr.Requests)
 	assert.Equal(t, 10, tr.Concurrency)
 }
+
+func TestNewTestRun_DefaultMethodIsGet(t *testing.T) {
+	tr, err := entity.NewTestRun("http://example.com", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "GET", tr.Method)
+}
+
+func TestNewTestRun_InvalidMethod(t *testing.T) {
+	opts := &entity.TestRunOptions{Requests: 10, Concurrency: 5, Method: "GET /"}
+	tr, err := entity.NewTestRun("http://example.com", opts)
+
+	assert.Nil(t, tr)
+	assert.EqualError(t, err, entity.ErrInvalidMethod)
+}
+
+func TestNewTestRun_WebSocketURL(t *testing.T) {
+	tr, err := entity.NewTestRun("wss://example.com/socket", nil)
+
+	assert.NoError(t, err)
+	assert.True(t, entity.IsWebSocketURL(tr.Url))
+}
+
+func TestNewTestRun_SocketURL(t *testing.T) {
+	tr, err := entity.NewTestRun("udp://127.0.0.1:9000", nil)
+
+	assert.NoError(t, err)
+	assert.True(t, entity.IsSocketURL(tr.Url))
+
+	_, err = entity.NewTestRun("tcp://example.com", nil)
+	assert.EqualError(t, err, entity.ErrInvalidURL)
+}
+
+func TestNewTestRun_UnsupportedScheme(t *testing.T) {
+	tr, err := entity.NewTestRun("ftp://example.com", nil)
+
+	assert.Nil(t, tr)
+	assert.EqualError(t, err, entity.ErrInvalidURL)
+}
+
+func TestNewTestRun_CustomId(t *testing.T) {
+	tr, err := entity.NewTestRun("http://example.com", &entity.TestRunOptions{Id: "run-1"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "run-1", tr.Id)
+}
@@ -0,0 +1,208 @@
+package grpcdesc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	ErrInvalidMethodName = "invalid grpc method, must be in the format package.Service/Method"
+	ErrMethodNotFound    = "grpc method not found"
+)
+
+// Resolver finds the descriptor of the method a gRPC run calls
+type Resolver interface {
+	FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error)
+}
+
+// FindMethod looks up a method given as package.Service/Method or package.Service.Method
+func FindMethod(resolver Resolver, method string) (protoreflect.MethodDescriptor, error) {
+	name := strings.TrimPrefix(method, "/")
+	if service, methodName, ok := strings.Cut(name, "/"); ok {
+		name = service + "." + methodName
+	}
+	if !strings.Contains(name, ".") {
+		return nil, errors.New(ErrInvalidMethodName)
+	}
+
+	descriptor, err := resolver.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrMethodNotFound, method)
+	}
+	methodDescriptor, ok := descriptor.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s", ErrMethodNotFound, method)
+	}
+	return methodDescriptor, nil
+}
+
+// FullMethodName returns the name a method is invoked with, like /package.Service/Method
+func FullMethodName(method protoreflect.MethodDescriptor) string {
+	return "/" + string(method.Parent().FullName()) + "/" + string(method.Name())
+}
+
+// FromProtoFiles compiles .proto files, imports are looked up in importPaths and in the standard google/protobuf files
+func FromProtoFiles(ctx context.Context, files, importPaths []string) (Resolver, error) {
+	return compile(ctx, files, importPaths)
+}
+
+// DescriptorSet compiles .proto files into a serialized FileDescriptorSet, imports included,
+// so the descriptors can be used where the files aren't
+func DescriptorSet(ctx context.Context, files, importPaths []string) ([]byte, error) {
+	registry, err := compile(ctx, files, importPaths)
+	if err != nil {
+		return nil, err
+	}
+	set := new(descriptorpb.FileDescriptorSet)
+	registry.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(file))
+		return true
+	})
+	return proto.Marshal(set)
+}
+
+// FromDescriptorSet reads the descriptors of a serialized FileDescriptorSet, see DescriptorSet
+func FromDescriptorSet(data []byte) (Resolver, error) {
+	set := new(descriptorpb.FileDescriptorSet)
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	return protodesc.NewFiles(set)
+}
+
+func compile(ctx context.Context, files, importPaths []string) (*protoregistry.Files, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	compiled, err := compiler.Compile(ctx, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := new(protoregistry.Files)
+	for _, file := range compiled {
+		if err := registerWithImports(registry, file); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+func registerWithImports(registry *protoregistry.Files, file protoreflect.FileDescriptor) error {
+	if _, err := registry.FindFileByPath(file.Path()); err == nil {
+		return nil
+	}
+	imports := file.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		if err := registerWithImports(registry, imports.Get(i).FileDescriptor); err != nil {
+			return err
+		}
+	}
+	return registry.RegisterFile(file)
+}
+
+// FromReflection asks the server for the descriptors of the service through server reflection
+func FromReflection(ctx context.Context, conn grpc.ClientConnInterface, method string) (Resolver, error) {
+	service, _, ok := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	if !ok {
+		index := strings.LastIndex(method, ".")
+		if index < 0 {
+			return nil, errors.New(ErrInvalidMethodName)
+		}
+		service = method[:index]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	fetched := make(map[string]*descriptorpb.FileDescriptorProto)
+	if err := fetchFiles(stream, &reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}, fetched); err != nil {
+		return nil, err
+	}
+
+	registry := new(protoregistry.Files)
+	for name := range fetched {
+		if err := registerFetched(stream, registry, fetched, name); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// registerFetched registers a file after its dependencies, asking the server for any it didn't send yet
+// Dependencies the server doesn't know, like the well known types, come from the ones compiled into the binary
+func registerFetched(stream reflectionpb.ServerReflection_ServerReflectionInfoClient, registry *protoregistry.Files, fetched map[string]*descriptorpb.FileDescriptorProto, name string) error {
+	if _, err := registry.FindFileByPath(name); err == nil {
+		return nil
+	}
+
+	file, ok := fetched[name]
+	if !ok {
+		if global, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+			return registry.RegisterFile(global)
+		}
+		if err := fetchFiles(stream, &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+		}, fetched); err != nil {
+			return err
+		}
+		if file, ok = fetched[name]; !ok {
+			return fmt.Errorf("server reflection did not return %s", name)
+		}
+	}
+
+	for _, dependency := range file.GetDependency() {
+		if err := registerFetched(stream, registry, fetched, dependency); err != nil {
+			return err
+		}
+	}
+	descriptor, err := protodesc.NewFile(file, registry)
+	if err != nil {
+		return err
+	}
+	return registry.RegisterFile(descriptor)
+}
+
+func fetchFiles(stream reflectionpb.ServerReflection_ServerReflectionInfoClient, request *reflectionpb.ServerReflectionRequest, fetched map[string]*descriptorpb.FileDescriptorProto) error {
+	if err := stream.Send(request); err != nil {
+		return err
+	}
+	response, err := stream.Recv()
+	if err == io.EOF {
+		return errors.New("server reflection closed the stream")
+	}
+	if err != nil {
+		return err
+	}
+	if errResponse := response.GetErrorResponse(); errResponse != nil {
+		return fmt.Errorf("server reflection: %s", errResponse.GetErrorMessage())
+	}
+
+	for _, raw := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(raw, file); err != nil {
+			return err
+		}
+		fetched[file.GetName()] = file
+	}
+	return nil
+}
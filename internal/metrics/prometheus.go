@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"sync"
+)
+
+// PhaseTotal labels the latency of the whole request, next to the phases of run.Measurement
+const PhaseTotal = "total"
+
+// LatencyBuckets are the upper bounds of the latency histogram, in seconds
+var LatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus keeps the metrics of the runs it observes and serves them in the Prometheus text format
+type Prometheus struct {
+	mu       sync.Mutex
+	requests map[string]float64 // by status
+	inFlight int
+	latency  map[latencyKey]*latencyHistogram
+	bytes    int64
+	errors   map[string]float64 // by class
+}
+
+type latencyKey struct {
+	status string
+	phase  string
+}
+
+type latencyHistogram struct {
+	buckets []float64 // count of observations up to each of LatencyBuckets
+	count   float64
+	sum     float64
+}
+
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		requests: make(map[string]float64),
+		latency:  make(map[latencyKey]*latencyHistogram),
+		errors:   make(map[string]float64),
+	}
+}
+
+// Observer returns the observer that feeds the metrics, to be passed to run.WithObserver
+func (p *Prometheus) Observer() run.Observer {
+	return run.Observer{Measured: p.measured, InFlight: p.changeInFlight}
+}
+
+func (p *Prometheus) measured(m run.Measurement) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m.Request {
+		p.requests[m.Status]++
+	}
+	p.observeLatency(latencyKey{m.Status, PhaseTotal}, m.Duration.Seconds())
+	for phase, duration := range m.Phases {
+		p.observeLatency(latencyKey{m.Status, phase}, duration.Seconds())
+	}
+	p.bytes += m.BytesReceived
+	if m.ErrorClass != "" {
+		p.errors[m.ErrorClass]++
+	}
+}
+
+// observeLatency adds an observation to a histogram, callers must hold the lock
+func (p *Prometheus) observeLatency(key latencyKey, seconds float64) {
+	h, exists := p.latency[key]
+	if !exists {
+		h = &latencyHistogram{buckets: make([]float64, len(LatencyBuckets))}
+		p.latency[key] = h
+	}
+	for i, bound := range LatencyBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (p *Prometheus) changeInFlight(delta int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight += delta
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format, with series sorted by their labels
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var out strings.Builder
+	header := func(name, kind, help string) {
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("stresstest_requests_total", "counter", "Requests finished, by status.")
+	for _, status := range sortedKeys(p.requests) {
+		fmt.Fprintf(&out, "stresstest_requests_total{status=%s} %s\n", quote(status), formatValue(p.requests[status]))
+	}
+
+	header("stresstest_requests_in_flight", "gauge", "Requests sent and still waiting for their response.")
+	fmt.Fprintf(&out, "stresstest_requests_in_flight %d\n", p.inFlight)
+
+	header("stresstest_request_duration_seconds", "histogram", "Latency of the measurements of the run, by status and request phase.")
+	keys := make([]latencyKey, 0, len(p.latency))
+	for key := range p.latency {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].status != keys[j].status {
+			return keys[i].status < keys[j].status
+		}
+		return keys[i].phase < keys[j].phase
+	})
+	for _, key := range keys {
+		h := p.latency[key]
+		labels := fmt.Sprintf("status=%s,phase=%s", quote(key.status), quote(key.phase))
+		for i, bound := range LatencyBuckets {
+			fmt.Fprintf(&out, "stresstest_request_duration_seconds_bucket{%s,le=\"%s\"} %s\n", labels, formatValue(bound), formatValue(h.buckets[i]))
+		}
+		fmt.Fprintf(&out, "stresstest_request_duration_seconds_bucket{%s,le=\"+Inf\"} %s\n", labels, formatValue(h.count))
+		fmt.Fprintf(&out, "stresstest_request_duration_seconds_sum{%s} %s\n", labels, formatValue(h.sum))
+		fmt.Fprintf(&out, "stresstest_request_duration_seconds_count{%s} %s\n", labels, formatValue(h.count))
+	}
+
+	header("stresstest_received_bytes_total", "counter", "Bytes of the response bodies received.")
+	fmt.Fprintf(&out, "stresstest_received_bytes_total %d\n", p.bytes)
+
+	header("stresstest_errors_total", "counter", "Requests that failed, by error class.")
+	for _, class := range sortedKeys(p.errors) {
+		fmt.Fprintf(&out, "stresstest_errors_total{class=%s} %s\n", quote(class), formatValue(p.errors[class]))
+	}
+
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quote escapes a label value the way the exposition format expects
+func quote(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"stresstest/internal/metrics"
+	"stresstest/internal/usecase/run"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MustExposeMeasurementsInPrometheusFormat(t *testing.T) {
+	// Arrange
+	prometheus := metrics.NewPrometheus()
+	observer := prometheus.Observer()
+
+	// Act
+	observer.InFlight(1)
+	observer.InFlight(1)
+	observer.InFlight(-1)
+	observer.Measured(run.Measurement{
+		Status:        "200",
+		Duration:      30 * time.Millisecond,
+		Request:       true,
+		Phases:        map[string]time.Duration{run.PhaseWait: 20 * time.Millisecond},
+		BytesReceived: 512,
+	})
+	observer.Measured(run.Measurement{Status: "0", Duration: time.Millisecond, Request: true, ErrorClass: run.ErrorClassRefused})
+	observer.Measured(run.Measurement{Status: "connect", Duration: time.Millisecond})
+	recorder := httptest.NewRecorder()
+	prometheus.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	// Assert
+	body := recorder.Body.String()
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "version=0.0.4")
+	assert.Contains(t, body, "# TYPE stresstest_requests_total counter\n")
+	assert.Contains(t, body, `stresstest_requests_total{status="200"} 1`+"\n")
+	assert.NotContains(t, body, `stresstest_requests_total{status="connect"}`)
+	assert.Contains(t, body, "stresstest_requests_in_flight 1\n")
+	assert.Contains(t, body, `stresstest_request_duration_seconds_bucket{status="200",phase="total",le="0.025"} 0`+"\n")
+	assert.Contains(t, body, `stresstest_request_duration_seconds_bucket{status="200",phase="total",le="0.05"} 1`+"\n")
+	assert.Contains(t, body, `stresstest_request_duration_seconds_count{status="200",phase="wait"} 1`+"\n")
+	assert.Contains(t, body, `stresstest_request_duration_seconds_count{status="connect",phase="total"} 1`+"\n")
+	assert.Contains(t, body, "stresstest_received_bytes_total 512\n")
+	assert.Contains(t, body, `stresstest_errors_total{class="connection_refused"} 1`+"\n")
+}
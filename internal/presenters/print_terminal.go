@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"stresstest/internal/usecase/run"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -31,6 +32,16 @@ func PrintReport(r run.RunOutputDTO) {
 	fmt.Println("Start:      ", start.Format("02/01/2006 15:04:05"))
 	fmt.Println("End:        ", end.Format("02/01/2006 15:04:05"))
 	fmt.Printf("Duration:   %.2f seconds\n", duration)
+	if r.Degraded {
+		fmt.Println(red("⚠️ Execução degradada: um ou mais agentes falharam"))
+	}
+	if r.Auth != nil {
+		failures := green(fmt.Sprintf("%d", r.Auth.RefreshFailures))
+		if r.Auth.RefreshFailures > 0 {
+			failures = red(fmt.Sprintf("%d (%s)", r.Auth.RefreshFailures, r.Auth.LastRefreshError))
+		}
+		fmt.Printf("Auth:        %s | Token fetches: %d | Refresh failures: %s\n", r.Auth.Type, r.Auth.TokenFetches, failures)
+	}
 
 	// ========== SEPARAR REPORTS ==========
 	var status200 *run.StatusReportDTO
@@ -60,7 +71,7 @@ func PrintReport(r run.RunOutputDTO) {
 		fmt.Println()
 		fmt.Println(bold("✅ Status 200"))
 		printStatusLine(*status200, green)
-	} else {
+	} else if isHTTPReport(r) {
 		fmt.Println()
 		fmt.Println(bold("⚠️ Nenhuma requisição com status 200"))
 	}
@@ -80,6 +91,167 @@ func PrintReport(r run.RunOutputDTO) {
 			printStatusLine(s, colorFunc)
 		}
 	}
+
+	// ========== AQUECIMENTO ==========
+	if r.Warmup != nil {
+		fmt.Println()
+		fmt.Println(bold("🔥 Aquecimento (fora das estatísticas)"))
+		fmt.Printf("Requests: %d | Duration: %dms\n", r.Warmup.Requests, r.Warmup.DurationInMs)
+		for _, s := range r.Warmup.Report {
+			if s.Status == "total" {
+				printStatusLine(s, cyan)
+			}
+		}
+		if r.Warmup.TLS != nil {
+			fmt.Printf("TLS handshakes: %d | Full: %d | Resumed: %d\n", r.Warmup.TLS.Handshakes, r.Warmup.TLS.Full, r.Warmup.TLS.Resumed)
+		}
+	}
+
+	// ========== RETRIES ==========
+	if r.Retries != nil {
+		retries := r.Retries
+		fmt.Println()
+		fmt.Println(bold("♻️ Retries"))
+		fmt.Printf("Requests: %d | Retried: %d | Recovered: %s | Exhausted: %s\n", retries.Requests, retries.Retried, green(retries.Recovered), red(retries.Exhausted))
+		fmt.Printf("Attempts: %d | Per request: %.2f | Max: %d | Retry-After waits: %d\n", retries.Attempts, retries.AttemptsPerRequest, retries.MaxAttempts, retries.RetryAfterWaits)
+		for _, status := range retryStatuses(retries) {
+			fmt.Printf("%s | First attempt: %d | Final: %d\n", cyan("→ Status "+status), retries.FirstAttempt[status], retries.FinalOutcome[status])
+		}
+		for _, outcome := range sortedKeys(retries.RetriedOutcomes) {
+			fmt.Printf("%s | Count: %d\n", yellow("→ "+outcome), retries.RetriedOutcomes[outcome])
+		}
+	}
+
+	// ========== WEBSOCKET ==========
+	if r.WebSocket != nil {
+		ws := r.WebSocket
+		fmt.Println()
+		fmt.Println(bold("🔌 WebSocket"))
+		fmt.Printf("Connections: %d | Failed: %s | Disconnects: %s\n", ws.Connections, red(ws.FailedConnections), red(ws.Disconnects))
+		fmt.Printf("Messages:    Sent: %d | Received: %d | %.2f msg/s\n", ws.MessagesSent, ws.MessagesReceived, ws.MessagesPerSecond)
+		for _, code := range sortedKeys(ws.CloseCodes) {
+			fmt.Printf("%s | Count: %d\n", cyan("→ "+code), ws.CloseCodes[code])
+		}
+	}
+
+	// ========== SOCKET ==========
+	if r.Socket != nil {
+		sock := r.Socket
+		fmt.Println()
+		fmt.Println(bold("🔗 Socket (" + strings.ToUpper(r.Mode) + ")"))
+		fmt.Printf("Connections: %d | Failed: %s\n", sock.Connections, red(sock.FailedConnections))
+		fmt.Printf("Bytes:       Sent: %d | Received: %d | %.2f bytes/s\n", sock.BytesSent, sock.BytesReceived, sock.BytesPerSecond)
+	}
+
+	// ========== GRPC ==========
+	if r.GRPC != nil {
+		fmt.Println()
+		fmt.Println(bold("🛰️ gRPC"))
+		fmt.Printf("Method:      %s (%s)\n", cyan(r.GRPC.Method), r.GRPC.CallType)
+		fmt.Printf("Messages:    Sent: %d | Received: %d\n", r.GRPC.MessagesSent, r.GRPC.MessagesReceived)
+	}
+
+	// ========== GRAPHQL ==========
+	if r.GraphQL != nil {
+		gql := r.GraphQL
+		fmt.Println()
+		fmt.Println(bold("🧬 GraphQL"))
+		fmt.Printf("Operation:   %s | Responses: %d | With errors: %s\n", cyan(gql.Operation), gql.Responses, red(gql.WithErrors))
+		for _, class := range sortedKeys(gql.ErrorClasses) {
+			fmt.Printf("%s | Count: %d\n", red("→ "+class), gql.ErrorClasses[class])
+		}
+	}
+
+	// ========== SSE ==========
+	if r.SSE != nil {
+		sse := r.SSE
+		fmt.Println()
+		fmt.Println(bold("📡 SSE"))
+		fmt.Printf("Streams:     %d | Failed: %s | Reconnects: %d | Drops: %s\n", sse.Streams, red(sse.FailedConnections), sse.Reconnects, red(sse.Drops))
+		fmt.Printf("Events:      %d | %.2f events/s\n", sse.EventsReceived, sse.EventsPerSecond)
+		printPercentiles("First event:", sse.TimeToFirstEvent)
+		printPercentiles("Event gap:  ", sse.EventGap)
+		for _, eventType := range sortedKeys(sse.EventTypes) {
+			fmt.Printf("%s | Count: %d\n", cyan("→ "+eventType), sse.EventTypes[eventType])
+		}
+	}
+
+	// ========== PROTOCOL ==========
+	if r.Protocol != nil {
+		fmt.Println()
+		fmt.Println(bold("🔀 Protocol"))
+		fmt.Printf("Connections: %d | Streams per connection: avg %.2f, max %d\n",
+			r.Protocol.Connections, r.Protocol.AvgStreamsPerConnection, r.Protocol.MaxStreamsPerConnection)
+		for _, protocol := range sortedKeys(r.Protocol.Negotiated) {
+			fmt.Printf("%s | Count: %d\n", cyan("→ "+protocol), r.Protocol.Negotiated[protocol])
+		}
+	}
+
+	// ========== QUIC ==========
+	if r.QUIC != nil {
+		q := r.QUIC
+		fmt.Println()
+		fmt.Println(bold("⚡ QUIC"))
+		fmt.Printf("Connections: %d | Failed: %s | 0-RTT: %s | Migrations: %d\n", q.Connections, red(q.FailedConnections), green(q.Used0RTT), q.Migrations)
+		fmt.Printf("Packets:     Sent: %d | Lost: %s (%d bytes)\n", q.PacketsSent, red(q.PacketsLost), q.BytesLost)
+		printPercentiles("Handshake:  ", q.HandshakeTime)
+	}
+
+	// ========== AGENTS ==========
+	if len(r.Agents) > 0 {
+		fmt.Println()
+		fmt.Println(bold("🤝 Agents"))
+		for _, agent := range r.Agents {
+			status := green(agent.Status)
+			if agent.Status != run.AgentOK {
+				status = red(fmt.Sprintf("%s (%s)", agent.Status, agent.Error))
+			}
+			fmt.Printf("%s | Requests: %d | Concurrency: %d | %s\n", cyan("→ "+agent.Address), agent.Requests, agent.Concurrency, status)
+		}
+	}
+
+	// ========== THRESHOLDS ==========
+	if len(r.Thresholds) > 0 {
+		fmt.Println()
+		fmt.Println(bold("🎯 Thresholds"))
+		for _, threshold := range r.Thresholds {
+			result := green("✓ " + threshold.Threshold)
+			if !threshold.Passed {
+				result = red("✗ " + threshold.Threshold)
+			}
+			fmt.Printf("%s | %s\n", result, ThresholdMessage(threshold))
+		}
+	}
+
+	// ========== TRACES ==========
+	if r.Traces != nil {
+		fmt.Println()
+		fmt.Println(bold("🔎 Traces"))
+		fmt.Println("Slowest:")
+		for _, trace := range r.Traces.Slowest {
+			fmt.Printf("%s | Status: %s | Duration: %dms | Start: %s\n", cyan("→ "+trace.TraceId), trace.Status, trace.DurationInMs, trace.RequestStartTimestamp)
+		}
+		if len(r.Traces.Failed) > 0 {
+			fmt.Println("Failed:")
+			for _, trace := range r.Traces.Failed {
+				fmt.Printf("%s | Status: %s | Error: %s | Duration: %dms\n", cyan("→ "+trace.TraceId), trace.Status, red(trace.Error), trace.DurationInMs)
+			}
+		}
+	}
+
+	// ========== TLS ==========
+	if r.TLS != nil {
+		fmt.Println()
+		fmt.Println(bold("🔒 TLS"))
+		fmt.Printf("Handshakes: %d | Full: %d | Resumed: %s | Failed: %s\n",
+			r.TLS.Handshakes, r.TLS.Full, green(r.TLS.Resumed), red(r.TLS.Failed))
+		for _, version := range sortedKeys(r.TLS.Versions) {
+			fmt.Printf("%s | Count: %d\n", cyan("→ "+version), r.TLS.Versions[version])
+		}
+		for _, suite := range sortedKeys(r.TLS.CipherSuites) {
+			fmt.Printf("%s | Count: %d\n", cyan("→ "+suite), r.TLS.CipherSuites[suite])
+		}
+	}
 }
 
 func printStatusLine(s run.StatusReportDTO, colorFunc func(a ...interface{}) string) {
@@ -91,4 +263,115 @@ func printStatusLine(s run.StatusReportDTO, colorFunc func(a ...interface{}) str
 		s.TotalTime,
 		s.AverageTime,
 	)
+	if s.Percentiles != nil {
+		printPercentiles("            ", *s.Percentiles)
+	}
+	if s.CorrectedPercentiles != nil {
+		printPercentiles("Corrigido:  ", *s.CorrectedPercentiles)
+	}
+}
+
+func printPercentiles(label string, p run.PercentilesDTO) {
+	fmt.Printf("%s p50: %.2fms | p90: %.2fms | p95: %.2fms | p99: %.2fms\n", label, p.P50, p.P90, p.P95, p.P99)
+}
+
+func PrintReplayComparison(r run.ReplayOutputDTO) {
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	fmt.Println()
+	fmt.Println(bold("🔁 Replay"))
+	fmt.Println("Log:        ", r.LogFile)
+	fmt.Println("Skipped:    ", r.SkippedLines, "linhas")
+	if r.KeepTiming {
+		fmt.Printf("Timing:      original (%.2fx)\n", r.Speed)
+	} else {
+		fmt.Println("Timing:      o mais rápido possível")
+	}
+
+	c := r.Comparison
+	if c.Compared == 0 {
+		fmt.Println(bold("⚠️ Nenhuma requisição do log tinha latência registrada"))
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(bold("⏱️ Latência registrada x replay"))
+	fmt.Printf("Compared:    %d | Same status: %d (%.2f%%)\n", c.Compared, c.StatusMatches, 100*float64(c.StatusMatches)/float64(c.Compared))
+	printStatusLine(c.Recorded, cyan)
+	printStatusLine(c.Replayed, cyan)
+	delta := fmt.Sprintf("%+.2fms", c.AverageDeltaInMs)
+	if c.AverageDeltaInMs > 0 {
+		delta = red(delta)
+	} else {
+		delta = green(delta)
+	}
+	fmt.Println("Avg delta:  ", delta)
+}
+
+// retryStatuses returns the statuses requests got on their first or last attempt, sorted
+func retryStatuses(r *run.RetryReportDTO) []string {
+	statuses := make(map[string]int)
+	for status := range r.FirstAttempt {
+		statuses[status]++
+	}
+	for status := range r.FinalOutcome {
+		statuses[status]++
+	}
+	return sortedKeys(statuses)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isHTTPReport tells if the report has a status per HTTP status code
+func isHTTPReport(r run.RunOutputDTO) bool {
+	return r.Mode == "" || r.Mode == run.ModeHTTP || r.Mode == run.ModeGraphQL
+}
+
+func PrintCapacityReport(r run.CapacityOutputDTO) {
+	bold := color.New(color.Bold).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+
+	fmt.Println()
+	fmt.Println(bold("📈 Capacidade"))
+	fmt.Println("URL:        ", r.Url)
+	fmt.Println("Strategy:   ", r.Strategy)
+	fmt.Println("SLO:        ", strings.Join(r.SLO, ", "))
+
+	fmt.Println()
+	fmt.Printf("%-12s %-10s %-12s %-12s %-12s %s\n", "Concurrency", "Requests", "Req/s", "P95", "Error rate", "SLO")
+	for _, step := range r.Steps {
+		result := green("✓")
+		if !step.Passed {
+			var breached []string
+			for _, threshold := range step.Thresholds {
+				if !threshold.Passed {
+					breached = append(breached, threshold.Threshold)
+				}
+			}
+			result = red("✗ " + strings.Join(breached, ", "))
+		}
+		fmt.Printf("%-12d %-10d %-12.2f %-12s %-12s %s\n", step.Concurrency, step.Requests, step.Throughput,
+			fmt.Sprintf("%.2fms", step.P95), fmt.Sprintf("%.2f%%", 100*step.ErrorRate), result)
+	}
+
+	fmt.Println()
+	switch {
+	case r.MaxConcurrency == 0:
+		fmt.Println(bold(red("⚠️ Nenhum nível respeitou o SLO")))
+	case !r.Breached:
+		fmt.Println(bold(green(fmt.Sprintf("✅ O SLO foi respeitado até a concorrência máxima testada: %d", r.MaxConcurrency))))
+	default:
+		fmt.Println(bold(green(fmt.Sprintf("✅ Maior concorrência dentro do SLO: %d", r.MaxConcurrency))))
+	}
 }
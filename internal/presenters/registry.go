@@ -0,0 +1,87 @@
+package presenters
+
+import (
+	"errors"
+	"sort"
+	"stresstest/internal/samples"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"sync"
+)
+
+const (
+	ErrInvalidOutput = "invalid output, use format=path, like json=report.json"
+	ErrUnknownOutput = "unknown output format"
+)
+
+// Format is a way to write a run to a file, selected with --out format=path.
+// Formats that stream the measurements open the file before the run, the others write the final report
+type Format struct {
+	// Stream opens the file and returns the observer that writes every measurement to it and the func that closes it
+	Stream func(path string) (observer run.Observer, close func() error, err error)
+	// Save writes the final report to the file
+	Save func(report run.RunOutputDTO, path string) error
+}
+
+var (
+	formatsMu sync.Mutex
+	formats   = map[string]Format{
+		"json":   {Save: SaveReportAsJSON},
+		"md":     {Save: SaveReportAsMarkdown},
+		"html":   {Save: SaveReportAsHTML},
+		"junit":  {Save: SaveReportAsJUnit},
+		"csv":    {Stream: streamSamples(samples.FormatCSV)},
+		"ndjson": {Stream: streamSamples(samples.FormatNDJSON)},
+	}
+)
+
+// Register adds a format, or replaces the one with the same name
+func Register(name string, format Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[name] = format
+}
+
+// Formats returns the names of the formats registered, sorted
+func Formats() []string {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Output is a file a run is written to
+type Output struct {
+	Name   string
+	Path   string
+	Format Format
+}
+
+// ParseOutput reads an output like json=report.json, looking its format up among the registered ones
+func ParseOutput(spec string) (Output, error) {
+	name, path, ok := strings.Cut(spec, "=")
+	if !ok || name == "" || path == "" {
+		return Output{}, errors.New(ErrInvalidOutput)
+	}
+	formatsMu.Lock()
+	format, exists := formats[name]
+	formatsMu.Unlock()
+	if !exists {
+		return Output{}, errors.New(ErrUnknownOutput + ", must be one of " + strings.Join(Formats(), ", "))
+	}
+	return Output{Name: name, Path: path, Format: format}, nil
+}
+
+func streamSamples(format string) func(path string) (run.Observer, func() error, error) {
+	return func(path string) (run.Observer, func() error, error) {
+		writer, err := samples.CreateAs(path, format)
+		if err != nil {
+			return run.Observer{}, nil, err
+		}
+		return writer.Observer(), writer.Close, nil
+	}
+}
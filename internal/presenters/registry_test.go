@@ -0,0 +1,70 @@
+package presenters_test
+
+import (
+	"path/filepath"
+	"stresstest/internal/presenters"
+	"stresstest/internal/usecase/run"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MustParseOutputsOfRegisteredFormats(t *testing.T) {
+	// Act
+	json, err := presenters.ParseOutput("json=report.json")
+	assert.NoError(t, err)
+	csv, err := presenters.ParseOutput("csv=out/samples=1.csv")
+	assert.NoError(t, err)
+	_, invalid := presenters.ParseOutput("report.json")
+	_, unknown := presenters.ParseOutput("xlsx=report.xlsx")
+
+	// Assert
+	assert.Equal(t, "report.json", json.Path)
+	assert.NotNil(t, json.Format.Save)
+	assert.Nil(t, json.Format.Stream)
+	assert.Equal(t, "out/samples=1.csv", csv.Path)
+	assert.NotNil(t, csv.Format.Stream)
+	assert.EqualError(t, invalid, presenters.ErrInvalidOutput)
+	assert.ErrorContains(t, unknown, presenters.ErrUnknownOutput)
+	assert.ErrorContains(t, unknown, "csv, html, json, junit, md, ndjson")
+}
+
+func Test_MustPlugNewFormatsIntoTheRegistry(t *testing.T) {
+	// Arrange
+	var saved string
+	presenters.Register("ids", presenters.Format{Save: func(report run.RunOutputDTO, path string) error {
+		saved = report.Id + "@" + path
+		return nil
+	}})
+
+	// Act
+	out, err := presenters.ParseOutput("ids=ids.txt")
+	assert.NoError(t, err)
+	err = out.Format.Save(run.RunOutputDTO{Id: "abc"}, out.Path)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "abc@ids.txt", saved)
+	assert.Contains(t, presenters.Formats(), "ids")
+}
+
+func Test_MustSaveOutputsAtTheExactPathGiven(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	jsonOut, err := presenters.ParseOutput("json=" + filepath.Join(dir, "report.out"))
+	assert.NoError(t, err)
+	junitOut, err := presenters.ParseOutput("junit=" + filepath.Join(dir, "x"))
+	assert.NoError(t, err)
+
+	// Act
+	errJSON := jsonOut.Format.Save(run.RunOutputDTO{Id: "abc"}, jsonOut.Path)
+	errJUnit := junitOut.Format.Save(run.RunOutputDTO{Id: "abc"}, junitOut.Path)
+
+	// Assert
+	assert.NoError(t, errJSON)
+	assert.NoError(t, errJUnit)
+	assert.FileExists(t, filepath.Join(dir, "report.out"))
+	assert.FileExists(t, filepath.Join(dir, "x"))
+	assert.NoFileExists(t, filepath.Join(dir, "report.out.json"))
+	assert.NoFileExists(t, filepath.Join(dir, "x.xml"))
+}
@@ -0,0 +1,105 @@
+package presenters
+
+import (
+	"html/template"
+	"os"
+	"sort"
+	"stresstest/internal/usecase/run"
+	"strings"
+)
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{"thresholdMessage": ThresholdMessage, "retryStatuses": retryStatuses}).Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>Stress Test Report {{.Id}}</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+table { border-collapse: collapse; margin-top: 1rem; }
+th, td { border: 1px solid #ccc; padding: 0.3rem 0.6rem; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.degraded { color: #b00; }
+</style>
+</head>
+<body>
+<h1>📊 Stress Test Report</h1>
+<p><strong>ID:</strong> <code>{{.Id}}</code><br>
+<strong>URL:</strong> {{.Url}}<br>
+<strong>Mode:</strong> {{.Mode}}<br>
+<strong>Requests:</strong> {{.Requests}}<br>
+<strong>Concurrency:</strong> {{.Concurrency}}<br>
+<strong>Start:</strong> {{.TimestampStart}}<br>
+<strong>End:</strong> {{.TimestampEnd}}</p>
+{{if .Degraded}}<p class="degraded">⚠️ Execução degradada: um ou mais agentes falharam</p>{{end}}
+{{$corrected := false}}{{range .Report}}{{if .CorrectedPercentiles}}{{$corrected = true}}{{end}}{{end -}}
+<table>
+<tr><th>Status</th><th>Count</th><th>Min Time</th><th>Max Time</th><th>Average Time</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th>
+{{- if $corrected}}<th>p50 corrigido</th><th>p90 corrigido</th><th>p95 corrigido</th><th>p99 corrigido</th>{{end}}</tr>
+{{range .Report}}<tr><td>{{.Status}}</td><td>{{.Count}}</td><td>{{.MinTime}}ms</td><td>{{.MaxTime}}ms</td><td>{{printf "%.2f" .AverageTime}}ms</td>
+{{- with .Percentiles}}<td>{{printf "%.2f" .P50}}ms</td><td>{{printf "%.2f" .P90}}ms</td><td>{{printf "%.2f" .P95}}ms</td><td>{{printf "%.2f" .P99}}ms</td>{{else}}<td></td><td></td><td></td><td></td>{{end}}
+{{- if $corrected}}{{with .CorrectedPercentiles}}<td>{{printf "%.2f" .P50}}ms</td><td>{{printf "%.2f" .P90}}ms</td><td>{{printf "%.2f" .P95}}ms</td><td>{{printf "%.2f" .P99}}ms</td>{{else}}<td></td><td></td><td></td><td></td>{{end}}{{end}}</tr>
+{{end}}</table>
+{{with .Warmup}}<h2>🔥 Aquecimento</h2>
+<p>Fora das estatísticas acima: <strong>{{.Requests}}</strong> requests em <strong>{{.DurationInMs}}ms</strong></p>
+<table>
+<tr><th>Status</th><th>Count</th><th>Min Time</th><th>Max Time</th><th>Average Time</th></tr>
+{{range .Report}}<tr><td>{{.Status}}</td><td>{{.Count}}</td><td>{{.MinTime}}ms</td><td>{{.MaxTime}}ms</td><td>{{printf "%.2f" .AverageTime}}ms</td></tr>
+{{end}}</table>
+{{end}}{{with .Retries}}<h2>♻️ Retries</h2>
+<table>
+<tr><th>Requests</th><th>Retried</th><th>Recovered</th><th>Exhausted</th><th>Attempts</th><th>Attempts/Request</th><th>Max Attempts</th><th>Retry-After Waits</th></tr>
+<tr><td>{{.Requests}}</td><td>{{.Retried}}</td><td>{{.Recovered}}</td><td>{{.Exhausted}}</td><td>{{.Attempts}}</td><td>{{printf "%.2f" .AttemptsPerRequest}}</td><td>{{.MaxAttempts}}</td><td>{{.RetryAfterWaits}}</td></tr>
+</table>
+<table>
+<tr><th>Status</th><th>First Attempt</th><th>Final Outcome</th></tr>
+{{$retries := .}}{{range retryStatuses .}}<tr><td>{{.}}</td><td>{{index $retries.FirstAttempt .}}</td><td>{{index $retries.FinalOutcome .}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Agents}}<h2>🤝 Agents</h2>
+<table>
+<tr><th>Address</th><th>Requests</th><th>Concurrency</th><th>Status</th><th>Error</th></tr>
+{{range .Agents}}<tr><td>{{.Address}}</td><td>{{.Requests}}</td><td>{{.Concurrency}}</td><td>{{.Status}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Thresholds}}<h2>🎯 Thresholds</h2>
+<table>
+<tr><th>Threshold</th><th>Result</th><th>Details</th></tr>
+{{range .Thresholds}}<tr><td><code>{{.Threshold}}</code></td><td>{{if .Passed}}✅ passed{{else}}❌ failed{{end}}</td><td>{{thresholdMessage .}}</td></tr>
+{{end}}</table>
+{{end}}{{with .Traces}}<h2>🔎 Traces</h2>
+<table>
+<tr><th>Slowest Trace ID</th><th>Status</th><th>Error</th><th>Duration</th><th>Start</th></tr>
+{{range .Slowest}}<tr><td><code>{{.TraceId}}</code></td><td>{{.Status}}</td><td>{{.Error}}</td><td>{{.DurationInMs}}ms</td><td>{{.RequestStartTimestamp}}</td></tr>
+{{end}}</table>
+{{if .Failed}}<table>
+<tr><th>Failed Trace ID</th><th>Status</th><th>Error</th><th>Duration</th><th>Start</th></tr>
+{{range .Failed}}<tr><td><code>{{.TraceId}}</code></td><td>{{.Status}}</td><td>{{.Error}}</td><td>{{.DurationInMs}}ms</td><td>{{.RequestStartTimestamp}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// ToHTML renders the report as a standalone HTML page, with the total first and the other statuses sorted
+func ToHTML(r run.RunOutputDTO) (string, error) {
+	report := make([]run.StatusReportDTO, len(r.Report))
+	copy(report, r.Report)
+	sort.SliceStable(report, func(i, j int) bool {
+		if report[i].Status == "total" || report[j].Status == "total" {
+			return report[i].Status == "total"
+		}
+		return report[i].Status < report[j].Status
+	})
+	r.Report = report
+
+	var html strings.Builder
+	if err := htmlReport.Execute(&html, r); err != nil {
+		return "", err
+	}
+	return html.String(), nil
+}
+
+func SaveReportAsHTML(report run.RunOutputDTO, filePath string) error {
+	html, err := ToHTML(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(html), 0644)
+}
@@ -7,12 +7,21 @@ import (
 )
 
 func SaveReportAsJSON(report run.RunOutputDTO, filePath string) error {
+	return saveAsJSON(report, filePath)
+}
+
+func SaveReplayAsJSON(report run.ReplayOutputDTO, filePath string) error {
+	return saveAsJSON(report, filePath)
+}
+
+func SaveCapacityAsJSON(report run.CapacityOutputDTO, filePath string) error {
+	return saveAsJSON(report, filePath)
+}
+
+func saveAsJSON(report interface{}, filePath string) error {
 	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return err
 	}
-	if ".json" != filePath[len(filePath)-5:] {
-		filePath += ".json"
-	}
 	return os.WriteFile(filePath, data, 0644)
 }
@@ -0,0 +1,112 @@
+package presenters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"stresstest/internal/usecase/run"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ToJUnit renders the thresholds of the report as a JUnit XML test suite, one testcase each,
+// with the metadata of the run as properties
+func ToJUnit(r run.RunOutputDTO) (string, error) {
+	suite := junitTestSuite{
+		Name:      "stresstest " + r.Url,
+		Tests:     len(r.Thresholds),
+		Time:      strconv.Itoa(r.TestDurationInSeconds),
+		Timestamp: strings.Replace(r.TimestampStart, " ", "T", 1),
+		Properties: []junitProperty{
+			{Name: "id", Value: r.Id},
+			{Name: "url", Value: r.Url},
+			{Name: "mode", Value: r.Mode},
+			{Name: "method", Value: r.Method},
+			{Name: "requests", Value: strconv.Itoa(r.Requests)},
+			{Name: "concurrency", Value: strconv.Itoa(r.Concurrency)},
+			{Name: "timestamp_start", Value: r.TimestampStart},
+			{Name: "timestamp_end", Value: r.TimestampEnd},
+			{Name: "test_duration_in_seconds", Value: strconv.Itoa(r.TestDurationInSeconds)},
+			{Name: "degraded", Value: strconv.FormatBool(r.Degraded)},
+		},
+	}
+	for _, threshold := range r.Thresholds {
+		testCase := junitTestCase{Name: threshold.Threshold, ClassName: "stresstest.thresholds", Time: "0"}
+		if !threshold.Passed {
+			suite.Failures++
+			message := ThresholdMessage(threshold)
+			testCase.Failure = &junitFailure{Message: message, Type: "threshold", Text: message}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	suites := junitTestSuites{Name: "stresstest", Tests: suite.Tests, Failures: suite.Failures, Suites: []junitTestSuite{suite}}
+	content, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(content) + "\n", nil
+}
+
+func SaveReportAsJUnit(report run.RunOutputDTO, filePath string) error {
+	content, err := ToJUnit(report)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(content), 0644)
+}
+
+// ThresholdMessage describes the actual value of a threshold against the expected one
+func ThresholdMessage(t run.ThresholdResultDTO) string {
+	expected := fmt.Sprintf("esperado %s %s", t.Operator, formatThresholdValue(t.Metric, t.Expected, -1))
+	if !t.Measured {
+		return fmt.Sprintf("%s sem valor medido, %s", t.Metric, expected)
+	}
+	return fmt.Sprintf("%s = %s, %s", t.Metric, formatThresholdValue(t.Metric, t.Actual, 2), expected)
+}
+
+// formatThresholdValue formats a latency in ms or an error rate in percent, -1 precision keeping every digit
+func formatThresholdValue(metric string, value float64, precision int) string {
+	if metric == run.ThresholdErrorRate {
+		// Rounded so 0.3 isn't shown as 30.000000000000004%
+		return strconv.FormatFloat(math.Round(value*100*1e6)/1e6, 'f', precision, 64) + "%"
+	}
+	return strconv.FormatFloat(value, 'f', precision, 64) + "ms"
+}
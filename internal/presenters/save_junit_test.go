@@ -0,0 +1,131 @@
+package presenters_test
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"stresstest/internal/presenters"
+	"stresstest/internal/usecase/run"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// junitReport is the part of the JUnit XML the tests read
+type junitReport struct {
+	Tests    int `xml:"tests,attr"`
+	Failures int `xml:"failures,attr"`
+	Suite    struct {
+		Tests      int `xml:"tests,attr"`
+		Failures   int `xml:"failures,attr"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"properties>property"`
+		TestCases []struct {
+			Name    string `xml:"name,attr"`
+			Failure *struct {
+				Message string `xml:"message,attr"`
+				Type    string `xml:"type,attr"`
+			} `xml:"failure"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func thresholdsReport() run.RunOutputDTO {
+	return run.RunOutputDTO{
+		Id:          "run-1",
+		Url:         "http://localhost:8080",
+		Mode:        run.ModeHTTP,
+		Method:      "GET",
+		Requests:    100,
+		Concurrency: 10,
+		Thresholds: []run.ThresholdResultDTO{
+			{Threshold: "p95<500", Metric: "p95", Operator: "<", Expected: 500, Actual: 120.5, Measured: true, Passed: true},
+			{Threshold: "error_rate<0.01", Metric: run.ThresholdErrorRate, Operator: "<", Expected: 0.01, Actual: 0.3, Measured: true},
+			{Threshold: "p99<800", Metric: "p99", Operator: "<", Expected: 800},
+		},
+	}
+}
+
+func Test_MustRenderOneTestCasePerThresholdInJUnit(t *testing.T) {
+	// Arrange
+	report := thresholdsReport()
+
+	// Act
+	content, err := presenters.ToJUnit(report)
+	var parsed junitReport
+	parseErr := xml.Unmarshal([]byte(content), &parsed)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, parseErr)
+	assert.Equal(t, 3, parsed.Tests)
+	assert.Equal(t, 2, parsed.Failures)
+	assert.Equal(t, 3, parsed.Suite.Tests)
+	assert.Equal(t, 2, parsed.Suite.Failures)
+	assert.Len(t, parsed.Suite.TestCases, 3)
+	assert.Equal(t, "p95<500", parsed.Suite.TestCases[0].Name)
+	assert.Nil(t, parsed.Suite.TestCases[0].Failure)
+	assert.Equal(t, "error_rate<0.01", parsed.Suite.TestCases[1].Name)
+	assert.Equal(t, "error_rate = 30.00%, esperado < 1%", parsed.Suite.TestCases[1].Failure.Message)
+	assert.Equal(t, "threshold", parsed.Suite.TestCases[1].Failure.Type)
+	assert.Equal(t, "p99<800", parsed.Suite.TestCases[2].Name)
+	assert.Equal(t, "p99 sem valor medido, esperado < 800ms", parsed.Suite.TestCases[2].Failure.Message)
+}
+
+func Test_MustRenderTheRunAsJUnitProperties(t *testing.T) {
+	// Arrange
+	report := thresholdsReport()
+
+	// Act
+	content, _ := presenters.ToJUnit(report)
+	var parsed junitReport
+	xml.Unmarshal([]byte(content), &parsed)
+	properties := map[string]string{}
+	for _, property := range parsed.Suite.Properties {
+		properties[property.Name] = property.Value
+	}
+
+	// Assert
+	assert.Equal(t, "run-1", properties["id"])
+	assert.Equal(t, "http://localhost:8080", properties["url"])
+	assert.Equal(t, run.ModeHTTP, properties["mode"])
+	assert.Equal(t, "GET", properties["method"])
+	assert.Equal(t, "100", properties["requests"])
+	assert.Equal(t, "10", properties["concurrency"])
+	assert.Equal(t, "false", properties["degraded"])
+}
+
+func Test_MustSaveTheJUnitReport(t *testing.T) {
+	// Arrange
+	report := thresholdsReport()
+	filePath := filepath.Join(t.TempDir(), "report.xml")
+
+	// Act
+	err := presenters.SaveReportAsJUnit(report, filePath)
+	saved, readErr := os.ReadFile(filePath)
+	expected, _ := presenters.ToJUnit(report)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, readErr)
+	assert.Equal(t, expected, string(saved))
+}
+
+func Test_MustDescribeTheActualAndExpectedValuesOfAThreshold(t *testing.T) {
+	// Arrange
+	latency := run.ThresholdResultDTO{Metric: "p95", Operator: "<", Expected: 500, Actual: 612.345, Measured: true}
+	errorRate := run.ThresholdResultDTO{Metric: run.ThresholdErrorRate, Operator: "<=", Expected: 0.05, Actual: 0.1, Measured: true}
+	unmeasured := run.ThresholdResultDTO{Metric: "avg", Operator: ">", Expected: 1.5}
+
+	// Act
+	latencyMessage := presenters.ThresholdMessage(latency)
+	errorRateMessage := presenters.ThresholdMessage(errorRate)
+	unmeasuredMessage := presenters.ThresholdMessage(unmeasured)
+
+	// Assert
+	assert.Equal(t, "p95 = 612.35ms, esperado < 500ms", latencyMessage)
+	assert.Equal(t, "error_rate = 10.00%, esperado <= 5%", errorRateMessage)
+	assert.Equal(t, "avg sem valor medido, esperado > 1.5ms", unmeasuredMessage)
+}
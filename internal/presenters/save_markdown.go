@@ -2,12 +2,17 @@ package presenters
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"stresstest/internal/usecase/run"
 	"strings"
 	"time"
 )
 
+func SaveReportAsMarkdown(report run.RunOutputDTO, filePath string) error {
+	return os.WriteFile(filePath, []byte(ToMarkdown(report)), 0644)
+}
+
 func ToMarkdown(r run.RunOutputDTO) string {
 	var markdown strings.Builder
 	md := func(format string, a ...interface{}) {
@@ -30,6 +35,15 @@ func ToMarkdown(r run.RunOutputDTO) string {
 	md("**Start:** %s", start.Format("02/01/2006 15:04:05"))
 	md("**End:** %s", end.Format("02/01/2006 15:04:05"))
 	md("**Duration:** %.2f seconds", duration)
+	if r.Degraded {
+		md("\n⚠️ Execução degradada: um ou mais agentes falharam")
+	}
+	if r.Auth != nil {
+		md("**Auth:** %s | **Token fetches:** %d | **Refresh failures:** %d", r.Auth.Type, r.Auth.TokenFetches, r.Auth.RefreshFailures)
+		if r.Auth.LastRefreshError != "" {
+			md("**Last refresh error:** %s", r.Auth.LastRefreshError)
+		}
+	}
 
 	// Separar os reports
 	var status200 *run.StatusReportDTO
@@ -60,7 +74,7 @@ func ToMarkdown(r run.RunOutputDTO) string {
 		md("| Count | Min Time | Max Time | Total Time | Average Time |")
 		md("|-------|----------|----------|------------|---------------|")
 		md("| %d | %dms | %dms | %dms | %.2fms |", status200.Count, status200.MinTime, status200.MaxTime, status200.TotalTime, status200.AverageTime)
-	} else {
+	} else if isHTTPReport(r) {
 		md("\n⚠️ Nenhuma requisição com status 200")
 	}
 
@@ -77,5 +91,293 @@ func ToMarkdown(r run.RunOutputDTO) string {
 		}
 	}
 
+	// Percentiles of every row that has them
+	if total != nil && total.Percentiles != nil {
+		md("\n### ⏱️ Percentiles")
+		// Corrected percentiles go side by side with the measured ones
+		corrected := total.CorrectedPercentiles != nil
+		if corrected {
+			md("| Status | p50 | p90 | p95 | p99 | p50 corrigido | p90 corrigido | p95 corrigido | p99 corrigido |")
+			md("|--------|-----|-----|-----|-----|---------------|---------------|---------------|---------------|")
+		} else {
+			md("| Status | p50 | p90 | p95 | p99 |")
+			md("|--------|-----|-----|-----|-----|")
+		}
+		rows := []run.StatusReportDTO{*total}
+		if status200 != nil {
+			rows = append(rows, *status200)
+		}
+		for _, s := range append(rows, others...) {
+			p := s.Percentiles
+			if p == nil {
+				continue
+			}
+			row := fmt.Sprintf("| %s | %.2fms | %.2fms | %.2fms | %.2fms |", s.Status, p.P50, p.P90, p.P95, p.P99)
+			if c := s.CorrectedPercentiles; corrected && c != nil {
+				row += fmt.Sprintf(" %.2fms | %.2fms | %.2fms | %.2fms |", c.P50, c.P90, c.P95, c.P99)
+			} else if corrected {
+				row += "  |  |  |  |"
+			}
+			md("%s", row)
+		}
+	}
+
+	// Aquecimento
+	if r.Warmup != nil {
+		md("\n### 🔥 Aquecimento")
+		md("Fora das estatísticas acima: **%d** requests em **%dms**", r.Warmup.Requests, r.Warmup.DurationInMs)
+		md("\n| Status | Count | Min Time | Max Time | Average Time |")
+		md("|--------|-------|----------|----------|--------------|")
+		for _, s := range r.Warmup.Report {
+			md("| %s | %d | %dms | %dms | %.2fms |", s.Status, s.Count, s.MinTime, s.MaxTime, s.AverageTime)
+		}
+	}
+
+	// Retries
+	if r.Retries != nil {
+		retries := r.Retries
+		md("\n### ♻️ Retries")
+		md("| Requests | Retried | Recovered | Exhausted | Attempts | Attempts/Request | Max Attempts | Retry-After Waits |")
+		md("|----------|---------|-----------|-----------|----------|------------------|--------------|-------------------|")
+		md("| %d | %d | %d | %d | %d | %.2f | %d | %d |", retries.Requests, retries.Retried, retries.Recovered, retries.Exhausted,
+			retries.Attempts, retries.AttemptsPerRequest, retries.MaxAttempts, retries.RetryAfterWaits)
+		md("\n| Status | First Attempt | Final Outcome |")
+		md("|--------|---------------|---------------|")
+		for _, status := range retryStatuses(retries) {
+			md("| %s | %d | %d |", status, retries.FirstAttempt[status], retries.FinalOutcome[status])
+		}
+		if len(retries.RetriedOutcomes) > 0 {
+			md("\n| Retried (first → final) | Count |")
+			md("|-------------------------|-------|")
+			for _, outcome := range sortedKeys(retries.RetriedOutcomes) {
+				md("| %s | %d |", outcome, retries.RetriedOutcomes[outcome])
+			}
+		}
+	}
+
+	// WebSocket
+	if r.WebSocket != nil {
+		ws := r.WebSocket
+		md("\n### 🔌 WebSocket")
+		md("| Connections | Failed | Disconnects | Sent | Received | Messages/s |")
+		md("|-------------|--------|-------------|------|----------|------------|")
+		md("| %d | %d | %d | %d | %d | %.2f |", ws.Connections, ws.FailedConnections, ws.Disconnects, ws.MessagesSent, ws.MessagesReceived, ws.MessagesPerSecond)
+		if len(ws.CloseCodes) > 0 {
+			md("\n| Close | Count |")
+			md("|-------|-------|")
+			for _, code := range sortedKeys(ws.CloseCodes) {
+				md("| %s | %d |", code, ws.CloseCodes[code])
+			}
+		}
+	}
+
+	// Socket
+	if r.Socket != nil {
+		sock := r.Socket
+		md("\n### 🔗 Socket (%s)", strings.ToUpper(r.Mode))
+		md("| Connections | Failed | Bytes Sent | Bytes Received | Bytes/s |")
+		md("|-------------|--------|------------|----------------|---------|")
+		md("| %d | %d | %d | %d | %.2f |", sock.Connections, sock.FailedConnections, sock.BytesSent, sock.BytesReceived, sock.BytesPerSecond)
+	}
+
+	// gRPC
+	if r.GRPC != nil {
+		md("\n### 🛰️ gRPC")
+		md("| Method | Call Type | Sent | Received |")
+		md("|--------|-----------|------|----------|")
+		md("| %s | %s | %d | %d |", r.GRPC.Method, r.GRPC.CallType, r.GRPC.MessagesSent, r.GRPC.MessagesReceived)
+	}
+
+	// GraphQL
+	if r.GraphQL != nil {
+		gql := r.GraphQL
+		md("\n### 🧬 GraphQL")
+		md("| Operation | Responses | With Errors |")
+		md("|-----------|-----------|-------------|")
+		md("| %s | %d | %d |", gql.Operation, gql.Responses, gql.WithErrors)
+		if len(gql.ErrorClasses) > 0 {
+			md("\n| Error Class | Count |")
+			md("|-------------|-------|")
+			for _, class := range sortedKeys(gql.ErrorClasses) {
+				md("| %s | %d |", class, gql.ErrorClasses[class])
+			}
+		}
+	}
+
+	// SSE
+	if r.SSE != nil {
+		sse := r.SSE
+		md("\n### 📡 SSE")
+		md("| Streams | Failed | Reconnects | Drops | Events | Events/s |")
+		md("|---------|--------|------------|-------|--------|----------|")
+		md("| %d | %d | %d | %d | %d | %.2f |", sse.Streams, sse.FailedConnections, sse.Reconnects, sse.Drops, sse.EventsReceived, sse.EventsPerSecond)
+		md("\n| Measurement | p50 | p90 | p95 | p99 |")
+		md("|-------------|-----|-----|-----|-----|")
+		for _, row := range []struct {
+			name        string
+			percentiles run.PercentilesDTO
+		}{{"Time to first event", sse.TimeToFirstEvent}, {"Event gap", sse.EventGap}} {
+			p := row.percentiles
+			md("| %s | %.2fms | %.2fms | %.2fms | %.2fms |", row.name, p.P50, p.P90, p.P95, p.P99)
+		}
+		if len(sse.EventTypes) > 0 {
+			md("\n| Event | Count |")
+			md("|-------|-------|")
+			for _, eventType := range sortedKeys(sse.EventTypes) {
+				md("| %s | %d |", eventType, sse.EventTypes[eventType])
+			}
+		}
+	}
+
+	// Protocol
+	if r.Protocol != nil {
+		md("\n### 🔀 Protocol")
+		md("| Connections | Avg Streams/Connection | Max Streams/Connection |")
+		md("|-------------|------------------------|------------------------|")
+		md("| %d | %.2f | %d |", r.Protocol.Connections, r.Protocol.AvgStreamsPerConnection, r.Protocol.MaxStreamsPerConnection)
+		md("\n| Negotiated | Count |")
+		md("|------------|-------|")
+		for _, protocol := range sortedKeys(r.Protocol.Negotiated) {
+			md("| %s | %d |", protocol, r.Protocol.Negotiated[protocol])
+		}
+	}
+
+	// QUIC
+	if r.QUIC != nil {
+		q := r.QUIC
+		md("\n### ⚡ QUIC")
+		md("| Connections | Failed | 0-RTT | Migrations | Packets Sent | Packets Lost | Bytes Lost |")
+		md("|-------------|--------|-------|------------|--------------|--------------|------------|")
+		md("| %d | %d | %d | %d | %d | %d | %d |", q.Connections, q.FailedConnections, q.Used0RTT, q.Migrations, q.PacketsSent, q.PacketsLost, q.BytesLost)
+		md("\n| Handshake p50 | p90 | p95 | p99 |")
+		md("|---------------|-----|-----|-----|")
+		md("| %.2fms | %.2fms | %.2fms | %.2fms |", q.HandshakeTime.P50, q.HandshakeTime.P90, q.HandshakeTime.P95, q.HandshakeTime.P99)
+	}
+
+	// Agents
+	if len(r.Agents) > 0 {
+		md("\n### 🤝 Agents")
+		md("| Address | Requests | Concurrency | Status | Error |")
+		md("|---------|----------|-------------|--------|-------|")
+		for _, agent := range r.Agents {
+			md("| %s | %d | %d | %s | %s |", agent.Address, agent.Requests, agent.Concurrency, agent.Status, agent.Error)
+		}
+	}
+
+	// Thresholds
+	if len(r.Thresholds) > 0 {
+		md("\n### 🎯 Thresholds")
+		md("| Threshold | Result | Details |")
+		md("|-----------|--------|---------|")
+		for _, threshold := range r.Thresholds {
+			result := "✅ passed"
+			if !threshold.Passed {
+				result = "❌ failed"
+			}
+			md("| `%s` | %s | %s |", threshold.Threshold, result, ThresholdMessage(threshold))
+		}
+	}
+
+	// Traces
+	if r.Traces != nil {
+		md("\n### 🔎 Traces")
+		md("| Trace ID | Status | Error | Duration | Start |")
+		md("|----------|--------|-------|----------|-------|")
+		for _, trace := range r.Traces.Slowest {
+			md("| `%s` | %s | %s | %dms | %s |", trace.TraceId, trace.Status, trace.Error, trace.DurationInMs, trace.RequestStartTimestamp)
+		}
+		if len(r.Traces.Failed) > 0 {
+			md("\n| Failed Trace ID | Status | Error | Duration | Start |")
+			md("|-----------------|--------|-------|----------|-------|")
+			for _, trace := range r.Traces.Failed {
+				md("| `%s` | %s | %s | %dms | %s |", trace.TraceId, trace.Status, trace.Error, trace.DurationInMs, trace.RequestStartTimestamp)
+			}
+		}
+	}
+
+	// TLS
+	if r.TLS != nil {
+		md("\n### 🔒 TLS")
+		md("| Handshakes | Full | Resumed | Failed |")
+		md("|------------|------|---------|--------|")
+		md("| %d | %d | %d | %d |", r.TLS.Handshakes, r.TLS.Full, r.TLS.Resumed, r.TLS.Failed)
+		md("\n| Negotiated | Count |")
+		md("|------------|-------|")
+		for _, version := range sortedKeys(r.TLS.Versions) {
+			md("| %s | %d |", version, r.TLS.Versions[version])
+		}
+		for _, suite := range sortedKeys(r.TLS.CipherSuites) {
+			md("| %s | %d |", suite, r.TLS.CipherSuites[suite])
+		}
+	}
+
+	return markdown.String()
+}
+
+func ReplayToMarkdown(r run.ReplayOutputDTO) string {
+	var markdown strings.Builder
+	markdown.WriteString(ToMarkdown(r.RunOutputDTO))
+	md := func(format string, a ...interface{}) {
+		markdown.WriteString(fmt.Sprintf(format, a...))
+		markdown.WriteString("\n")
+	}
+
+	md("\n### 🔁 Replay")
+	md("**Log:** %s", r.LogFile)
+	md("**Skipped lines:** %d", r.SkippedLines)
+	if r.KeepTiming {
+		md("**Timing:** original (%.2fx)", r.Speed)
+	} else {
+		md("**Timing:** o mais rápido possível")
+	}
+
+	c := r.Comparison
+	if c.Compared == 0 {
+		md("\n⚠️ Nenhuma requisição do log tinha latência registrada")
+		return markdown.String()
+	}
+
+	md("\n### ⏱️ Latência registrada x replay")
+	md("**Compared:** %d | **Same status:** %d", c.Compared, c.StatusMatches)
+	md("| Source | Count | Min Time | Max Time | Total Time | Average Time |")
+	md("|--------|-------|----------|----------|------------|---------------|")
+	for _, s := range []run.StatusReportDTO{c.Recorded, c.Replayed} {
+		md("| %s | %d | %dms | %dms | %dms | %.2fms |", s.Status, s.Count, s.MinTime, s.MaxTime, s.TotalTime, s.AverageTime)
+	}
+	md("\n**Average delta:** %+.2fms", c.AverageDeltaInMs)
+
+	return markdown.String()
+}
+
+func CapacityToMarkdown(r run.CapacityOutputDTO) string {
+	var markdown strings.Builder
+	md := func(format string, a ...interface{}) {
+		markdown.WriteString(fmt.Sprintf(format, a...))
+		markdown.WriteString("\n")
+	}
+
+	md("## 📈 Capacidade")
+	md("**URL:** %s", r.Url)
+	md("**Strategy:** %s", r.Strategy)
+	md("**SLO:** %s", strings.Join(r.SLO, ", "))
+	switch {
+	case r.MaxConcurrency == 0:
+		md("**Max concurrency:** nenhum nível respeitou o SLO")
+	case !r.Breached:
+		md("**Max concurrency:** %d (SLO respeitado até o máximo testado)", r.MaxConcurrency)
+	default:
+		md("**Max concurrency:** %d", r.MaxConcurrency)
+	}
+
+	md("\n| Concurrency | Requests | Req/s | P95 | Error rate | SLO |")
+	md("|-------------|----------|-------|-----|------------|-----|")
+	for _, step := range r.Steps {
+		result := "✅"
+		if !step.Passed {
+			result = "❌"
+		}
+		md("| %d | %d | %.2f | %.2fms | %.2f%% | %s |", step.Concurrency, step.Requests, step.Throughput, step.P95, 100*step.ErrorRate, result)
+	}
+
 	return markdown.String()
 }
@@ -0,0 +1,24 @@
+package presenters_test
+
+import (
+	"stresstest/internal/presenters"
+	"stresstest/internal/usecase/run"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MustRenderTheSocketSectionInMarkdown(t *testing.T) {
+	// Arrange
+	report := run.RunOutputDTO{
+		Mode:   run.ModeTCP,
+		Socket: &run.SocketReportDTO{Connections: 3, FailedConnections: 1, BytesSent: 30, BytesReceived: 12, BytesPerSecond: 6},
+	}
+
+	// Act
+	markdown := presenters.ToMarkdown(report)
+
+	// Assert
+	assert.Contains(t, markdown, "### 🔗 Socket (TCP)")
+	assert.Contains(t, markdown, "| 3 | 1 | 30 | 12 | 6.00 |")
+}
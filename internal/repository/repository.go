@@ -2,23 +2,69 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"stresstest/internal/entity"
+	"sync"
 )
 
+const ErrTestRunNotFound = "test run not found"
+
 // RepositoryInterface is an interface for the repository layer
-// This project will not implement it, but it is here to show how it could be done
-// This will allow future implementations to be easily swapped
+// This project only keeps test runs in memory, but other implementations can be easily swapped in
 
 type RepositoryInterface interface {
 	Save(ctx context.Context, testRun *entity.TestRun) error
+	FindAll(ctx context.Context) ([]*entity.TestRun, error)
+	FindByID(ctx context.Context, id string) (*entity.TestRun, error)
+	Delete(ctx context.Context, id string) error
 }
 
-type Repository struct{}
+// Repository keeps the test runs of the process in memory, in the order they were saved
+type Repository struct {
+	mu   sync.Mutex
+	runs []*entity.TestRun
+}
 
-func NewRepository() Repository {
-	return Repository{}
+func NewRepository() *Repository {
+	return &Repository{}
 }
 
 func (r *Repository) Save(ctx context.Context, testRun *entity.TestRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, testRun)
 	return nil
 }
+
+func (r *Repository) FindAll(ctx context.Context) ([]*entity.TestRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := make([]*entity.TestRun, len(r.runs))
+	copy(runs, r.runs)
+	return runs, nil
+}
+
+func (r *Repository) FindByID(ctx context.Context, id string) (*entity.TestRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, testRun := range r.runs {
+		if testRun.Id == id {
+			return testRun, nil
+		}
+	}
+	return nil, errors.New(ErrTestRunNotFound)
+}
+
+// Delete removes a test run, so a process that runs many tests only keeps the ones still needed
+func (r *Repository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, testRun := range r.runs {
+		if testRun.Id == id {
+			r.runs = slices.Delete(r.runs, i, i+1)
+			return nil
+		}
+	}
+	return errors.New(ErrTestRunNotFound)
+}
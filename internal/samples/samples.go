@@ -0,0 +1,195 @@
+package samples
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"stresstest/internal/usecase/run"
+	"sync"
+	"time"
+)
+
+const ErrUnknownSamplesFormat = "unknown samples format, the file must end with .csv, .ndjson or .jsonl"
+
+// Columns of the CSV, in the order they're written
+var Columns = []string{
+	"timestamp_start", "timestamp_end", "status", "request", "duration_in_ms", "error_class",
+	"dns_in_ms", "connect_in_ms", "tls_in_ms", "wait_in_ms", "bytes_received", "trace_id",
+}
+
+// Sample is a measurement as written to the NDJSON, phases the measurement didn't go through are null
+type Sample struct {
+	TimestampStart string   `json:"timestamp_start"`
+	TimestampEnd   string   `json:"timestamp_end"`
+	Status         string   `json:"status"`
+	Request        bool     `json:"request"`
+	DurationInMs   float64  `json:"duration_in_ms"`
+	ErrorClass     string   `json:"error_class"`
+	DNSInMs        *float64 `json:"dns_in_ms"`
+	ConnectInMs    *float64 `json:"connect_in_ms"`
+	TLSInMs        *float64 `json:"tls_in_ms"`
+	WaitInMs       *float64 `json:"wait_in_ms"`
+	BytesReceived  int64    `json:"bytes_received"`
+	TraceId        string   `json:"trace_id"`
+}
+
+// Writer streams every measurement of a run to a file as it completes, through a buffer,
+// so runs of millions of requests don't keep them in memory
+type Writer struct {
+	mu     sync.Mutex
+	buffer *bufio.Writer
+	closer io.Closer
+	write  func(s Sample) error
+	err    error // the first write that failed, later samples are dropped
+}
+
+// NewCSV returns a writer of CSV samples, starting with the header
+func NewCSV(w io.Writer) *Writer {
+	buffer := bufio.NewWriter(w)
+	records := csv.NewWriter(buffer)
+	writer := &Writer{buffer: buffer, write: func(s Sample) error {
+		records.Write([]string{
+			s.TimestampStart, s.TimestampEnd, s.Status, strconv.FormatBool(s.Request), formatMs(&s.DurationInMs), s.ErrorClass,
+			formatMs(s.DNSInMs), formatMs(s.ConnectInMs), formatMs(s.TLSInMs), formatMs(s.WaitInMs),
+			strconv.FormatInt(s.BytesReceived, 10), s.TraceId,
+		})
+		// The csv writer keeps its own buffer, flushed into the bufio one after every record
+		records.Flush()
+		return records.Error()
+	}}
+	records.Write(Columns)
+	records.Flush()
+	writer.err = records.Error()
+	return writer
+}
+
+// NewNDJSON returns a writer of samples as JSON objects, one per line
+func NewNDJSON(w io.Writer) *Writer {
+	buffer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffer)
+	return &Writer{buffer: buffer, write: func(s Sample) error { return encoder.Encode(s) }}
+}
+
+// Formats of the samples, as named by CreateAs
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Create creates the file at path and returns a writer for it, CSV or NDJSON as told by its extension
+func Create(path string) (*Writer, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	return CreateAs(path, format)
+}
+
+// FormatOf returns the format told by the extension of path
+func FormatOf(path string) (string, error) {
+	switch filepath.Ext(path) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", errors.New(ErrUnknownSamplesFormat)
+	}
+}
+
+// CreateAs creates the file at path and returns a writer for it in the format, whatever its extension
+func CreateAs(path, format string) (*Writer, error) {
+	var newWriter func(w io.Writer) *Writer
+	switch format {
+	case FormatCSV:
+		newWriter = NewCSV
+	case FormatNDJSON:
+		newWriter = NewNDJSON
+	default:
+		return nil, errors.New(ErrUnknownSamplesFormat)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	writer := newWriter(file)
+	writer.closer = file
+	return writer, nil
+}
+
+// Observer returns the observer that feeds the writer, to be passed to run.WithObserver
+func (w *Writer) Observer() run.Observer {
+	return run.Observer{Measured: w.measured}
+}
+
+func (w *Writer) measured(m run.Measurement) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	w.err = w.write(NewSample(m))
+}
+
+// Close flushes what's buffered and closes the file, returning the first error of the writer
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.buffer.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if w.closer != nil {
+		if err := w.closer.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	return w.err
+}
+
+// NewSample turns a measurement into its sample
+func NewSample(m run.Measurement) Sample {
+	sample := Sample{
+		TimestampStart: run.FormatTimeToUTCString(m.Start),
+		TimestampEnd:   run.FormatTimeToUTCString(m.Start.Add(m.Duration)),
+		Status:         m.Status,
+		Request:        m.Request,
+		DurationInMs:   toMs(m.Duration),
+		ErrorClass:     m.ErrorClass,
+		DNSInMs:        phaseMs(m.Phases, run.PhaseDNS),
+		ConnectInMs:    phaseMs(m.Phases, run.PhaseConnect),
+		TLSInMs:        phaseMs(m.Phases, run.PhaseTLS),
+		WaitInMs:       phaseMs(m.Phases, run.PhaseWait),
+		BytesReceived:  m.BytesReceived,
+	}
+	if m.Span != nil {
+		sample.TraceId = m.Span.TraceID
+	}
+	return sample
+}
+
+func phaseMs(phases map[string]time.Duration, phase string) *float64 {
+	duration, ok := phases[phase]
+	if !ok {
+		return nil
+	}
+	ms := toMs(duration)
+	return &ms
+}
+
+// toMs converts a duration to milliseconds, keeping the microseconds
+func toMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// formatMs formats milliseconds for the CSV, empty when there's no value
+func formatMs(ms *float64) string {
+	if ms == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*ms, 'f', -1, 64)
+}
@@ -0,0 +1,136 @@
+package samples_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"stresstest/internal/samples"
+	"stresstest/internal/usecase/run"
+	"stresstest/mocks/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var start = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func Test_MustWriteSamplesAsCSV(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	writer := samples.NewCSV(&out)
+	observer := writer.Observer()
+
+	// Act
+	observer.Measured(run.Measurement{
+		Status:        "200",
+		Start:         start,
+		Duration:      12500 * time.Microsecond,
+		Request:       true,
+		Phases:        map[string]time.Duration{run.PhaseConnect: time.Millisecond, run.PhaseWait: 10 * time.Millisecond},
+		BytesReceived: 512,
+		Span:          &run.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c"},
+	})
+	observer.Measured(run.Measurement{Status: "connect", Start: start, Duration: 2 * time.Millisecond})
+	err := writer.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	records, err := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		samples.Columns,
+		{"2025-01-02 03:04:05.0000000", "2025-01-02 03:04:05.0125000", "200", "true", "12.5", "", "", "1", "", "10", "512", "0af7651916cd43dd8448eb211c80319c"},
+		{"2025-01-02 03:04:05.0000000", "2025-01-02 03:04:05.0020000", "connect", "false", "2", "", "", "", "", "", "0", ""},
+	}, records)
+}
+
+func Test_MustWriteSamplesAsNDJSON(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	writer := samples.NewNDJSON(&out)
+	observer := writer.Observer()
+
+	// Act
+	observer.Measured(run.Measurement{
+		Status:     "0",
+		Start:      start,
+		Duration:   3 * time.Second,
+		Request:    true,
+		Phases:     map[string]time.Duration{run.PhaseDNS: 2 * time.Millisecond},
+		ErrorClass: run.ErrorClassTimeout,
+	})
+	err := writer.Close()
+
+	// Assert
+	assert.NoError(t, err)
+	var sample map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &sample))
+	assert.Equal(t, map[string]any{
+		"timestamp_start": "2025-01-02 03:04:05.0000000",
+		"timestamp_end":   "2025-01-02 03:04:08.0000000",
+		"status":          "0",
+		"request":         true,
+		"duration_in_ms":  float64(3000),
+		"error_class":     "timeout",
+		"dns_in_ms":       float64(2),
+		"connect_in_ms":   nil,
+		"tls_in_ms":       nil,
+		"wait_in_ms":      nil,
+		"bytes_received":  float64(0),
+		"trace_id":        "",
+	}, sample)
+}
+
+func Test_MustStreamEverySampleOfARunToTheFile(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+	path := filepath.Join(t.TempDir(), "samples.ndjson")
+	writer, err := samples.Create(path)
+	assert.NoError(t, err)
+
+	// Act
+	output, err := uc.Run(run.WithObserver(context.Background(), writer.Observer()), run.RunInputDTO{Url: target.URL, Requests: 50, Concurrency: 5})
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	// Assert
+	assert.Empty(t, output.Data)
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var sample samples.Sample
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &sample))
+		assert.Equal(t, "200", sample.Status)
+		assert.Equal(t, int64(5), sample.BytesReceived)
+		assert.NotNil(t, sample.WaitInMs)
+		lines++
+	}
+	assert.Equal(t, 50, lines)
+}
+
+func Test_MustRefuseUnknownSampleFormats(t *testing.T) {
+	// Act
+	_, err := samples.Create(filepath.Join(t.TempDir(), "samples.xlsx"))
+
+	// Assert
+	assert.EqualError(t, err, samples.ErrUnknownSamplesFormat)
+}
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"stresstest/internal/presenters"
+	"stresstest/internal/repository"
+	"stresstest/internal/usecase/run"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ErrTooManyRuns    = "too many runs executing, try again later"
+	ErrRunNotFound    = "run not found"
+	ErrRunNotFinished = "run has not finished yet"
+	ErrRunHasNoReport = "run failed before producing a report"
+	ErrRunFinished    = "run has already finished"
+	ErrUnknownFormat  = "unknown report format, must be json, md or html"
+
+	StatusRunning  = "running"
+	StatusFinished = "finished"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+	// StatusUnknown is the status of runs the repository has but this server didn't start
+	StatusUnknown = "unknown"
+)
+
+type RunStatusDTO struct {
+	Id          string                `json:"id"`
+	Status      string                `json:"status"`
+	Url         string                `json:"url"`
+	Requests    int                   `json:"requests"`
+	Concurrency int                   `json:"concurrency"`
+	StartedAt   string                `json:"started_at"`
+	FinishedAt  string                `json:"finished_at,omitempty"`
+	Completed   int                   `json:"completed"` // requests finished so far
+	Error       string                `json:"error,omitempty"`
+	Live        []run.StatusReportDTO `json:"live,omitempty"` // report of the requests finished so far
+}
+
+// Server starts runs over HTTP and keeps their status and reports, up to a number of finished runs
+type Server struct {
+	usecase run.RunUseCaseInterface
+	repo    repository.RepositoryInterface
+	token   string // clients must send it, see run.RequireToken
+	// slots holds a token for every run executing, so no more than its capacity run at once
+	slots    chan struct{}
+	keptRuns int
+
+	mu       sync.Mutex
+	runs     map[string]*serverRun
+	finished []string // ids of the finished runs still kept, oldest first
+}
+
+// serverRun is a run started by the server
+type serverRun struct {
+	input   run.RunInputDTO
+	started time.Time
+	live    *run.LiveReport
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	status   string
+	err      string
+	finished time.Time
+	output   *run.RunOutputDTO
+}
+
+// NewServer returns a server that executes at most maxRunning runs at once and keeps the last keptRuns finished ones,
+// only clients with the token get through
+func NewServer(usecase run.RunUseCaseInterface, repo repository.RepositoryInterface, maxRunning, keptRuns int, token string) *Server {
+	return &Server{
+		usecase:  usecase,
+		repo:     repo,
+		token:    token,
+		slots:    make(chan struct{}, max(maxRunning, 1)),
+		keptRuns: max(keptRuns, 1),
+		runs:     make(map[string]*serverRun),
+	}
+}
+
+// Handler returns the HTTP API of the server
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.start)
+	mux.HandleFunc("GET /runs", s.history)
+	mux.HandleFunc("GET /runs/{id}", s.get)
+	mux.HandleFunc("DELETE /runs/{id}", s.cancel)
+	mux.HandleFunc("GET /runs/{id}/report", s.report)
+	return run.RequireToken(s.token, mux)
+}
+
+// start validates the input and starts the run in the background, answering with its id right away
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	var input run.RunInputDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	input.Id = uuid.New().String()
+	// The API never reads files or variables of the machine on behalf of its clients
+	if err := run.CheckRemoteInput(input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := run.ValidateInput(input); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		writeError(w, http.StatusTooManyRequests, errors.New(ErrTooManyRuns))
+		return
+	}
+
+	// The run outlives the request that started it
+	ctx, cancel := context.WithCancel(context.Background())
+	current := &serverRun{input: input, started: time.Now(), live: run.NewLiveReport(), cancel: cancel, status: StatusRunning}
+	s.mu.Lock()
+	s.runs[input.Id] = current
+	s.mu.Unlock()
+
+	go func() {
+		defer func() { <-s.slots }()
+		defer cancel()
+		output, err := s.usecase.Run(run.WithObserver(ctx, current.live.Observer()), input)
+
+		current.mu.Lock()
+		current.finished = time.Now()
+		switch {
+		case err != nil:
+			current.status = StatusFailed
+			current.err = err.Error()
+		case current.status == StatusCanceled:
+			current.output = &output
+		default:
+			current.status = StatusFinished
+			current.output = &output
+		}
+		current.mu.Unlock()
+		s.keep(input.Id)
+	}()
+
+	writeJSON(w, http.StatusAccepted, current.statusDTO())
+}
+
+// history lists every run the repository has, with the status of the ones this server started
+func (s *Server) history(w http.ResponseWriter, r *http.Request) {
+	testRuns, err := s.repo.FindAll(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	history := make([]RunStatusDTO, 0, len(testRuns))
+	for _, testRun := range testRuns {
+		if current := s.find(testRun.Id); current != nil {
+			status := current.statusDTO()
+			status.Live = nil
+			history = append(history, status)
+			continue
+		}
+		history = append(history, RunStatusDTO{
+			Id:          testRun.Id,
+			Status:      StatusUnknown,
+			Url:         testRun.Url,
+			Requests:    testRun.Requests,
+			Concurrency: testRun.Concurrency,
+			StartedAt:   run.FormatTimeToUTCString(testRun.Timestamp),
+		})
+	}
+	writeJSON(w, http.StatusOK, history)
+}
+
+// get returns the status of a run along with its live report
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	current := s.find(r.PathValue("id"))
+	if current == nil {
+		writeError(w, http.StatusNotFound, errors.New(ErrRunNotFound))
+		return
+	}
+	writeJSON(w, http.StatusOK, current.statusDTO())
+}
+
+// cancel stops a running run, its report keeps what was measured until then
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	current := s.find(r.PathValue("id"))
+	if current == nil {
+		writeError(w, http.StatusNotFound, errors.New(ErrRunNotFound))
+		return
+	}
+
+	current.mu.Lock()
+	if current.status != StatusRunning {
+		current.mu.Unlock()
+		writeError(w, http.StatusConflict, errors.New(ErrRunFinished))
+		return
+	}
+	current.status = StatusCanceled
+	current.mu.Unlock()
+	current.cancel()
+
+	writeJSON(w, http.StatusAccepted, current.statusDTO())
+}
+
+// report returns the final report of a run as json, md or html
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	current := s.find(r.PathValue("id"))
+	if current == nil {
+		writeError(w, http.StatusNotFound, errors.New(ErrRunNotFound))
+		return
+	}
+
+	current.mu.Lock()
+	output, finished := current.output, !current.finished.IsZero()
+	current.mu.Unlock()
+	if !finished {
+		writeError(w, http.StatusConflict, errors.New(ErrRunNotFinished))
+		return
+	}
+	if output == nil {
+		writeError(w, http.StatusConflict, errors.New(ErrRunHasNoReport))
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		writeJSON(w, http.StatusOK, output)
+	case "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write([]byte(presenters.ToMarkdown(*output)))
+	case "html":
+		html, err := presenters.ToHTML(*output)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(html))
+	default:
+		writeError(w, http.StatusBadRequest, errors.New(ErrUnknownFormat))
+	}
+}
+
+// keep adds a run to the finished ones, forgetting the oldest past keptRuns so reports don't pile up in memory
+func (s *Server) keep(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = append(s.finished, id)
+	if len(s.finished) > s.keptRuns {
+		delete(s.runs, s.finished[0])
+		// The repository forgets it too, or a long running server would keep every run
+		_ = s.repo.Delete(context.Background(), s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
+
+func (s *Server) find(id string) *serverRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[id]
+}
+
+func (c *serverRun) statusDTO() RunStatusDTO {
+	live := c.live.Report()
+	status := RunStatusDTO{
+		Id:          c.input.Id,
+		Url:         c.input.Url,
+		Requests:    c.input.Requests,
+		Concurrency: c.input.Concurrency,
+		StartedAt:   run.FormatTimeToUTCString(c.started),
+		Live:        live,
+	}
+	for _, report := range live {
+		if report.Status == "total" {
+			status.Completed = report.Count
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status.Status = c.status
+	status.Error = c.err
+	if !c.finished.IsZero() {
+		status.FinishedAt = run.FormatTimeToUTCString(c.finished)
+	}
+	return status
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
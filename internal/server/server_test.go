@@ -0,0 +1,193 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"stresstest/internal/repository"
+	"stresstest/internal/server"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const apiToken = "api-secret"
+
+func newServer(t *testing.T, maxRunning, keptRuns int) string {
+	repo := repository.NewRepository()
+	uc := run.NewRunUseCase(repo)
+	api := httptest.NewServer(server.NewServer(&uc, repo, maxRunning, keptRuns, apiToken).Handler())
+	t.Cleanup(api.Close)
+	return api.URL
+}
+
+// call sends a request to the API with its token
+func call(t *testing.T, method, url, body string) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	return resp
+}
+
+func startRun(t *testing.T, api, body string) (*http.Response, server.RunStatusDTO) {
+	resp := call(t, http.MethodPost, api+"/runs", body)
+	defer resp.Body.Close()
+	var status server.RunStatusDTO
+	json.NewDecoder(resp.Body).Decode(&status)
+	return resp, status
+}
+
+func getStatus(t *testing.T, api, id string) server.RunStatusDTO {
+	resp := call(t, http.MethodGet, api+"/runs/"+id, "")
+	defer resp.Body.Close()
+	var status server.RunStatusDTO
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	return status
+}
+
+func waitFinished(t *testing.T, api, id string) server.RunStatusDTO {
+	var status server.RunStatusDTO
+	assert.Eventually(t, func() bool {
+		status = getStatus(t, api, id)
+		return status.FinishedAt != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func Test_MustStartRunAndServeItsReport(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	api := newServer(t, 2, 10)
+
+	// Act
+	resp, started := startRun(t, api, `{"url": "`+target.URL+`", "requests": 6, "concurrency": 2}`)
+	finished := waitFinished(t, api, started.Id)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, server.StatusRunning, started.Status)
+	assert.Equal(t, server.StatusFinished, finished.Status)
+	assert.Equal(t, 6, finished.Completed)
+
+	report := call(t, http.MethodGet, api+"/runs/"+started.Id+"/report", "")
+	defer report.Body.Close()
+	var output run.RunOutputDTO
+	assert.NoError(t, json.NewDecoder(report.Body).Decode(&output))
+	assert.Equal(t, started.Id, output.Id)
+
+	for format, contentType := range map[string]string{"md": "text/markdown", "html": "text/html"} {
+		resp := call(t, http.MethodGet, api+"/runs/"+started.Id+"/report?format="+format, "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), contentType)
+	}
+
+	history := call(t, http.MethodGet, api+"/runs", "")
+	defer history.Body.Close()
+	var runs []server.RunStatusDTO
+	assert.NoError(t, json.NewDecoder(history.Body).Decode(&runs))
+	assert.Len(t, runs, 1)
+	assert.Equal(t, server.StatusFinished, runs[0].Status)
+}
+
+func Test_MustLimitRunningRunsAndCancelThem(t *testing.T) {
+	// Arrange
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer target.Close()
+	defer close(release)
+	api := newServer(t, 1, 10)
+	body := `{"url": "` + target.URL + `", "requests": 1000, "concurrency": 1}`
+
+	// Act
+	_, first := startRun(t, api, body)
+	second, _ := startRun(t, api, body)
+	canceled := call(t, http.MethodDelete, api+"/runs/"+first.Id, "")
+	canceled.Body.Close()
+	finished := waitFinished(t, api, first.Id)
+	third, _ := startRun(t, api, body)
+
+	// Assert
+	assert.Equal(t, http.StatusTooManyRequests, second.StatusCode)
+	assert.Equal(t, http.StatusAccepted, canceled.StatusCode)
+	assert.Equal(t, server.StatusCanceled, finished.Status)
+	assert.Less(t, finished.Completed, 1000)
+	assert.Equal(t, http.StatusAccepted, third.StatusCode)
+}
+
+func Test_MustAnswerErrorsForInvalidRequests(t *testing.T) {
+	// Arrange
+	api := newServer(t, 1, 10)
+
+	// Act
+	invalid, _ := startRun(t, api, `{"url": "ftp://example.com"}`)
+	unknown := call(t, http.MethodGet, api+"/runs/unknown", "")
+	unknown.Body.Close()
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, invalid.StatusCode)
+	assert.Equal(t, http.StatusNotFound, unknown.StatusCode)
+}
+
+func Test_MustRefuseRunsWithoutTheTokenOrNamingLocalFiles(t *testing.T) {
+	// Arrange
+	api := newServer(t, 1, 10)
+	req, _ := http.NewRequest(http.MethodGet, api+"/runs", nil)
+
+	// Act
+	withoutToken, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	withoutToken.Body.Close()
+	localFile, _ := startRun(t, api, `{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1, "auth": {"type": "bearer", "token_file": "/etc/passwd"}}`)
+	localEnv, _ := startRun(t, api, `{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1, "auth": {"type": "bearer", "token_env": "HOME"}}`)
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, withoutToken.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, localFile.StatusCode)
+	assert.Equal(t, http.StatusBadRequest, localEnv.StatusCode)
+}
+
+func Test_MustKeepOnlyTheLastFinishedRuns(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	api := newServer(t, 1, 2)
+	body := `{"url": "` + target.URL + `", "requests": 1, "concurrency": 1}`
+
+	// Act
+	var ids []string
+	for range 3 {
+		_, started := startRun(t, api, body)
+		waitFinished(t, api, started.Id)
+		ids = append(ids, started.Id)
+	}
+	evicted := call(t, http.MethodGet, api+"/runs/"+ids[0], "")
+	evicted.Body.Close()
+	// The oldest run leaves the repository right after the last one is shown finished
+	var history []server.RunStatusDTO
+	assert.Eventually(t, func() bool {
+		resp := call(t, http.MethodGet, api+"/runs", "")
+		defer resp.Body.Close()
+		history = nil
+		return json.NewDecoder(resp.Body).Decode(&history) == nil && len(history) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, evicted.StatusCode)
+	assert.Len(t, history, 2)
+	assert.Equal(t, ids[1], history[0].Id)
+	assert.Equal(t, ids[2], history[1].Id)
+	assert.Equal(t, server.StatusFinished, getStatus(t, api, ids[1]).Status)
+	assert.Equal(t, server.StatusFinished, getStatus(t, api, ids[2]).Status)
+}
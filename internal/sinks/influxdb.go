@@ -0,0 +1,91 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InfluxMeasurement is the measurement every point is written to
+const InfluxMeasurement = "stresstest"
+
+// InfluxDB writes points in the line protocol to the HTTP write endpoint of InfluxDB,
+// like http://localhost:8086/api/v2/write?org=acme&bucket=load, with nanosecond timestamps
+type InfluxDB struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewInfluxDB returns a sink for the write endpoint, sending the token when it isn't empty
+func NewInfluxDB(url, token string) *InfluxDB {
+	return &InfluxDB{url: url, token: token, client: &http.Client{}}
+}
+
+func (s *InfluxDB) Write(ctx context.Context, points []Point, tags map[string]string) error {
+	var body bytes.Buffer
+	for _, point := range points {
+		body.WriteString(InfluxLine(point, tags))
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb answered %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// InfluxLine formats a point in the line protocol, with the tags sorted by key as InfluxDB prefers
+func InfluxLine(point Point, tags map[string]string) string {
+	all := map[string]string{"status": point.Status}
+	for key, value := range tags {
+		all[key] = value
+	}
+	if point.ErrorClass != "" {
+		all["error_class"] = point.ErrorClass
+	}
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	line.WriteString(InfluxMeasurement)
+	for _, key := range keys {
+		// Empty tag values aren't allowed by the line protocol
+		if all[key] == "" {
+			continue
+		}
+		line.WriteString("," + escapeInflux(key) + "=" + escapeInflux(all[key]))
+	}
+	line.WriteString(" duration_ms=" + strconv.FormatFloat(float64(point.Duration.Microseconds())/1000, 'f', -1, 64))
+	line.WriteString(",bytes_received=" + strconv.FormatInt(point.BytesReceived, 10) + "i")
+	line.WriteString(",request=" + strconv.FormatBool(point.Request))
+	line.WriteString(" " + strconv.FormatInt(point.Time.UnixNano(), 10))
+	return line.String()
+}
+
+// escapeInflux escapes the characters the line protocol gives meaning to in tag keys and values
+func escapeInflux(s string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(s)
+}
@@ -0,0 +1,336 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OTLPServiceName is the service.name of the resource the spans and metrics come from
+	OTLPServiceName = "stresstest"
+
+	otlpSpanKindClient  = 3
+	otlpStatusCodeError = 2
+	// otlpCumulative is the aggregation temporality of the metrics, every push carries the totals since the start
+	otlpCumulative = 2
+)
+
+// OTLPLatencyBounds are the upper bounds of the latency histogram, in milliseconds
+var OTLPLatencyBounds = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// OTLP exports to an OpenTelemetry collector over OTLP/HTTP with JSON encoding: the sampled requests
+// as client spans to /v1/traces, and the request counters and latency histogram to /v1/metrics
+type OTLP struct {
+	endpoint string
+	headers  map[string]string
+	method   string
+	client   *http.Client
+	start    time.Time
+
+	// Cumulative metrics, Write is only called by one pusher so they need no lock
+	requests map[string]int64 // by status
+	errors   map[string]int64 // by class
+	bytes    int64
+	latency  map[string]*otlpHistogram // by status
+}
+
+type otlpHistogram struct {
+	buckets []int64 // count of observations in each of OTLPLatencyBounds, plus the one above the last
+	count   int64
+	sum     float64
+}
+
+// NewOTLP returns a sink for the collector at endpoint, like http://localhost:4318, sending the headers with every push.
+// The method names the spans of the requests
+func NewOTLP(endpoint string, headers map[string]string, method string) *OTLP {
+	return &OTLP{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		headers:  headers,
+		method:   method,
+		client:   &http.Client{},
+		start:    time.Now(),
+		requests: make(map[string]int64),
+		errors:   make(map[string]int64),
+		latency:  make(map[string]*otlpHistogram),
+	}
+}
+
+func (s *OTLP) Write(ctx context.Context, points []Point, tags map[string]string) error {
+	var spans []otlpSpan
+	for _, point := range points {
+		s.aggregate(point)
+		if point.Span != nil && point.Span.Sampled {
+			spans = append(spans, s.span(point, tags))
+		}
+	}
+	resource := otlpResource{Attributes: otlpAttributes(map[string]string{"service.name": OTLPServiceName}, tags)}
+	scope := otlpScope{Name: OTLPServiceName}
+
+	var errs []error
+	if len(spans) > 0 {
+		traces := otlpTraces{ResourceSpans: []otlpResourceSpans{{Resource: resource, ScopeSpans: []otlpScopeSpans{{Scope: scope, Spans: spans}}}}}
+		errs = append(errs, s.post(ctx, "/v1/traces", traces))
+	}
+	metrics := otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{Resource: resource, ScopeMetrics: []otlpScopeMetrics{{Scope: scope, Metrics: s.metrics()}}}}}
+	errs = append(errs, s.post(ctx, "/v1/metrics", metrics))
+	return errors.Join(errs...)
+}
+
+// aggregate adds a point to the cumulative metrics
+func (s *OTLP) aggregate(point Point) {
+	if point.Request {
+		s.requests[point.Status]++
+	}
+	if point.ErrorClass != "" {
+		s.errors[point.ErrorClass]++
+	}
+	s.bytes += point.BytesReceived
+
+	h, exists := s.latency[point.Status]
+	if !exists {
+		h = &otlpHistogram{buckets: make([]int64, len(OTLPLatencyBounds)+1)}
+		s.latency[point.Status] = h
+	}
+	ms := float64(point.Duration.Microseconds()) / 1000
+	h.buckets[sort.SearchFloat64s(OTLPLatencyBounds, ms)]++
+	h.count++
+	h.sum += ms
+}
+
+// span turns a sampled request into its client span
+func (s *OTLP) span(point Point, tags map[string]string) otlpSpan {
+	start := point.Start
+	if start.IsZero() {
+		start = point.Time.Add(-point.Duration)
+	}
+	attributes := map[string]string{"http.request.method": s.method}
+	if url := tags["url"]; url != "" {
+		attributes["url.full"] = url
+	}
+	span := otlpSpan{
+		TraceId:           point.Span.TraceID,
+		SpanId:            point.Span.SpanID,
+		Name:              s.method,
+		Kind:              otlpSpanKindClient,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(start.Add(point.Duration)),
+		Attributes:        otlpAttributes(attributes),
+	}
+	if status, err := strconv.Atoi(point.Status); err == nil && status > 0 {
+		span.Attributes = append(span.Attributes, otlpKeyValue{Key: "http.response.status_code", Value: otlpValue{IntValue: point.Status}})
+	}
+	if point.ErrorClass != "" {
+		span.Attributes = append(span.Attributes, otlpKeyValue{Key: "error.type", Value: otlpValue{StringValue: point.ErrorClass}})
+		span.Status = &otlpStatus{Code: otlpStatusCodeError, Message: point.ErrorClass}
+	}
+	return span
+}
+
+// metrics returns the cumulative metrics as of now
+func (s *OTLP) metrics() []otlpMetric {
+	start, now := unixNano(s.start), unixNano(time.Now())
+	counter := func(name, unit, key string, values map[string]int64) otlpMetric {
+		sum := &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true}
+		for _, value := range sortedKeys(values) {
+			sum.DataPoints = append(sum.DataPoints, otlpNumberDataPoint{
+				Attributes:        otlpAttributes(map[string]string{key: value}),
+				StartTimeUnixNano: start,
+				TimeUnixNano:      now,
+				AsInt:             strconv.FormatInt(values[value], 10),
+			})
+		}
+		return otlpMetric{Name: name, Unit: unit, Sum: sum}
+	}
+
+	metrics := []otlpMetric{
+		counter("stresstest.requests", "{request}", "status", s.requests),
+		counter("stresstest.errors", "{error}", "error.type", s.errors),
+		{Name: "stresstest.received_bytes", Unit: "By", Sum: &otlpSum{AggregationTemporality: otlpCumulative, IsMonotonic: true, DataPoints: []otlpNumberDataPoint{
+			{StartTimeUnixNano: start, TimeUnixNano: now, AsInt: strconv.FormatInt(s.bytes, 10)},
+		}}},
+	}
+
+	histogram := &otlpHistogramData{AggregationTemporality: otlpCumulative}
+	for _, status := range sortedKeys(s.latency) {
+		h := s.latency[status]
+		buckets := make([]string, len(h.buckets))
+		for i, count := range h.buckets {
+			buckets[i] = strconv.FormatInt(count, 10)
+		}
+		histogram.DataPoints = append(histogram.DataPoints, otlpHistogramDataPoint{
+			Attributes:        otlpAttributes(map[string]string{"status": status}),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			Count:             strconv.FormatInt(h.count, 10),
+			Sum:               h.sum,
+			BucketCounts:      buckets,
+			ExplicitBounds:    OTLPLatencyBounds,
+		})
+	}
+	return append(metrics, otlpMetric{Name: "stresstest.request.duration", Unit: "ms", Histogram: histogram})
+}
+
+func (s *OTLP) post(ctx context.Context, path string, body any) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+path, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp collector answered %d on %s: %s", resp.StatusCode, path, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// otlpAttributes turns maps of attributes into key values sorted by key, later maps overriding earlier ones
+// and empty values left out
+func otlpAttributes(maps ...map[string]string) []otlpKeyValue {
+	all := make(map[string]string)
+	for _, m := range maps {
+		for key, value := range m {
+			if value != "" {
+				all[key] = value
+			}
+		}
+	}
+	attributes := make([]otlpKeyValue, 0, len(all))
+	for _, key := range sortedKeys(all) {
+		attributes = append(attributes, otlpKeyValue{Key: key, Value: otlpValue{StringValue: all[key]}})
+	}
+	return attributes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unixNano formats a time the way OTLP/JSON expects 64 bit integers
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// The OTLP/JSON messages, with only the fields the sink sends
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name      string             `json:"name"`
+	Unit      string             `json:"unit,omitempty"`
+	Sum       *otlpSum           `json:"sum,omitempty"`
+	Histogram *otlpHistogramData `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpHistogramData struct {
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	Count             string         `json:"count"`
+	Sum               float64        `json:"sum"`
+	BucketCounts      []string       `json:"bucketCounts"`
+	ExplicitBounds    []float64      `json:"explicitBounds"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue,omitempty"`
+	IntValue    string `json:"intValue,omitempty"`
+}
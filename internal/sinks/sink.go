@@ -0,0 +1,154 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"stresstest/internal/usecase/run"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFlushInterval is how often points are pushed when the options don't say
+	DefaultFlushInterval = time.Second
+	// DefaultBatchSize is how many points are buffered before they're pushed ahead of the interval
+	DefaultBatchSize = 1000
+
+	// writeTimeout bounds a single push, so a slow backend doesn't hold points forever
+	writeTimeout = 10 * time.Second
+)
+
+// Point is a single measurement of a run, as a sink writes it
+type Point struct {
+	Time          time.Time
+	Status        string
+	Duration      time.Duration
+	Request       bool // counts as a request of the run
+	BytesReceived int64
+	ErrorClass    string // empty when the request didn't fail
+	Start         time.Time
+	Span          *run.SpanContext // only known for HTTP requests of a traced run
+}
+
+// Sink writes batches of points to a metrics backend, every point carrying the tags
+type Sink interface {
+	Write(ctx context.Context, points []Point, tags map[string]string) error
+}
+
+type Options struct {
+	FlushInterval time.Duration
+	BatchSize     int
+	Tags          map[string]string // like the run id, the URL and custom labels
+}
+
+// Pusher buffers the measurements of a run and pushes them to a sink in batches while the run goes
+type Pusher struct {
+	sink Sink
+	opts Options
+
+	mu        sync.Mutex
+	buffer    []Point
+	failures  int
+	lastError error
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPusher starts pushing to the sink, until Close is called
+func NewPusher(sink Sink, opts Options) *Pusher {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	p := &Pusher{
+		sink: sink,
+		opts: opts,
+		full: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go p.loop()
+	return p
+}
+
+// Observer returns the observer that feeds the pusher, to be passed to run.WithObserver
+func (p *Pusher) Observer() run.Observer {
+	return run.Observer{Measured: p.add}
+}
+
+func (p *Pusher) add(m run.Measurement) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buffer = append(p.buffer, Point{
+		Time:          time.Now(),
+		Status:        m.Status,
+		Duration:      m.Duration,
+		Request:       m.Request,
+		BytesReceived: m.BytesReceived,
+		ErrorClass:    m.ErrorClass,
+		Start:         m.Start,
+		Span:          m.Span,
+	})
+	if len(p.buffer) >= p.opts.BatchSize {
+		select {
+		case p.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *Pusher) loop() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.flush()
+		case <-p.full:
+			p.flush()
+		case <-p.stop:
+			p.flush()
+			return
+		}
+	}
+}
+
+// flush pushes the buffered points, a batch at a time
+func (p *Pusher) flush() {
+	p.mu.Lock()
+	points := p.buffer
+	p.buffer = nil
+	p.mu.Unlock()
+
+	for len(points) > 0 {
+		batch := points[:min(len(points), p.opts.BatchSize)]
+		points = points[len(batch):]
+
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := p.sink.Write(ctx, batch, p.opts.Tags)
+		cancel()
+		if err != nil {
+			p.mu.Lock()
+			p.failures++
+			p.lastError = err
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Close pushes what's left and closes the sink, returning how many pushes failed and the last error
+func (p *Pusher) Close() (failures int, lastError error) {
+	close(p.stop)
+	<-p.done
+	if closer, ok := p.sink.(io.Closer); ok {
+		closer.Close()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failures, p.lastError
+}
@@ -0,0 +1,251 @@
+package sinks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"stresstest/internal/sinks"
+	"stresstest/internal/usecase/run"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]sinks.Point
+	tags    map[string]string
+	err     error
+}
+
+func (s *fakeSink) Write(ctx context.Context, points []sinks.Point, tags map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, points)
+	s.tags = tags
+	return s.err
+}
+
+func (s *fakeSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.batches)
+}
+
+func Test_MustPushInBatchesOfTheConfiguredSize(t *testing.T) {
+	// Arrange
+	sink := &fakeSink{}
+	pusher := sinks.NewPusher(sink, sinks.Options{FlushInterval: time.Hour, BatchSize: 2, Tags: map[string]string{"run_id": "abc"}})
+	observer := pusher.Observer()
+
+	// Act
+	observer.Measured(run.Measurement{Status: "200", Request: true})
+	observer.Measured(run.Measurement{Status: "200", Request: true})
+	assert.Eventually(t, func() bool { return sink.count() == 1 }, time.Second, 5*time.Millisecond)
+	observer.Measured(run.Measurement{Status: "503", Request: true})
+	failures, err := pusher.Close()
+
+	// Assert
+	assert.Equal(t, 0, failures)
+	assert.NoError(t, err)
+	assert.Len(t, sink.batches, 2)
+	assert.Len(t, sink.batches[0], 2)
+	assert.Equal(t, "503", sink.batches[1][0].Status)
+	assert.Equal(t, map[string]string{"run_id": "abc"}, sink.tags)
+}
+
+func Test_MustPushOnTheFlushIntervalAndCountFailures(t *testing.T) {
+	// Arrange
+	sink := &fakeSink{err: errors.New("backend down")}
+	pusher := sinks.NewPusher(sink, sinks.Options{FlushInterval: 10 * time.Millisecond, BatchSize: 100})
+
+	// Act
+	pusher.Observer().Measured(run.Measurement{Status: "200", Request: true})
+	assert.Eventually(t, func() bool { return sink.count() == 1 }, time.Second, 5*time.Millisecond)
+	failures, err := pusher.Close()
+
+	// Assert
+	assert.Equal(t, 1, failures)
+	assert.EqualError(t, err, "backend down")
+}
+
+func Test_MustWriteLineProtocolToInfluxDB(t *testing.T) {
+	// Arrange
+	var body, authorization, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := io.ReadAll(r.Body)
+		body, authorization, query = string(content), r.Header.Get("Authorization"), r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	sink := sinks.NewInfluxDB(server.URL+"/api/v2/write?org=acme&bucket=load", "secret")
+	at := time.Unix(1700000000, 5)
+
+	// Act
+	err := sink.Write(context.Background(), []sinks.Point{
+		{Time: at, Status: "200", Duration: 12500 * time.Microsecond, Request: true, BytesReceived: 512},
+		{Time: at, Status: "0", Duration: time.Millisecond, Request: true, ErrorClass: run.ErrorClassTimeout},
+	}, map[string]string{"run_id": "abc", "url": "http://api/a b", "team": "x,y"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "Token secret", authorization)
+	assert.Equal(t, "org=acme&bucket=load", query)
+	assert.Equal(t,
+		`stresstest,run_id=abc,status=200,team=x\,y,url=http://api/a\ b duration_ms=12.5,bytes_received=512i,request=true 1700000000000000005`+"\n"+
+			`stresstest,error_class=timeout,run_id=abc,status=0,team=x\,y,url=http://api/a\ b duration_ms=1,bytes_received=0i,request=true 1700000000000000005`+"\n",
+		body)
+}
+
+func Test_MustReturnInfluxDBErrors(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+	sink := sinks.NewInfluxDB(server.URL, "")
+
+	// Act
+	err := sink.Write(context.Background(), []sinks.Point{{Status: "200"}}, nil)
+
+	// Assert
+	assert.EqualError(t, err, "influxdb answered 404: bucket not found")
+}
+
+func Test_MustSendDogStatsDLinesOverUDP(t *testing.T) {
+	// Arrange
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	sink, err := sinks.NewStatsD(listener.LocalAddr().String(), true)
+	assert.NoError(t, err)
+	defer sink.Close()
+
+	// Act
+	err = sink.Write(context.Background(), []sinks.Point{
+		{Status: "200", Duration: 12500 * time.Microsecond, Request: true, BytesReceived: 512},
+		{Status: "503", Duration: time.Millisecond, Request: true, ErrorClass: run.ErrorClassHTTP5xx},
+	}, map[string]string{"run_id": "abc"})
+	buffer := make([]byte, 2048)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, readErr := listener.ReadFrom(buffer)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, readErr)
+	assert.Equal(t, []string{
+		"stresstest.requests:1|c|#run_id:abc,status:200",
+		"stresstest.duration:12.5|ms|#run_id:abc,status:200",
+		"stresstest.received_bytes:512|c|#run_id:abc,status:200",
+		"stresstest.requests:1|c|#run_id:abc,status:503",
+		"stresstest.duration:1|ms|#run_id:abc,status:503",
+		"stresstest.errors:1|c|#error_class:http_5xx,run_id:abc,status:503",
+	}, strings.Split(string(buffer[:n]), "\n"))
+}
+
+func Test_MustSplitStatsDLinesInDatagramsUnderTheMTU(t *testing.T) {
+	// Arrange
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	sink, err := sinks.NewStatsD(listener.LocalAddr().String(), false)
+	assert.NoError(t, err)
+	defer sink.Close()
+	points := make([]sinks.Point, 100)
+	for i := range points {
+		points[i] = sinks.Point{Status: "200", Duration: time.Millisecond, Request: true}
+	}
+
+	// Act
+	err = sink.Write(context.Background(), points, map[string]string{"run_id": "abc"})
+	lines := 0
+	buffer := make([]byte, 2048)
+	for lines < 200 {
+		listener.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, readErr := listener.ReadFrom(buffer)
+		if !assert.NoError(t, readErr) {
+			break
+		}
+		assert.LessOrEqual(t, n, 1432)
+		assert.NotContains(t, string(buffer[:n]), "#")
+		lines += len(strings.Split(string(buffer[:n]), "\n"))
+	}
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 200, lines)
+}
+
+func Test_MustExportSampledSpansAndCumulativeMetricsOverOTLP(t *testing.T) {
+	// Arrange
+	var mu sync.Mutex
+	bodies := make(map[string][]map[string]any)
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		defer mu.Unlock()
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], body)
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	sink := sinks.NewOTLP(server.URL+"/", map[string]string{"Authorization": "Bearer secret"}, "GET")
+	start := time.Unix(1700000000, 0)
+	tags := map[string]string{"run_id": "abc", "url": "http://api"}
+
+	// Act
+	err := sink.Write(context.Background(), []sinks.Point{
+		{Start: start, Status: "200", Duration: 12 * time.Millisecond, Request: true, BytesReceived: 512,
+			Span: &run.SpanContext{TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Sampled: true}},
+		{Start: start, Status: "503", Duration: 300 * time.Millisecond, Request: true, ErrorClass: run.ErrorClassHTTP5xx,
+			Span: &run.SpanContext{TraceID: "1af7651916cd43dd8448eb211c80319c", SpanID: "c7ad6b7169203331", Sampled: true}},
+		{Start: start, Status: "200", Duration: time.Millisecond, Request: true,
+			Span: &run.SpanContext{TraceID: "2af7651916cd43dd8448eb211c80319c", SpanID: "d7ad6b7169203331"}},
+	}, tags)
+	assert.NoError(t, err)
+	err = sink.Write(context.Background(), []sinks.Point{{Status: "200", Duration: 2 * time.Second, Request: true}}, tags)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", authorization)
+	assert.Len(t, bodies["/v1/traces"], 1)
+	assert.Len(t, bodies["/v1/metrics"], 2)
+
+	resourceSpans := bodies["/v1/traces"][0]["resourceSpans"].([]any)[0].(map[string]any)
+	assert.Contains(t, resourceSpans["resource"].(map[string]any)["attributes"], map[string]any{"key": "run_id", "value": map[string]any{"stringValue": "abc"}})
+	spans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	assert.Len(t, spans, 2)
+	ok, failed := spans[0].(map[string]any), spans[1].(map[string]any)
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", ok["traceId"])
+	assert.Equal(t, "b7ad6b7169203331", ok["spanId"])
+	assert.Equal(t, "GET", ok["name"])
+	assert.Equal(t, float64(3), ok["kind"])
+	assert.Equal(t, "1700000000000000000", ok["startTimeUnixNano"])
+	assert.Equal(t, "1700000000012000000", ok["endTimeUnixNano"])
+	assert.Contains(t, ok["attributes"], map[string]any{"key": "http.response.status_code", "value": map[string]any{"intValue": "200"}})
+	assert.Nil(t, ok["status"])
+	assert.Equal(t, map[string]any{"code": float64(2), "message": "http_5xx"}, failed["status"])
+
+	metrics := bodies["/v1/metrics"][1]["resourceMetrics"].([]any)[0].(map[string]any)["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any)
+	byName := make(map[string]map[string]any)
+	for _, metric := range metrics {
+		byName[metric.(map[string]any)["name"].(string)] = metric.(map[string]any)
+	}
+	requests := byName["stresstest.requests"]["sum"].(map[string]any)["dataPoints"].([]any)
+	assert.Equal(t, "3", requests[0].(map[string]any)["asInt"])
+	assert.Equal(t, "1", requests[1].(map[string]any)["asInt"])
+	assert.Equal(t, "512", byName["stresstest.received_bytes"]["sum"].(map[string]any)["dataPoints"].([]any)[0].(map[string]any)["asInt"])
+	latency := byName["stresstest.request.duration"]["histogram"].(map[string]any)["dataPoints"].([]any)[0].(map[string]any)
+	assert.Equal(t, "3", latency["count"])
+	assert.Equal(t, float64(2013), latency["sum"])
+	assert.Equal(t, []any{"1", "0", "1", "0", "0", "0", "0", "0", "1", "0", "0", "0"}, latency["bucketCounts"])
+}
@@ -0,0 +1,110 @@
+package sinks
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// StatsDPrefix starts the name of every metric sent
+	StatsDPrefix = "stresstest."
+	// maxDatagramSize keeps datagrams under the usual MTU, so they aren't fragmented or dropped
+	maxDatagramSize = 1432
+)
+
+// StatsD sends points to a StatsD server over UDP, many lines per datagram.
+// With DogStatsD the tags go along with every line, plain StatsD has no tags so they're left out
+type StatsD struct {
+	conn      net.Conn
+	dogStatsD bool
+}
+
+// NewStatsD returns a sink for the server at addr, like localhost:8125
+func NewStatsD(addr string, dogStatsD bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{conn: conn, dogStatsD: dogStatsD}, nil
+}
+
+func (s *StatsD) Write(ctx context.Context, points []Point, tags map[string]string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	var datagram []byte
+	for _, point := range points {
+		for _, line := range s.lines(point, tags) {
+			if len(datagram) > 0 && len(datagram)+1+len(line) > maxDatagramSize {
+				if _, err := s.conn.Write(datagram); err != nil {
+					return err
+				}
+				datagram = datagram[:0]
+			}
+			if len(datagram) > 0 {
+				datagram = append(datagram, '\n')
+			}
+			datagram = append(datagram, line...)
+		}
+	}
+	if len(datagram) > 0 {
+		if _, err := s.conn.Write(datagram); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lines formats a point as the StatsD lines that count it
+func (s *StatsD) lines(point Point, tags map[string]string) []string {
+	pointTags := map[string]string{"status": point.Status}
+	for key, value := range tags {
+		pointTags[key] = value
+	}
+	suffix := s.tags(pointTags)
+
+	var lines []string
+	if point.Request {
+		lines = append(lines, StatsDPrefix+"requests:1|c"+suffix)
+	}
+	lines = append(lines, StatsDPrefix+"duration:"+strconv.FormatFloat(float64(point.Duration.Microseconds())/1000, 'f', -1, 64)+"|ms"+suffix)
+	if point.BytesReceived > 0 {
+		lines = append(lines, StatsDPrefix+"received_bytes:"+strconv.FormatInt(point.BytesReceived, 10)+"|c"+suffix)
+	}
+	if point.ErrorClass != "" {
+		pointTags["error_class"] = point.ErrorClass
+		lines = append(lines, StatsDPrefix+"errors:1|c"+s.tags(pointTags))
+	}
+	return lines
+}
+
+// tags formats the DogStatsD tags suffix, sorted by key
+func (s *StatsD) tags(tags map[string]string) string {
+	if !s.dogStatsD || len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	formatted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		formatted = append(formatted, escapeStatsD(key)+":"+escapeStatsD(tags[key]))
+	}
+	return "|#" + strings.Join(formatted, ",")
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// escapeStatsD replaces the characters that separate lines, fields and tags
+func escapeStatsD(s string) string {
+	return strings.NewReplacer("\n", "_", "|", "_", ",", "_", "#", "_").Replace(s)
+}
@@ -0,0 +1,122 @@
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineSize bounds a single line of the stream, longer lines fail the read
+const maxLineSize = 1024 * 1024
+
+// Event is a single event dispatched from a text/event-stream
+type Event struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// Reader reads the events of a text/event-stream as the server sends them
+type Reader struct {
+	scanner *bufio.Scanner
+	lastID  string
+	retry   time.Duration
+}
+
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	scanner.Split(scanLines)
+	return &Reader{scanner: scanner}
+}
+
+// LastID returns the last event id seen, sent back as Last-Event-ID when reconnecting
+func (r *Reader) LastID() string {
+	return r.lastID
+}
+
+// Retry returns the reconnection time the server asked for, zero when it never set one
+func (r *Reader) Retry() time.Duration {
+	return r.retry
+}
+
+// Next blocks until the next event is dispatched, returning io.EOF when the stream ends
+// Comments and events without data are skipped, as the spec asks
+func (r *Reader) Next() (Event, error) {
+	var event Event
+	var data strings.Builder
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		// A blank line dispatches the event
+		if line == "" {
+			if !hasData {
+				event = Event{}
+				continue
+			}
+			event.ID = r.lastID
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data.WriteString(value)
+			data.WriteString("\n")
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				r.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				r.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	// An event cut off by the end of the stream is discarded
+	return Event{}, io.EOF
+}
+
+// scanLines splits on \r\n, \n or a lone \r, the three line endings the spec allows
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A \r at the end of the buffer may be followed by a \n that hasn't arrived yet
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
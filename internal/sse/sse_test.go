@@ -0,0 +1,59 @@
+package sse_test
+
+import (
+	"io"
+	"stresstest/internal/sse"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader_ParsesFields(t *testing.T) {
+	stream := ": keep-alive\n\nevent: price\nid: 7\ndata: {\"a\":1}\n\ndata: first\ndata:second\n\n"
+	reader := sse.NewReader(strings.NewReader(stream))
+
+	first, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, sse.Event{ID: "7", Type: "price", Data: `{"a":1}`}, first)
+
+	second, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, sse.Event{ID: "7", Type: "message", Data: "first\nsecond"}, second)
+	assert.Equal(t, "7", reader.LastID())
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_LineEndings(t *testing.T) {
+	reader := sse.NewReader(strings.NewReader("data: crlf\r\n\r\ndata: cr\r\rdata: lf\n\n"))
+
+	for _, expected := range []string{"crlf", "cr", "lf"} {
+		event, err := reader.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, event.Data)
+	}
+}
+
+func TestReader_RetryWithoutData(t *testing.T) {
+	reader := sse.NewReader(strings.NewReader("retry: 250\n\ndata: x\n\nretry: abc\n\n"))
+
+	event, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "x", event.Data)
+	assert.Equal(t, 250*time.Millisecond, reader.Retry())
+
+	_, err = reader.Next()
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 250*time.Millisecond, reader.Retry())
+}
+
+func TestReader_DiscardsUnfinishedEvent(t *testing.T) {
+	reader := sse.NewReader(strings.NewReader("data: cut"))
+
+	_, err := reader.Next()
+
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,193 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ErrAgentBusy       = "agent is busy with another run"
+	ErrUnknownAgentRun = "unknown run, it must be prepared first"
+
+	// agentStartTimeout is how long a prepared run waits to be started before the agent takes other runs
+	agentStartTimeout = 30 * time.Second
+	// agentSnapshotInterval is how often a running agent sends its partial results
+	agentSnapshotInterval = time.Second
+)
+
+// agentSnapshot is the report of an agent so far, with the histograms its percentiles come from
+type agentSnapshot struct {
+	Report     []StatusReportDTO     `json:"report"`
+	Histograms map[string]*histogram `json:"histograms"`
+	Corrected  map[string]*histogram `json:"corrected,omitempty"` // corrected for coordinated omission
+	// Histograms of the mode reports, only known once the run is over
+	SSEFirstEvent  *histogram            `json:"sse_first_event,omitempty"`
+	SSEEventGap    *histogram            `json:"sse_event_gap,omitempty"`
+	QUICHandshakes *histogram            `json:"quic_handshakes,omitempty"`
+	Warmup         map[string]*histogram `json:"warmup,omitempty"` // by status, like Histograms
+}
+
+// addModes adds the histograms of the mode reports of the output
+func (s *agentSnapshot) addModes(output RunOutputDTO) {
+	if output.SSE != nil {
+		s.SSEFirstEvent, s.SSEEventGap = output.SSE.firstEvent, output.SSE.gaps
+	}
+	if output.QUIC != nil {
+		s.QUICHandshakes = output.QUIC.handshakes
+	}
+	if output.Warmup != nil {
+		s.Warmup = output.Warmup.histograms
+	}
+}
+
+// agentMessage is a line of the stream an agent answers a started run with
+// Every line has the snapshot so far, the last one also has the result or the error that ended the run
+type agentMessage struct {
+	Snapshot *agentSnapshot `json:"snapshot,omitempty"`
+	Result   *RunOutputDTO  `json:"result,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Agent runs the share of a distributed run a controller sends it, one run at a time
+// A run is prepared first and started by a second request, so the controller can start every agent together
+type Agent struct {
+	usecase *RunUseCase
+	token   string // controllers must send it, see RequireToken
+
+	mu      sync.Mutex
+	busy    bool
+	pending *agentRun
+}
+
+// agentRun is a prepared run waiting to be started
+type agentRun struct {
+	id    string
+	input RunInputDTO
+	timer *time.Timer
+}
+
+func NewAgent(usecase *RunUseCase, token string) *Agent {
+	return &Agent{usecase: usecase, token: token}
+}
+
+// Handler returns the HTTP API controllers talk to, only controllers with the token of the agent get through
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", a.prepare)
+	mux.HandleFunc("POST /runs/{id}/start", a.start)
+	return RequireToken(a.token, mux)
+}
+
+// prepare validates a run and holds the agent for it until it's started
+func (a *Agent) prepare(w http.ResponseWriter, r *http.Request) {
+	var input RunInputDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeAgentError(w, http.StatusBadRequest, err)
+		return
+	}
+	// Controllers send the contents of their files, see ResolveLocalInput
+	if err := CheckRemoteInput(input); err != nil {
+		writeAgentError(w, http.StatusBadRequest, err)
+		return
+	}
+	if _, err := newTestRun(input); err != nil {
+		writeAgentError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.busy {
+		writeAgentError(w, http.StatusConflict, errors.New(ErrAgentBusy))
+		return
+	}
+	run := &agentRun{id: uuid.New().String(), input: input}
+	// A controller that never starts the run must not hold the agent forever
+	run.timer = time.AfterFunc(agentStartTimeout, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.pending == run {
+			a.pending = nil
+			a.busy = false
+		}
+	})
+	a.busy = true
+	a.pending = run
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"id": run.id})
+}
+
+// start runs a prepared run, streaming its partial results as JSON lines until it ends
+func (a *Agent) start(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	run := a.pending
+	if run == nil || run.id != r.PathValue("id") {
+		a.mu.Unlock()
+		writeAgentError(w, http.StatusNotFound, errors.New(ErrUnknownAgentRun))
+		return
+	}
+	run.timer.Stop()
+	a.pending = nil
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.busy = false
+	}()
+
+	// The partial report is fed the same measurements as the report of the run
+	partial := newCollector(context.Background(), false).expectInterval(run.input.expectedInterval())
+	ctx := WithObserver(r.Context(), Observer{Measured: partial.recordMeasurement})
+
+	type outcome struct {
+		output RunOutputDTO
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		output, err := a.usecase.Run(ctx, run.input)
+		done <- outcome{output, err}
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	flusher := http.NewResponseController(w)
+	send := func(message agentMessage) {
+		encoder.Encode(message)
+		flusher.Flush()
+	}
+
+	ticker := time.NewTicker(agentSnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			snapshot := partial.snapshot()
+			send(agentMessage{Snapshot: &snapshot})
+		case result := <-done:
+			snapshot := partial.snapshot()
+			snapshot.addModes(result.output)
+			message := agentMessage{Snapshot: &snapshot, Result: &result.output}
+			if result.err != nil {
+				message = agentMessage{Snapshot: &snapshot, Error: result.err.Error()}
+			}
+			send(message)
+			return
+		}
+	}
+}
+
+func writeAgentError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(agentMessage{Error: err.Error()})
+}
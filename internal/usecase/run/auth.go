@@ -0,0 +1,94 @@
+package run
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"stresstest/internal/auth"
+	"strings"
+)
+
+const (
+	ErrUnknownAuthType    = "unknown auth type, must be basic, bearer or oauth2"
+	ErrAuthHeaderConflict = "an Authorization header can't be combined with auth"
+)
+
+// newAuthenticator builds the authenticator described by the input, or nil when the run has no auth
+// OAuth2 tokens are fetched with a client of their own and refreshed until ctx is done
+func newAuthenticator(ctx context.Context, input AuthDTO, headers map[string]string, insecure bool) (auth.Authenticator, error) {
+	// The auth would overwrite the header, so neither would be sent as the user asked
+	if input.Type != "" {
+		for name := range headers {
+			if strings.EqualFold(name, "Authorization") {
+				return nil, errors.New(ErrAuthHeaderConflict)
+			}
+		}
+	}
+	switch input.Type {
+	case "":
+		return nil, nil
+	case "basic":
+		return auth.Basic(input.Username, input.Password), nil
+	case "bearer":
+		return auth.Bearer(input.Token, input.TokenEnv, input.TokenFile)
+	case "oauth2":
+		credentials := auth.NewClientCredentials(newTokenClient(insecure), auth.ClientCredentialsConfig{
+			TokenURL:     input.TokenURL,
+			ClientID:     input.ClientID,
+			ClientSecret: input.ClientSecret,
+			Scopes:       input.Scopes,
+		})
+		if err := credentials.Start(ctx); err != nil {
+			return nil, err
+		}
+		return credentials, nil
+	default:
+		return nil, errors.New(ErrUnknownAuthType)
+	}
+}
+
+// newTokenClient returns the client that fetches OAuth2 tokens
+// The token endpoint is another server, so the protocol, certificates and CA of the target don't apply to it
+func newTokenClient(insecure bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+	return &http.Client{Transport: transport}
+}
+
+// authReport summarizes the token fetches of the run, nil when the run has no auth
+func authReport(input AuthDTO, authenticator auth.Authenticator) *AuthReportDTO {
+	if authenticator == nil {
+		return nil
+	}
+	stats := authenticator.Stats()
+	return &AuthReportDTO{
+		Type:             input.Type,
+		TokenFetches:     stats.TokenFetches,
+		RefreshFailures:  stats.RefreshFailures,
+		LastRefreshError: stats.LastError,
+	}
+}
+
+// authTransport sets the Authorization header on every request
+// Reading the header is a memory access, so the measured latency only covers the request itself
+type authTransport struct {
+	base          http.RoundTripper
+	authenticator auth.Authenticator
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.authenticator.Authorization())
+	return t.base.RoundTrip(req)
+}
+
+// withAuth returns a client that authenticates every request, the original client is left untouched
+func withAuth(client *http.Client, authenticator auth.Authenticator) *http.Client {
+	if authenticator == nil {
+		return client
+	}
+	authenticated := *client
+	authenticated.Transport = &authTransport{base: client.Transport, authenticator: authenticator}
+	return &authenticated
+}
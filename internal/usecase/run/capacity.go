@@ -0,0 +1,176 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	ErrInvalidCapacityStrategy = "invalid capacity strategy, must be step or binary"
+	ErrInvalidCapacityRange    = "invalid capacity range, start, step and max must be positive and start can't be above max"
+	ErrEmptyCapacitySLO        = "capacity search needs at least one SLO, like p95<500"
+
+	CapacityStrategyStep   = "step"
+	CapacityStrategyBinary = "binary"
+
+	defaultRequestsPerVU = 50
+)
+
+// FindCapacity looks for the highest concurrency the target serves within the SLO.
+// Every level tried is a regular run, with its warm-up left out of the report, followed by a cool-down,
+// the step strategy climbs from start until the SLO is breached, the binary one narrows the range down to step
+func (u *RunUseCase) FindCapacity(ctx context.Context, input CapacityInputDTO) (CapacityOutputDTO, error) {
+
+	// Validate input
+	switch input.Strategy {
+	case "":
+		input.Strategy = CapacityStrategyStep
+	case CapacityStrategyStep, CapacityStrategyBinary:
+	default:
+		return CapacityOutputDTO{}, errors.New(ErrInvalidCapacityStrategy)
+	}
+	if input.Start <= 0 || input.Step <= 0 || input.Max < input.Start {
+		return CapacityOutputDTO{}, errors.New(ErrInvalidCapacityRange)
+	}
+	if len(input.SLO) == 0 {
+		return CapacityOutputDTO{}, errors.New(ErrEmptyCapacitySLO)
+	}
+	slo := make([]Threshold, 0, len(input.SLO))
+	for _, expression := range input.SLO {
+		threshold, err := ParseThreshold(expression)
+		if err != nil {
+			return CapacityOutputDTO{}, err
+		}
+		slo = append(slo, threshold)
+	}
+	if input.RequestsPerVU <= 0 {
+		input.RequestsPerVU = defaultRequestsPerVU
+	}
+	// The warm-up goes through the client of the level, so the measured requests find its connections open
+	if input.WarmupRequests > 0 {
+		input.Run.Warmup = WarmupDTO{Requests: input.WarmupRequests}
+	}
+	// A bad target fails here, before any level is tried
+	first := input.Run
+	first.Requests, first.Concurrency = input.RequestsPerVU*input.Start, input.Start
+	if err := ValidateInput(first); err != nil {
+		return CapacityOutputDTO{}, err
+	}
+
+	output := CapacityOutputDTO{Url: input.Run.Url, Strategy: input.Strategy, SLO: input.SLO}
+	try := func(concurrency int) (bool, error) {
+		if len(output.Steps) > 0 {
+			if err := sleep(ctx, time.Duration(input.CooldownInMs)*time.Millisecond); err != nil {
+				return false, err
+			}
+		}
+		step, err := u.capacityStep(ctx, input, slo, concurrency)
+		if err != nil {
+			return false, err
+		}
+		output.Steps = append(output.Steps, step)
+		if step.Passed {
+			output.MaxConcurrency = max(output.MaxConcurrency, concurrency)
+		}
+		return step.Passed, ctx.Err()
+	}
+
+	// Step: every level from start until one breaches the SLO
+	if input.Strategy == CapacityStrategyStep {
+		for concurrency := input.Start; concurrency <= input.Max; concurrency += input.Step {
+			passed, err := try(concurrency)
+			if err != nil {
+				return output, err
+			}
+			if !passed {
+				output.Breached = true
+				return output, nil
+			}
+		}
+		return output, nil
+	}
+
+	// Binary: start must pass and max must fail for the capacity to be in between
+	passed, err := try(input.Start)
+	if err != nil || !passed {
+		output.Breached = !passed
+		return output, err
+	}
+	if input.Max == input.Start {
+		return output, nil
+	}
+	passed, err = try(input.Max)
+	if err != nil || passed {
+		return output, err
+	}
+	output.Breached = true
+	low, high := input.Start, input.Max
+	for high-low > input.Step {
+		middle := (low + high) / 2
+		passed, err := try(middle)
+		if err != nil {
+			return output, err
+		}
+		if passed {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return output, nil
+}
+
+// capacityStep runs the target at the concurrency and checks the measured run against the SLO
+func (u *RunUseCase) capacityStep(ctx context.Context, input CapacityInputDTO, slo []Threshold, concurrency int) (CapacityStepDTO, error) {
+	runInput := input.Run
+	runInput.Id = ""
+	runInput.ShowData = false
+	runInput.Concurrency = concurrency
+	runInput.Requests = input.RequestsPerVU * concurrency
+
+	start := time.Now()
+	report, err := u.Run(ctx, runInput)
+	if err != nil {
+		return CapacityStepDTO{}, err
+	}
+	elapsed := time.Since(start)
+	if report.Warmup != nil {
+		elapsed -= time.Duration(report.Warmup.DurationInMs) * time.Millisecond
+	}
+
+	step := CapacityStepDTO{
+		RunId:        report.Id,
+		Concurrency:  concurrency,
+		Requests:     runInput.Requests,
+		DurationInMs: int(elapsed.Milliseconds()),
+		Thresholds:   EvaluateThresholds(report, slo),
+		Passed:       true,
+	}
+	if elapsed > 0 {
+		step.Throughput = float64(runInput.Requests) / elapsed.Seconds()
+	}
+	if total, failed := reportTotals(report); total != nil && total.Count > 0 {
+		step.P95, _ = thresholdActual("p95", *total, failed)
+		step.ErrorRate, _ = thresholdActual(ThresholdErrorRate, *total, failed)
+	}
+	for _, threshold := range step.Thresholds {
+		step.Passed = step.Passed && threshold.Passed
+	}
+	return step, nil
+}
+
+// sleep waits for d, returning early with the error of ctx when it's done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,311 @@
+package run
+
+import (
+	"context"
+	"crypto/tls"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// collector aggregates the results of a run as requests complete
+type collector struct {
+	mu         sync.Mutex
+	showData   bool
+	data       []DataOutputDTO
+	reportMap  map[string]*StatusReportDTO
+	histograms map[string]*histogram
+	// corrected has the histograms of the requests corrected for coordinated omission, when an interval is expected
+	corrected map[string]*histogram
+	interval  time.Duration
+	tls       *TLSReportDTO
+	protocols map[string]int
+	conns     map[string]int
+	traces    traceRecorder
+	observers []Observer
+}
+
+// newCollector returns a collector that also tells the observers of ctx about every measurement
+func newCollector(ctx context.Context, showData bool) *collector {
+	return &collector{
+		showData:   showData,
+		data:       make([]DataOutputDTO, 0),
+		reportMap:  make(map[string]*StatusReportDTO),
+		histograms: make(map[string]*histogram),
+		protocols:  make(map[string]int),
+		conns:      make(map[string]int),
+		observers:  observersFrom(ctx),
+	}
+}
+
+// record saves the result of a single request
+func (c *collector) record(result RequestResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Save data if requested
+	if c.showData {
+		c.data = append(c.data, DataOutputDTO{
+			StatusCode:            result.Status,
+			DurationInMs:          result.Duration,
+			Protocol:              result.Protocol,
+			RequestStartTimestamp: FormatTimeToUTCString(result.Start),
+			RequestEndTimestamp:   FormatTimeToUTCString(result.End),
+		})
+	}
+
+	// Save report data
+	status := strconv.Itoa(result.Status)
+	class := errorClass(result)
+	c.update(status, result.Duration, true)
+	c.update("total", result.Duration, true) // total for all statuses
+	c.traces.record(result, status, class)
+	c.notify(Measurement{
+		Status:        status,
+		Duration:      result.End.Sub(result.Start),
+		Request:       true,
+		Phases:        result.Phases,
+		BytesReceived: result.BytesReceived,
+		ErrorClass:    class,
+		Start:         result.Start,
+		Span:          result.Span,
+	})
+
+	if result.Handshake != nil {
+		c.recordHandshake(result.Handshake, result.HandshakeErr)
+	}
+	if result.Protocol != "" {
+		c.protocols[result.Protocol]++
+	}
+	if result.conn != "" {
+		c.conns[result.conn]++
+	}
+}
+
+// recordEvent saves a measurement that isn't an HTTP response, like a WebSocket message or a gRPC call
+// Events that count as requests of the run also go into the total and the data
+func (c *collector) recordEvent(status string, start, end time.Time, request bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	duration := int(end.Sub(start).Milliseconds())
+	c.update(status, duration, request)
+	c.notify(Measurement{Status: status, Duration: end.Sub(start), Request: request, Start: start})
+	if !request {
+		return
+	}
+	c.update("total", duration, true)
+
+	// Save data if requested
+	if c.showData {
+		c.data = append(c.data, DataOutputDTO{
+			Status:                status,
+			DurationInMs:          duration,
+			RequestStartTimestamp: FormatTimeToUTCString(start),
+			RequestEndTimestamp:   FormatTimeToUTCString(end),
+		})
+	}
+}
+
+// expectInterval makes the collector also keep the percentiles of requests corrected for coordinated omission,
+// given the interval at which a virtual user is expected to send them
+func (c *collector) expectInterval(intervalInMs int) *collector {
+	if intervalInMs > 0 {
+		c.interval = time.Duration(intervalInMs) * time.Millisecond
+		c.corrected = make(map[string]*histogram)
+	}
+	return c
+}
+
+// update adds a measurement to the row of status, callers must hold the lock
+// Only requests are corrected, events like connects don't stall a virtual user's schedule
+func (c *collector) update(status string, duration int, request bool) {
+	updateReport(c.reportMap, status, duration)
+	d := time.Duration(duration) * time.Millisecond
+	histogramOf(c.histograms, status).record(d)
+	if c.corrected != nil && request {
+		histogramOf(c.corrected, status).recordCorrected(d, c.interval)
+	}
+}
+
+// histogramOf returns the histogram of status, creating it when there's none
+func histogramOf(histograms map[string]*histogram, status string) *histogram {
+	h, exists := histograms[status]
+	if !exists {
+		h = newHistogram()
+		histograms[status] = h
+	}
+	return h
+}
+
+// notify tells the observers about a measurement, callers must hold the lock so they're told in order
+func (c *collector) notify(m Measurement) {
+	for _, observer := range c.observers {
+		if observer.Measured != nil {
+			observer.Measured(m)
+		}
+	}
+}
+
+// recordMeasurement adds a measurement an observer was told about to the report
+func (c *collector) recordMeasurement(m Measurement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	duration := int(m.Duration.Milliseconds())
+	c.update(m.Status, duration, m.Request)
+	if m.Request {
+		c.update("total", duration, true)
+	}
+}
+
+// snapshot returns the report so far along with the histograms of its rows
+func (c *collector) snapshot() agentSnapshot {
+	report := c.finalReport()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return agentSnapshot{Report: report, Histograms: copyHistograms(c.histograms), Corrected: copyHistograms(c.corrected)}
+}
+
+func copyHistograms(histograms map[string]*histogram) map[string]*histogram {
+	if histograms == nil {
+		return nil
+	}
+	copied := make(map[string]*histogram, len(histograms))
+	for status, h := range histograms {
+		copied[status] = copyHistogram(h)
+	}
+	return copied
+}
+
+func copyHistogram(h *histogram) *histogram {
+	copied := newHistogram()
+	copied.merge(h)
+	return copied
+}
+
+// merge adds the rows of a snapshot to the report, merging their histograms so percentiles stay exact
+func (c *collector) merge(s agentSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, row := range s.Report {
+		report, exists := c.reportMap[row.Status]
+		if !exists {
+			report = &StatusReportDTO{Status: row.Status, MinTime: row.MinTime, MaxTime: row.MaxTime}
+			c.reportMap[row.Status] = report
+		}
+		report.Count += row.Count
+		report.TotalTime += row.TotalTime
+		report.MinTime = min(report.MinTime, row.MinTime)
+		report.MaxTime = max(report.MaxTime, row.MaxTime)
+	}
+	for status, h := range s.Histograms {
+		histogramOf(c.histograms, status).merge(h)
+	}
+	if len(s.Corrected) > 0 && c.corrected == nil {
+		c.corrected = make(map[string]*histogram)
+	}
+	for status, h := range s.Corrected {
+		histogramOf(c.corrected, status).merge(h)
+	}
+}
+
+// recordHandshake counts a new TLS connection, callers must hold the lock
+func (c *collector) recordHandshake(state *tls.ConnectionState, err error) {
+	if c.tls == nil {
+		c.tls = &TLSReportDTO{Versions: make(map[string]int), CipherSuites: make(map[string]int)}
+	}
+	c.tls.Handshakes++
+	switch {
+	case err != nil:
+		c.tls.Failed++
+		return
+	case state.DidResume:
+		c.tls.Resumed++
+	default:
+		c.tls.Full++
+	}
+	c.tls.Versions[tls.VersionName(state.Version)]++
+	c.tls.CipherSuites[tls.CipherSuiteName(state.CipherSuite)]++
+}
+
+// tlsReport returns the TLS handshakes of the run, nil when the run made none
+func (c *collector) tlsReport() *TLSReportDTO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tls
+}
+
+// protocolReport returns the protocols the responses came with and how requests spread over connections,
+// nil when the run got no responses
+func (c *collector) protocolReport() *ProtocolReportDTO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.protocols) == 0 {
+		return nil
+	}
+
+	report := &ProtocolReportDTO{Negotiated: c.protocols, Connections: len(c.conns)}
+	streams := 0
+	for _, count := range c.conns {
+		streams += count
+		if count > report.MaxStreamsPerConnection {
+			report.MaxStreamsPerConnection = count
+		}
+	}
+	if report.Connections > 0 {
+		report.AvgStreamsPerConnection = float64(streams) / float64(report.Connections)
+	}
+	return report
+}
+
+// traceReport returns the slowest and failed sampled requests, nil when the run wasn't traced
+func (c *collector) traceReport() *TracesReportDTO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.traces.report()
+}
+
+// finalReport returns the report of every status with its average time calculated
+func (c *collector) finalReport() []StatusReportDTO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	finalReport := finalizeReport(c.reportMap)
+	for i, report := range finalReport {
+		if h, exists := c.histograms[report.Status]; exists {
+			percentiles := h.percentiles()
+			finalReport[i].Percentiles = &percentiles
+		}
+		if h, exists := c.corrected[report.Status]; exists {
+			percentiles := h.percentiles()
+			finalReport[i].CorrectedPercentiles = &percentiles
+		}
+	}
+	return finalReport
+}
+
+// updateReport updates the report map with the new data, callers must hold the lock protecting the map
+func updateReport(reportMap map[string]*StatusReportDTO, status string, duration int) {
+	report, exists := reportMap[status]
+	if !exists {
+		report = &StatusReportDTO{Status: status, MinTime: duration, MaxTime: duration}
+		reportMap[status] = report
+	}
+	report.Count++
+	report.TotalTime += duration
+	if duration < report.MinTime {
+		report.MinTime = duration
+	}
+	if duration > report.MaxTime {
+		report.MaxTime = duration
+	}
+}
+
+// finalizeReport calculates the average time of every status and flattens the map
+func finalizeReport(reportMap map[string]*StatusReportDTO) []StatusReportDTO {
+	var finalReport []StatusReportDTO
+	for _, report := range reportMap {
+		report.AverageTime = float64(report.TotalTime) / float64(report.Count)
+		finalReport = append(finalReport, *report)
+	}
+	return finalReport
+}
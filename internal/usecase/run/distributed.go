@@ -0,0 +1,424 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ErrTooManyAgents         = "concurrency must be at least the number of agents"
+	ErrNoAgentPrepared       = "no agent could prepare the run"
+	ErrAgentStreamEnded      = "agent stream ended before the run finished"
+	ErrUnexpectedAgentStatus = "agent answered with an unexpected status"
+	ErrDistributedObservers  = "observers and tracing don't receive the measurements of a distributed run"
+
+	AgentOK     = "ok"
+	AgentFailed = "failed"
+)
+
+// RunDistributed splits the run across agents, starts them together and merges what they send back into one output
+// An agent that fails is left out, or counts with what it sent before failing, and the run is marked degraded.
+// Agents are sent the token they expect and the contents of the files the input names, never the paths
+func (u *RunUseCase) RunDistributed(ctx context.Context, input RunInputDTO, agents []string, token string) (RunOutputDTO, error) {
+	testRun, err := newTestRun(input)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	input, err = ResolveLocalInput(ctx, input)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	if len(agents) > testRun.Concurrency {
+		return RunOutputDTO{}, errors.New(ErrTooManyAgents)
+	}
+	// Agents only send snapshots, so nothing would reach the observers
+	if _, traced := tracingFrom(ctx); len(observersFrom(ctx)) > 0 || traced {
+		return RunOutputDTO{}, errors.New(ErrDistributedObservers)
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	remotes := make([]*remoteAgent, len(agents))
+	for i, address := range agents {
+		share := input
+		share.Requests = splitShare(testRun.Requests, len(agents), i)
+		share.Concurrency = splitShare(testRun.Concurrency, len(agents), i)
+		share.Warmup.Requests = splitShare(input.Warmup.Requests, len(agents), i)
+		remotes[i] = newRemoteAgent(address, token, share)
+	}
+
+	// Every agent is prepared before any is started, so they all start together
+	var wg sync.WaitGroup
+	for _, remote := range remotes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			remote.fail(remote.prepare(ctx))
+		}()
+	}
+	wg.Wait()
+
+	prepared := 0
+	for _, remote := range remotes {
+		if remote.report.Status != AgentFailed {
+			prepared++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				remote.fail(remote.run(ctx))
+			}()
+		}
+	}
+	if prepared == 0 {
+		return RunOutputDTO{}, errors.New(ErrNoAgentPrepared)
+	}
+	wg.Wait()
+
+	// Merge what every agent sent
+	results := newCollector(ctx, input.ShowData)
+	output := RunOutputDTO{
+		Id:          testRun.Id,
+		Url:         testRun.Url,
+		Method:      testRun.Method,
+		Requests:    testRun.Requests,
+		Concurrency: testRun.Concurrency,
+	}
+	for _, remote := range remotes {
+		if remote.snapshot != nil {
+			results.merge(*remote.snapshot)
+		}
+		if remote.result != nil {
+			mergeOutput(&output, *remote.result)
+		}
+		if remote.report.Status == AgentFailed {
+			output.Degraded = true
+		} else {
+			remote.report.Status = AgentOK
+		}
+		output.Agents = append(output.Agents, remote.report)
+	}
+	// Agents warm up together, the run starts once the slowest one is done
+	start := testRun.Timestamp
+	if output.Warmup != nil {
+		start = start.Add(time.Duration(output.Warmup.DurationInMs) * time.Millisecond)
+	}
+	output.TimestampStart = FormatTimeToUTCString(start)
+	output.TimestampEnd = FormatTimeToUTCString(time.Now())
+	output.TestDurationInSeconds = int(time.Since(start).Seconds())
+	output.Report = results.finalReport()
+	mergeModePercentiles(&output, remotes)
+	mergeWarmup(&output, remotes)
+	return output, nil
+}
+
+// mergeModePercentiles sets the percentiles of the SSE and QUIC reports from the histograms of the agents
+// that finished, like the rows of the report
+func mergeModePercentiles(output *RunOutputDTO, remotes []*remoteAgent) {
+	firstEvent, gaps, handshakes := newHistogram(), newHistogram(), newHistogram()
+	for _, remote := range remotes {
+		if remote.result == nil || remote.snapshot == nil {
+			continue
+		}
+		firstEvent.merge(remote.snapshot.SSEFirstEvent)
+		gaps.merge(remote.snapshot.SSEEventGap)
+		handshakes.merge(remote.snapshot.QUICHandshakes)
+	}
+	if output.SSE != nil {
+		output.SSE.TimeToFirstEvent = firstEvent.percentiles()
+		output.SSE.EventGap = gaps.percentiles()
+	}
+	if output.QUIC != nil {
+		output.QUIC.HandshakeTime = handshakes.percentiles()
+	}
+}
+
+// mergeWarmup sets the rows of the warm-up from the histograms of the agents that finished, like the rows of the report
+func mergeWarmup(output *RunOutputDTO, remotes []*remoteAgent) {
+	if output.Warmup == nil {
+		return
+	}
+	warm := newCollector(context.Background(), false)
+	for _, remote := range remotes {
+		if remote.result == nil || remote.result.Warmup == nil || remote.snapshot == nil {
+			continue
+		}
+		warm.merge(agentSnapshot{Report: remote.result.Warmup.Report, Histograms: remote.snapshot.Warmup})
+	}
+	output.Warmup.Report = warm.finalReport()
+}
+
+// splitShare returns the part of total agent i gets, spreading the remainder over the first agents
+// Splitting requests and concurrency the same way keeps every agent with at least as many requests as virtual users
+func splitShare(total, agents, i int) int {
+	share := total / agents
+	if i < total%agents {
+		share++
+	}
+	return share
+}
+
+// remoteAgent is an agent taking part in a distributed run, with the last results it sent
+type remoteAgent struct {
+	base     string
+	token    string
+	input    RunInputDTO
+	runID    string
+	report   AgentReportDTO
+	snapshot *agentSnapshot
+	result   *RunOutputDTO
+}
+
+func newRemoteAgent(address, token string, input RunInputDTO) *remoteAgent {
+	base := address
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &remoteAgent{
+		base:   strings.TrimSuffix(base, "/"),
+		token:  token,
+		input:  input,
+		report: AgentReportDTO{Address: address, Requests: input.Requests, Concurrency: input.Concurrency},
+	}
+}
+
+// fail marks the agent failed when err isn't nil
+func (a *remoteAgent) fail(err error) {
+	if err != nil {
+		a.report.Status = AgentFailed
+		a.report.Error = err.Error()
+	}
+}
+
+// prepare sends the agent its share of the run
+func (a *remoteAgent) prepare(ctx context.Context) error {
+	body, err := json.Marshal(a.input)
+	if err != nil {
+		return err
+	}
+	resp, err := a.post(ctx, "/runs", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var prepared struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&prepared); err != nil {
+		return err
+	}
+	a.runID = prepared.ID
+	return nil
+}
+
+// run starts the prepared run and keeps the partial results the agent streams until it ends
+func (a *remoteAgent) run(ctx context.Context) error {
+	resp, err := a.post(ctx, "/runs/"+a.runID+"/start", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message agentMessage
+		if err := decoder.Decode(&message); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New(ErrAgentStreamEnded)
+			}
+			return err
+		}
+		if message.Snapshot != nil {
+			a.snapshot = message.Snapshot
+		}
+		if message.Error != "" {
+			return errors.New(message.Error)
+		}
+		if message.Result != nil {
+			a.result = message.Result
+			return nil
+		}
+	}
+}
+
+// post sends a request to the agent, turning the error it answers with into an error
+func (a *remoteAgent) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var message agentMessage
+		if json.NewDecoder(resp.Body).Decode(&message) == nil && message.Error != "" {
+			return nil, errors.New(message.Error)
+		}
+		return nil, errors.New(ErrUnexpectedAgentStatus)
+	}
+	return resp, nil
+}
+
+// mergeOutput adds the output of an agent to the output of the distributed run, except for the report
+// and the percentiles of the mode reports, which come from the histograms of the agents.
+// Rates of agents running at the same time add up
+func mergeOutput(dst *RunOutputDTO, src RunOutputDTO) {
+	if dst.Mode == "" {
+		dst.Mode = src.Mode
+	}
+	dst.Data = append(dst.Data, src.Data...)
+
+	if src.Auth != nil {
+		if dst.Auth == nil {
+			dst.Auth = &AuthReportDTO{Type: src.Auth.Type}
+		}
+		dst.Auth.TokenFetches += src.Auth.TokenFetches
+		dst.Auth.RefreshFailures += src.Auth.RefreshFailures
+		if src.Auth.LastRefreshError != "" {
+			dst.Auth.LastRefreshError = src.Auth.LastRefreshError
+		}
+	}
+	dst.TLS = mergeTLS(dst.TLS, src.TLS)
+	if src.WebSocket != nil {
+		if dst.WebSocket == nil {
+			dst.WebSocket = &WebSocketReportDTO{CloseCodes: make(map[string]int)}
+		}
+		dst.WebSocket.Connections += src.WebSocket.Connections
+		dst.WebSocket.FailedConnections += src.WebSocket.FailedConnections
+		dst.WebSocket.MessagesSent += src.WebSocket.MessagesSent
+		dst.WebSocket.MessagesReceived += src.WebSocket.MessagesReceived
+		dst.WebSocket.MessagesPerSecond += src.WebSocket.MessagesPerSecond
+		dst.WebSocket.Disconnects += src.WebSocket.Disconnects
+		addCounts(dst.WebSocket.CloseCodes, src.WebSocket.CloseCodes)
+	}
+	if src.GRPC != nil {
+		if dst.GRPC == nil {
+			dst.GRPC = &GRPCReportDTO{Method: src.GRPC.Method, CallType: src.GRPC.CallType}
+		}
+		dst.GRPC.MessagesSent += src.GRPC.MessagesSent
+		dst.GRPC.MessagesReceived += src.GRPC.MessagesReceived
+	}
+	dst.Traces = mergeTraces(dst.Traces, src.Traces)
+	if src.SSE != nil {
+		if dst.SSE == nil {
+			dst.SSE = &SSEReportDTO{EventTypes: make(map[string]int)}
+		}
+		dst.SSE.Streams += src.SSE.Streams
+		dst.SSE.FailedConnections += src.SSE.FailedConnections
+		dst.SSE.EventsReceived += src.SSE.EventsReceived
+		dst.SSE.EventsPerSecond += src.SSE.EventsPerSecond
+		dst.SSE.Reconnects += src.SSE.Reconnects
+		dst.SSE.Drops += src.SSE.Drops
+		addCounts(dst.SSE.EventTypes, src.SSE.EventTypes)
+	}
+	if src.GraphQL != nil {
+		if dst.GraphQL == nil {
+			dst.GraphQL = &GraphQLReportDTO{Operation: src.GraphQL.Operation, ErrorClasses: make(map[string]int)}
+		}
+		dst.GraphQL.Responses += src.GraphQL.Responses
+		dst.GraphQL.WithErrors += src.GraphQL.WithErrors
+		addCounts(dst.GraphQL.ErrorClasses, src.GraphQL.ErrorClasses)
+	}
+	if src.Protocol != nil {
+		if dst.Protocol == nil {
+			dst.Protocol = &ProtocolReportDTO{Negotiated: make(map[string]int)}
+		}
+		// The average is weighted by the connections of each agent
+		streams := dst.Protocol.AvgStreamsPerConnection*float64(dst.Protocol.Connections) +
+			src.Protocol.AvgStreamsPerConnection*float64(src.Protocol.Connections)
+		dst.Protocol.Connections += src.Protocol.Connections
+		if dst.Protocol.Connections > 0 {
+			dst.Protocol.AvgStreamsPerConnection = streams / float64(dst.Protocol.Connections)
+		}
+		dst.Protocol.MaxStreamsPerConnection = max(dst.Protocol.MaxStreamsPerConnection, src.Protocol.MaxStreamsPerConnection)
+		addCounts(dst.Protocol.Negotiated, src.Protocol.Negotiated)
+	}
+	if src.QUIC != nil {
+		if dst.QUIC == nil {
+			dst.QUIC = &QUICReportDTO{}
+		}
+		dst.QUIC.Connections += src.QUIC.Connections
+		dst.QUIC.FailedConnections += src.QUIC.FailedConnections
+		dst.QUIC.Used0RTT += src.QUIC.Used0RTT
+		dst.QUIC.Migrations += src.QUIC.Migrations
+		dst.QUIC.PacketsSent += src.QUIC.PacketsSent
+		dst.QUIC.PacketsLost += src.QUIC.PacketsLost
+		dst.QUIC.BytesLost += src.QUIC.BytesLost
+	}
+	if src.Socket != nil {
+		if dst.Socket == nil {
+			dst.Socket = &SocketReportDTO{}
+		}
+		dst.Socket.Connections += src.Socket.Connections
+		dst.Socket.FailedConnections += src.Socket.FailedConnections
+		dst.Socket.BytesSent += src.Socket.BytesSent
+		dst.Socket.BytesReceived += src.Socket.BytesReceived
+		dst.Socket.BytesPerSecond += src.Socket.BytesPerSecond
+	}
+	if src.Retries != nil {
+		if dst.Retries == nil {
+			dst.Retries = &RetryReportDTO{FirstAttempt: make(map[string]int), FinalOutcome: make(map[string]int), RetriedOutcomes: make(map[string]int)}
+		}
+		r := dst.Retries
+		r.Requests += src.Retries.Requests
+		r.Retried += src.Retries.Retried
+		r.Recovered += src.Retries.Recovered
+		r.Exhausted += src.Retries.Exhausted
+		r.Attempts += src.Retries.Attempts
+		r.MaxAttempts = max(r.MaxAttempts, src.Retries.MaxAttempts)
+		r.RetryAfterWaits += src.Retries.RetryAfterWaits
+		addCounts(r.FirstAttempt, src.Retries.FirstAttempt)
+		addCounts(r.FinalOutcome, src.Retries.FinalOutcome)
+		addCounts(r.RetriedOutcomes, src.Retries.RetriedOutcomes)
+		if r.Requests > 0 {
+			r.AttemptsPerRequest = float64(r.Attempts) / float64(r.Requests)
+		}
+	}
+	if src.Warmup != nil {
+		if dst.Warmup == nil {
+			dst.Warmup = &WarmupReportDTO{}
+		}
+		// Agents warm up together, so the warm-up lasts as long as the slowest one
+		dst.Warmup.Requests += src.Warmup.Requests
+		dst.Warmup.DurationInMs = max(dst.Warmup.DurationInMs, src.Warmup.DurationInMs)
+		dst.Warmup.TLS = mergeTLS(dst.Warmup.TLS, src.Warmup.TLS)
+	}
+}
+
+func mergeTLS(dst, src *TLSReportDTO) *TLSReportDTO {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = &TLSReportDTO{Versions: make(map[string]int), CipherSuites: make(map[string]int)}
+	}
+	dst.Handshakes += src.Handshakes
+	dst.Full += src.Full
+	dst.Resumed += src.Resumed
+	dst.Failed += src.Failed
+	addCounts(dst.Versions, src.Versions)
+	addCounts(dst.CipherSuites, src.CipherSuites)
+	return dst
+}
+
+func addCounts(dst, src map[string]int) {
+	for key, count := range src {
+		dst[key] += count
+	}
+}
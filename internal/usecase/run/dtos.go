@@ -1,36 +1,383 @@
 package run
 
 type RunInputDTO struct {
-	Url         string `json:"url"`
+	Id          string            `json:"id,omitempty"` // a new one is generated when empty
+	Url         string            `json:"url"`
+	Requests    int               `json:"requests"`
+	Concurrency int               `json:"concurrency"`
+	ShowData    bool              `json:"show_data"`
+	Method      string            `json:"method,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        string            `json:"body,omitempty"`
+	Transport   TransportDTO      `json:"transport"`
+	Auth        AuthDTO           `json:"auth"`
+	Session     SessionDTO        `json:"session"`
+	WebSocket   WebSocketDTO      `json:"websocket"`
+	GRPC        GRPCDTO           `json:"grpc"`
+	SSE         SSEDTO            `json:"sse"`
+	GraphQL     GraphQLDTO        `json:"graphql"`
+	Socket      SocketDTO         `json:"socket"`
+	Warmup      WarmupDTO         `json:"warmup"`
+	// ExpectedIntervalInMs is how often a virtual user is expected to send a request, correcting the percentiles
+	// for coordinated omission when set
+	ExpectedIntervalInMs int `json:"expected_interval_in_ms,omitempty"`
+	// ThinkTime is the pause of a virtual user after every request, and PacingInMs how often it starts one
+	ThinkTime  ThinkTimeDTO `json:"think_time"`
+	PacingInMs int          `json:"pacing_in_ms,omitempty"`
+	Retry      RetryDTO     `json:"retry"`
+}
+
+// RetryDTO is the policy requests are retried with, retries are off while MaxRetries is 0
+type RetryDTO struct {
+	MaxRetries    int   `json:"max_retries,omitempty"`
+	Statuses      []int `json:"statuses,omitempty"`         // 429 and 503 when empty, 0 retries requests without response
+	BaseDelayInMs int   `json:"base_delay_in_ms,omitempty"` // first backoff, doubled on every retry
+	MaxDelayInMs  int   `json:"max_delay_in_ms,omitempty"`  // cap of the backoff and of the Retry-After
+}
+
+// ThinkTimeDTO is drawn from the distribution: constant and exponential use the mean,
+// uniform the min and max, normal the mean and standard deviation
+type ThinkTimeDTO struct {
+	Distribution string `json:"distribution,omitempty"` // constant, uniform, normal or exponential
+	MeanInMs     int    `json:"mean_in_ms,omitempty"`
+	MinInMs      int    `json:"min_in_ms,omitempty"`
+	MaxInMs      int    `json:"max_in_ms,omitempty"`
+	StdDevInMs   int    `json:"std_dev_in_ms,omitempty"`
+}
+
+// WarmupDTO is sent before the run and reported apart from it, either a number of requests or a duration
+type WarmupDTO struct {
+	Requests     int `json:"requests,omitempty"`
+	DurationInMs int `json:"duration_in_ms,omitempty"`
+}
+
+type SocketDTO struct {
+	PayloadHex           string `json:"payload_hex,omitempty"`
+	PayloadFile          string `json:"payload_file,omitempty"`
+	ResponseLength       int    `json:"response_length,omitempty"`        // bytes the response has
+	ResponseDelimiterHex string `json:"response_delimiter_hex,omitempty"` // bytes the response ends with
+	TimeoutInMs          int    `json:"timeout_in_ms,omitempty"`
+}
+
+type GraphQLDTO struct {
+	Query         string `json:"query,omitempty"`
+	OperationName string `json:"operation_name,omitempty"`
+	Variables     string `json:"variables,omitempty"` // JSON object, rendered as a text/template on every request
+}
+
+type SSEDTO struct {
+	Enabled      bool `json:"enabled"`
+	DurationInMs int  `json:"duration_in_ms,omitempty"` // how long the streams are held open
+}
+
+type GRPCDTO struct {
+	Method      string   `json:"method,omitempty"` // package.Service/Method
+	Data        string   `json:"data,omitempty"`   // JSON message, or an array of messages for client streaming calls
+	ProtoFiles  []string `json:"proto_files,omitempty"`
+	ImportPaths []string `json:"import_paths,omitempty"`
+	Descriptors []byte   `json:"descriptors,omitempty"` // serialized FileDescriptorSet, used like the .proto files
+	TimeoutInMs int      `json:"timeout_in_ms,omitempty"`
+}
+
+type WebSocketDTO struct {
+	Messages []string `json:"messages,omitempty"`
+	Rate     float64  `json:"rate,omitempty"` // messages per second per connection, 0 waits for each reply
+}
+
+type SessionDTO struct {
+	Mode      string `json:"mode,omitempty"` // shared or isolated, empty keeps no cookies
+	PoolPerVU bool   `json:"pool_per_vu"`
+}
+
+type AuthDTO struct {
+	Type         string   `json:"type,omitempty"` // basic, bearer or oauth2
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Token        string   `json:"token,omitempty"`
+	TokenEnv     string   `json:"token_env,omitempty"`
+	TokenFile    string   `json:"token_file,omitempty"`
+	TokenURL     string   `json:"token_url,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+}
+
+type TransportDTO struct {
+	Insecure           bool     `json:"insecure"`
+	DisableCompression bool     `json:"disable_compression"`
+	CertFile           string   `json:"cert_file,omitempty"`
+	KeyFile            string   `json:"key_file,omitempty"`
+	CAFile             string   `json:"ca_file,omitempty"`
+	CertPEM            string   `json:"cert_pem,omitempty"` // contents of the files, used when the files aren't given
+	KeyPEM             string   `json:"key_pem,omitempty"`
+	CAPEM              string   `json:"ca_pem,omitempty"`
+	ServerName         string   `json:"server_name,omitempty"`
+	MinTLSVersion      string   `json:"min_tls_version,omitempty"` // 1.0, 1.1, 1.2 or 1.3
+	MaxTLSVersion      string   `json:"max_tls_version,omitempty"`
+	CipherSuites       []string `json:"cipher_suites,omitempty"`
+	Protocol           string   `json:"protocol,omitempty"`    // http1, h2, h2c or h3, empty lets ALPN choose
+	Connections        int      `json:"connections,omitempty"` // fixed number of connections the virtual users multiplex over
+}
+
+type RunOutputDTO struct {
+	Id                    string               `json:"id"`
+	Mode                  string               `json:"mode"`
+	Url                   string               `json:"url"`
+	Method                string               `json:"method"`
+	Requests              int                  `json:"requests"`
+	Concurrency           int                  `json:"concurrency"`
+	TimestampStart        string               `json:"timestamp_start"`
+	TimestampEnd          string               `json:"timestamp_end"`
+	TestDurationInSeconds int                  `json:"test_duration_in_seconds"`
+	Data                  []DataOutputDTO      `json:"data"`
+	Report                []StatusReportDTO    `json:"report"`
+	Auth                  *AuthReportDTO       `json:"auth,omitempty"`
+	TLS                   *TLSReportDTO        `json:"tls,omitempty"`
+	WebSocket             *WebSocketReportDTO  `json:"websocket,omitempty"`
+	GRPC                  *GRPCReportDTO       `json:"grpc,omitempty"`
+	SSE                   *SSEReportDTO        `json:"sse,omitempty"`
+	GraphQL               *GraphQLReportDTO    `json:"graphql,omitempty"`
+	Protocol              *ProtocolReportDTO   `json:"protocol,omitempty"`
+	QUIC                  *QUICReportDTO       `json:"quic,omitempty"`
+	Socket                *SocketReportDTO     `json:"socket,omitempty"`
+	Traces                *TracesReportDTO     `json:"traces,omitempty"`
+	Warmup                *WarmupReportDTO     `json:"warmup,omitempty"`
+	Retries               *RetryReportDTO      `json:"retries,omitempty"`
+	Thresholds            []ThresholdResultDTO `json:"thresholds,omitempty"`
+	Degraded              bool                 `json:"degraded,omitempty"` // some agent of a distributed run failed
+	Agents                []AgentReportDTO     `json:"agents,omitempty"`
+}
+
+// RetryReportDTO tells how the requests of a run went through their attempts, a request is counted once with the
+// outcome of its last attempt
+type RetryReportDTO struct {
+	Requests           int            `json:"requests"`
+	Retried            int            `json:"retried"`   // requests sent more than once
+	Recovered          int            `json:"recovered"` // retried requests that ended with a status that isn't retried
+	Exhausted          int            `json:"exhausted"` // retried requests that ran out of retries
+	Attempts           int            `json:"attempts"`
+	AttemptsPerRequest float64        `json:"attempts_per_request"`
+	MaxAttempts        int            `json:"max_attempts"`
+	RetryAfterWaits    int            `json:"retry_after_waits"` // waits that came from a Retry-After instead of the backoff
+	FirstAttempt       map[string]int `json:"first_attempt"`     // requests by the status of their first attempt
+	FinalOutcome       map[string]int `json:"final_outcome"`     // requests by the status of their last attempt
+	RetriedOutcomes    map[string]int `json:"retried_outcomes"`  // retried requests by first and last status, like 429 → 200
+}
+
+// WarmupReportDTO holds the requests of the warm-up, left out of the report of the run
+type WarmupReportDTO struct {
+	Requests     int               `json:"requests"`
+	DurationInMs int               `json:"duration_in_ms"`
+	Report       []StatusReportDTO `json:"report"`
+	TLS          *TLSReportDTO     `json:"tls,omitempty"` // connections are mostly opened during the warm-up
+	// Histograms the percentiles of the rows come from, see SSEReportDTO
+	histograms map[string]*histogram
+}
+
+type ThresholdResultDTO struct {
+	Threshold string  `json:"threshold"` // as it was given, like p95<500
+	Metric    string  `json:"metric"`
+	Operator  string  `json:"operator"`
+	Expected  float64 `json:"expected"`
+	Actual    float64 `json:"actual"`
+	Measured  bool    `json:"measured"` // false when the run has no value for the metric
+	Passed    bool    `json:"passed"`
+}
+
+// TracesReportDTO lists sampled requests of a traced run, to be looked up in the tracing backend
+type TracesReportDTO struct {
+	Slowest []TraceDTO `json:"slowest"`
+	Failed  []TraceDTO `json:"failed,omitempty"`
+}
+
+type TraceDTO struct {
+	TraceId               string `json:"trace_id"`
+	SpanId                string `json:"span_id"`
+	Status                string `json:"status"`
+	DurationInMs          int    `json:"duration_in_ms"`
+	Error                 string `json:"error,omitempty"` // error class, see ErrorClassTimeout
+	RequestStartTimestamp string `json:"request_start_timestamp"`
+}
+
+type AgentReportDTO struct {
+	Address     string `json:"address"`
 	Requests    int    `json:"requests"`
 	Concurrency int    `json:"concurrency"`
-	ShowData    bool   `json:"show_data"`
+	Status      string `json:"status"` // ok or failed
+	Error       string `json:"error,omitempty"`
 }
 
-type RunOutputDTO struct {
-	Id                    string            `json:"id"`
-	Url                   string            `json:"url"`
-	Requests              int               `json:"requests"`
-	Concurrency           int               `json:"concurrency"`
-	TimestampStart        string            `json:"timestamp_start"`
-	TimestampEnd          string            `json:"timestamp_end"`
-	TestDurationInSeconds int               `json:"test_duration_in_seconds"`
-	Data                  []DataOutputDTO   `json:"data"`
-	Report                []StatusReportDTO `json:"report"`
+type SocketReportDTO struct {
+	Connections       int     `json:"connections"`
+	FailedConnections int     `json:"failed_connections"`
+	BytesSent         int64   `json:"bytes_sent"`
+	BytesReceived     int64   `json:"bytes_received"`
+	BytesPerSecond    float64 `json:"bytes_per_second"`
+}
+
+type QUICReportDTO struct {
+	Connections       int            `json:"connections"`
+	FailedConnections int            `json:"failed_connections"`
+	HandshakeTime     PercentilesDTO `json:"handshake_time"`
+	Used0RTT          int            `json:"used_0rtt"`  // connections resumed with 0-RTT
+	Migrations        int            `json:"migrations"` // connections that moved to another network path
+	PacketsSent       uint64         `json:"packets_sent"`
+	PacketsLost       uint64         `json:"packets_lost"`
+	BytesLost         uint64         `json:"bytes_lost"`
+	// Histogram the handshake time comes from, see SSEReportDTO
+	handshakes *histogram
+}
+
+type ProtocolReportDTO struct {
+	Negotiated              map[string]int `json:"negotiated"` // responses by protocol, like HTTP/2.0
+	Connections             int            `json:"connections"`
+	AvgStreamsPerConnection float64        `json:"avg_streams_per_connection"`
+	MaxStreamsPerConnection int            `json:"max_streams_per_connection"`
+}
+
+type GraphQLReportDTO struct {
+	Operation    string         `json:"operation"`
+	Responses    int            `json:"responses"`
+	WithErrors   int            `json:"with_errors"`
+	ErrorClasses map[string]int `json:"error_classes"`
+}
+
+type SSEReportDTO struct {
+	Streams           int            `json:"streams"`
+	FailedConnections int            `json:"failed_connections"`
+	EventsReceived    int            `json:"events_received"`
+	EventsPerSecond   float64        `json:"events_per_second"`
+	Reconnects        int            `json:"reconnects"`
+	Drops             int            `json:"drops"`
+	EventTypes        map[string]int `json:"event_types"`
+	TimeToFirstEvent  PercentilesDTO `json:"time_to_first_event"`
+	EventGap          PercentilesDTO `json:"event_gap"`
+	// Histograms the percentiles come from, agents send them apart so distributed runs can merge them
+	firstEvent *histogram
+	gaps       *histogram
+}
+
+// PercentilesDTO holds the percentiles of a measurement, in milliseconds
+type PercentilesDTO struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+type GRPCReportDTO struct {
+	Method           string `json:"method"`
+	CallType         string `json:"call_type"`
+	MessagesSent     int    `json:"messages_sent"`
+	MessagesReceived int    `json:"messages_received"`
+}
+
+type WebSocketReportDTO struct {
+	Connections       int            `json:"connections"`
+	FailedConnections int            `json:"failed_connections"`
+	MessagesSent      int            `json:"messages_sent"`
+	MessagesReceived  int            `json:"messages_received"`
+	MessagesPerSecond float64        `json:"messages_per_second"`
+	Disconnects       int            `json:"disconnects"`
+	CloseCodes        map[string]int `json:"close_codes"`
+}
+
+type AuthReportDTO struct {
+	Type             string `json:"type"`
+	TokenFetches     int    `json:"token_fetches"`
+	RefreshFailures  int    `json:"refresh_failures"`
+	LastRefreshError string `json:"last_refresh_error,omitempty"`
+}
+
+type TLSReportDTO struct {
+	Handshakes   int            `json:"handshakes"`
+	Full         int            `json:"full"`
+	Resumed      int            `json:"resumed"`
+	Failed       int            `json:"failed"`
+	Versions     map[string]int `json:"versions"`
+	CipherSuites map[string]int `json:"cipher_suites"`
 }
 
 type DataOutputDTO struct {
 	StatusCode            int    `json:"status_code"`
+	Status                string `json:"status,omitempty"`
 	DurationInMs          int    `json:"duration_in_ms"`
+	Protocol              string `json:"protocol,omitempty"`
 	RequestStartTimestamp string `json:"request_start_timestamp"`
 	RequestEndTimestamp   string `json:"request_end_timestamp"`
 }
 
 type StatusReportDTO struct {
-	Status      string  `json:"status"`
-	Count       int     `json:"count"`
-	MinTime     int     `json:"min_time_in_ms"`
-	MaxTime     int     `json:"max_time_in_ms"`
-	TotalTime   int     `json:"total_time_in_ms"`
-	AverageTime float64 `json:"average_time_in_ms"`
+	Status      string          `json:"status"`
+	Count       int             `json:"count"`
+	MinTime     int             `json:"min_time_in_ms"`
+	MaxTime     int             `json:"max_time_in_ms"`
+	TotalTime   int             `json:"total_time_in_ms"`
+	AverageTime float64         `json:"average_time_in_ms"`
+	Percentiles *PercentilesDTO `json:"percentiles,omitempty"`
+	// CorrectedPercentiles add the requests a stall kept virtual users from sending, see RunInputDTO.ExpectedIntervalInMs
+	CorrectedPercentiles *PercentilesDTO `json:"corrected_percentiles,omitempty"`
+}
+
+type ReplayInputDTO struct {
+	LogFile     string            `json:"log_file"`
+	Format      string            `json:"format"`
+	Target      string            `json:"target"`
+	Concurrency int               `json:"concurrency"`
+	KeepTiming  bool              `json:"keep_timing"`
+	Speed       float64           `json:"speed"`
+	ShowData    bool              `json:"show_data"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Transport   TransportDTO      `json:"transport"`
+	Auth        AuthDTO           `json:"auth"`
+}
+
+type ReplayOutputDTO struct {
+	RunOutputDTO
+	LogFile      string              `json:"log_file"`
+	SkippedLines int                 `json:"skipped_lines"`
+	KeepTiming   bool                `json:"keep_timing"`
+	Speed        float64             `json:"speed"`
+	Comparison   ReplayComparisonDTO `json:"comparison"`
+}
+
+type ReplayComparisonDTO struct {
+	Compared         int             `json:"compared"`
+	StatusMatches    int             `json:"status_matches"`
+	Recorded         StatusReportDTO `json:"recorded"`
+	Replayed         StatusReportDTO `json:"replayed"`
+	AverageDeltaInMs float64         `json:"average_delta_in_ms"`
+}
+
+type CapacityInputDTO struct {
+	Run            RunInputDTO `json:"run"`      // requests and concurrency are set by every step
+	SLO            []string    `json:"slo"`      // thresholds every step must pass, like p95<500
+	Strategy       string      `json:"strategy"` // step or binary
+	Start          int         `json:"start"`
+	Step           int         `json:"step"` // increment of the step strategy, resolution of the binary one
+	Max            int         `json:"max"`
+	RequestsPerVU  int         `json:"requests_per_vu"`
+	WarmupRequests int         `json:"warmup_requests"` // warm-up of every step, see WarmupDTO
+	CooldownInMs   int         `json:"cooldown_in_ms"`  // pause between steps
+}
+
+type CapacityOutputDTO struct {
+	Url            string            `json:"url"`
+	Strategy       string            `json:"strategy"`
+	SLO            []string          `json:"slo"`
+	MaxConcurrency int               `json:"max_concurrency"` // highest level that passed, 0 when none did
+	Breached       bool              `json:"breached"`        // false when max passed, the capacity may be higher
+	Steps          []CapacityStepDTO `json:"steps"`
+}
+
+type CapacityStepDTO struct {
+	RunId        string               `json:"run_id"`
+	Concurrency  int                  `json:"concurrency"`
+	Requests     int                  `json:"requests"`
+	DurationInMs int                  `json:"duration_in_ms"`
+	Throughput   float64              `json:"throughput"` // requests per second
+	P95          float64              `json:"p95_in_ms"`
+	ErrorRate    float64              `json:"error_rate"`
+	Passed       bool                 `json:"passed"`
+	Thresholds   []ThresholdResultDTO `json:"thresholds"`
 }
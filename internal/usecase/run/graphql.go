@@ -0,0 +1,277 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"stresstest/internal/auth"
+	"stresstest/internal/entity"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ErrInvalidGraphQLVariables = "graphql variables must render to a JSON object"
+
+	// GraphQLAnonymous names the operation of queries without a name
+	GraphQLAnonymous = "anonymous"
+	// GraphQLError is the class of errors without an extensions.code
+	GraphQLError = "GRAPHQL_ERROR"
+	// GraphQLInvalidResponse is the class of responses that aren't a GraphQL result
+	GraphQLInvalidResponse = "INVALID_RESPONSE"
+	// GraphQLTemplateError is the class of requests not sent because their variables didn't render
+	GraphQLTemplateError = "template"
+)
+
+// operationRegex finds the first named operation of a document
+var operationRegex = regexp.MustCompile(`(?m)^\s*(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// graphQLTemplateFuncs are the functions available to the variables template
+var graphQLTemplateFuncs = template.FuncMap{
+	"uuid": func() string { return uuid.New().String() },
+	// randInt returns a number in [min, max]
+	"randInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + rand.IntN(max-min+1)
+	},
+	"now": func() int64 { return time.Now().UnixMilli() },
+}
+
+// graphQLVariablesData is what the variables template is rendered with on every request
+type graphQLVariablesData struct {
+	VU        int // index of the virtual user sending the request
+	Iteration int // index of the request in the run, starting at 0
+}
+
+// runGraphQL POSTs the query on every request, rendering its variables each time
+// GraphQL answers errors with status 200, so responses are also reported by operation and error class
+func (u *RunUseCase) runGraphQL(ctx context.Context, input RunInputDTO, testRun *entity.TestRun, clients []*http.Client, authenticator auth.Authenticator) (RunOutputDTO, error) {
+	operation := input.GraphQL.OperationName
+	if operation == "" {
+		operation = GraphQLOperationName(input.GraphQL.Query)
+	}
+
+	variables, err := template.New("variables").Funcs(graphQLTemplateFuncs).Parse(input.GraphQL.Variables)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	// The template is rendered once up front so a broken one fails the run before it starts
+	if _, err := renderGraphQLVariables(variables, graphQLVariablesData{}); err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	headers := map[string]string{"Content-Type": "application/json", "Accept": "application/json"}
+	for name, value := range testRun.Headers {
+		headers[name] = value
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	var wg sync.WaitGroup
+	var sent atomic.Int64
+	results := newCollector(ctx, input.ShowData).expectInterval(input.expectedInterval())
+	stats := &graphQLStats{errorClasses: make(map[string]int)}
+	warm := newWarmup(input.Warmup)
+	retries := newRetrier(input.Retry)
+	var warmed atomic.Int64
+
+	for vu, client := range clients {
+		wg.Add(1)
+
+		go func(vu int, client *http.Client) {
+			defer wg.Done()
+
+			// Templates that only break for some requests skip them, counted under GraphQLTemplateError
+			graphQLRequestFor := func(iteration int64) (HTTPRequest, error) {
+				vars, err := renderGraphQLVariables(variables, graphQLVariablesData{VU: vu, Iteration: int(iteration - 1)})
+				if err != nil {
+					return HTTPRequest{}, err
+				}
+				body, err := json.Marshal(graphQLRequest{Query: input.GraphQL.Query, OperationName: input.GraphQL.OperationName, Variables: vars})
+				return HTTPRequest{Method: http.MethodPost, Url: testRun.Url, Headers: headers, Body: string(body)}, err
+			}
+
+			pace := newPacer(input)
+			for {
+				if ctx.Err() == nil && warm.claim() {
+					request, err := graphQLRequestFor(warmed.Add(1))
+					// Paced even when the template breaks, or a warm-up by duration would spin on it
+					if pace.wait(ctx) != nil {
+						return
+					}
+					if err != nil {
+						now := time.Now()
+						warm.results.recordEvent(operation+" "+GraphQLTemplateError, now, now, false)
+						continue
+					}
+					result, _ := retries.send(ctx, client, request, nil)
+					warm.results.record(result)
+					continue
+				}
+				iteration := sent.Add(1)
+				if ctx.Err() != nil || iteration > int64(testRun.Requests) {
+					return
+				}
+				request, err := graphQLRequestFor(iteration)
+				if err != nil {
+					now := time.Now()
+					results.recordEvent(operation+" "+GraphQLTemplateError, now, now, false)
+					stats.skip(GraphQLTemplateError)
+					continue
+				}
+				if pace.wait(ctx) != nil {
+					return
+				}
+
+				var class string
+				result, attempts := retries.send(ctx, client, request, func(resp *http.Response) {
+					class = graphQLErrorClass(resp.Body)
+				})
+				retries.track(attempts, result.Status)
+				results.record(result)
+				if result.Status == 0 {
+					continue
+				}
+				results.recordEvent(operation+" "+class, result.Start, result.End, false)
+				stats.add(class)
+			}
+		}(vu, client)
+	}
+
+	wg.Wait() // Wait for all requests to finish
+	start := warm.measuredFrom(testRun.Timestamp)
+
+	// Return output
+	return RunOutputDTO{
+		Id:                    testRun.Id,
+		Mode:                  ModeGraphQL,
+		Url:                   testRun.Url,
+		Method:                http.MethodPost,
+		Requests:              testRun.Requests,
+		Concurrency:           testRun.Concurrency,
+		TimestampStart:        FormatTimeToUTCString(start),
+		TimestampEnd:          FormatTimeToUTCString(time.Now()),
+		TestDurationInSeconds: int(time.Since(start).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Auth:                  authReport(input.Auth, authenticator),
+		TLS:                   results.tlsReport(),
+		Protocol:              results.protocolReport(),
+		Traces:                results.traceReport(),
+		QUIC:                  quicReport(clients),
+		GraphQL:               stats.report(operation),
+		Warmup:                warm.report(),
+		Retries:               retries.report(),
+	}, nil
+}
+
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
+	} `json:"errors"`
+}
+
+// GraphQLOperationName returns the name of the first operation in a query, or anonymous when it has none
+func GraphQLOperationName(query string) string {
+	if match := operationRegex.FindStringSubmatch(query); match != nil {
+		return match[1]
+	}
+	return GraphQLAnonymous
+}
+
+// graphQLErrorClass reads a response and returns OK, the code of its first error or why it isn't a GraphQL result
+func graphQLErrorClass(body io.Reader) string {
+	var response graphQLResponse
+	if err := json.NewDecoder(body).Decode(&response); err != nil {
+		return GraphQLInvalidResponse
+	}
+	if len(response.Errors) == 0 {
+		if response.Data == nil {
+			return GraphQLInvalidResponse
+		}
+		return "OK"
+	}
+	if code := response.Errors[0].Extensions.Code; code != "" {
+		return code
+	}
+	return GraphQLError
+}
+
+// renderGraphQLVariables renders the variables of a request, nil when the query has none
+func renderGraphQLVariables(variables *template.Template, data graphQLVariablesData) (json.RawMessage, error) {
+	var rendered bytes.Buffer
+	if err := variables.Execute(&rendered, data); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rendered.String()) == "" {
+		return nil, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(rendered.Bytes(), &object); err != nil {
+		return nil, errors.New(ErrInvalidGraphQLVariables)
+	}
+	return rendered.Bytes(), nil
+}
+
+// graphQLStats counts the responses of a GraphQL run by error class
+type graphQLStats struct {
+	mu           sync.Mutex
+	responses    int
+	withErrors   int
+	errorClasses map[string]int
+}
+
+func (s *graphQLStats) add(class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses++
+	if class != "OK" {
+		s.withErrors++
+		s.errorClasses[class]++
+	}
+}
+
+// skip counts a request that wasn't sent, so it has no response
+func (s *graphQLStats) skip(class string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorClasses[class]++
+}
+
+func (s *graphQLStats) report(operation string) *GraphQLReportDTO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &GraphQLReportDTO{
+		Operation:    operation,
+		Responses:    s.responses,
+		WithErrors:   s.withErrors,
+		ErrorClasses: s.errorClasses,
+	}
+}
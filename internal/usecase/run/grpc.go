@@ -0,0 +1,304 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"stresstest/internal/auth"
+	"stresstest/internal/entity"
+	"stresstest/internal/grpcdesc"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	ErrMissingGRPCMethod = "grpc runs need the method to call"
+	ErrGRPCSingleMessage = "only client streaming calls can send more than one message"
+
+	// grpcReflectionTimeout bounds how long the server has to describe its services
+	grpcReflectionTimeout = 10 * time.Second
+)
+
+const (
+	GRPCUnary        = "unary"
+	GRPCServerStream = "server_stream"
+	GRPCClientStream = "client_stream"
+	GRPCBidiStream   = "bidi_stream"
+)
+
+// runGRPC calls a gRPC method on every request, building the messages from JSON with the method's descriptors
+// The report is grouped by gRPC status code instead of HTTP status
+func (u *RunUseCase) runGRPC(ctx context.Context, input RunInputDTO, testRun *entity.TestRun, authenticator auth.Authenticator) (RunOutputDTO, error) {
+	if input.GRPC.Method == "" {
+		return RunOutputDTO{}, errors.New(ErrMissingGRPCMethod)
+	}
+	if err := input.Session.validate(); err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	// grpcs:// uses the run's TLS options, grpc:// is plaintext
+	target, err := url.Parse(testRun.Url)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	creds := insecure.NewCredentials()
+	if target.Scheme == "grpcs" {
+		tlsConfig, err := NewTLSConfig(input.Transport)
+		if err != nil {
+			return RunOutputDTO{}, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	dial := func() (*grpc.ClientConn, error) {
+		return grpc.NewClient(target.Host, grpc.WithTransportCredentials(creds))
+	}
+
+	// Every virtual user shares one connection unless asked for its own pool
+	shared, err := dial()
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	defer shared.Close()
+	conns := make([]*grpc.ClientConn, testRun.Concurrency)
+	for vu := range conns {
+		conns[vu] = shared
+		if input.Session.PoolPerVU {
+			if conns[vu], err = dial(); err != nil {
+				return RunOutputDTO{}, err
+			}
+			defer conns[vu].Close()
+		}
+	}
+
+	method, err := resolveGRPCMethod(ctx, shared, input.GRPC)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	messages, err := parseGRPCMessages(method.Input(), input.GRPC.Data)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	if len(messages) != 1 && !method.IsStreamingClient() {
+		return RunOutputDTO{}, errors.New(ErrGRPCSingleMessage)
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	call := &grpcCall{
+		method:        method,
+		fullName:      grpcdesc.FullMethodName(method),
+		messages:      messages,
+		headers:       testRun.Headers,
+		authenticator: authenticator,
+		timeout:       time.Duration(input.GRPC.TimeoutInMs) * time.Millisecond,
+	}
+
+	// Run the Stress Test
+	var wg sync.WaitGroup
+	var sent atomic.Int64
+	results := newCollector(ctx, input.ShowData).expectInterval(input.expectedInterval())
+
+	for _, conn := range conns {
+		wg.Add(1)
+
+		go func(conn *grpc.ClientConn) {
+			defer wg.Done()
+
+			for ctx.Err() == nil && sent.Add(1) <= int64(testRun.Requests) {
+				start := time.Now()
+				code := call.do(ctx, conn)
+				results.recordEvent(GRPCStatusName(code), start, time.Now(), true)
+			}
+		}(conn)
+	}
+
+	wg.Wait() // Wait for all requests to finish
+
+	// Return output
+	return RunOutputDTO{
+		Id:                    testRun.Id,
+		Mode:                  ModeGRPC,
+		Url:                   testRun.Url,
+		Requests:              testRun.Requests,
+		Concurrency:           testRun.Concurrency,
+		TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+		TimestampEnd:          FormatTimeToUTCString(time.Now()),
+		TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Auth:                  authReport(input.Auth, authenticator),
+		GRPC: &GRPCReportDTO{
+			Method:           call.fullName,
+			CallType:         grpcCallType(method),
+			MessagesSent:     int(call.sent.Load()),
+			MessagesReceived: int(call.received.Load()),
+		},
+	}, nil
+}
+
+// resolveGRPCMethod finds the method in the given .proto files or descriptors or, without them, through server reflection
+func resolveGRPCMethod(ctx context.Context, conn *grpc.ClientConn, opts GRPCDTO) (protoreflect.MethodDescriptor, error) {
+	var resolver grpcdesc.Resolver
+	var err error
+	if len(opts.ProtoFiles) > 0 {
+		resolver, err = grpcdesc.FromProtoFiles(ctx, opts.ProtoFiles, opts.ImportPaths)
+	} else if len(opts.Descriptors) > 0 {
+		resolver, err = grpcdesc.FromDescriptorSet(opts.Descriptors)
+	} else {
+		reflectionCtx, cancel := context.WithTimeout(ctx, grpcReflectionTimeout)
+		defer cancel()
+		resolver, err = grpcdesc.FromReflection(reflectionCtx, conn, opts.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return grpcdesc.FindMethod(resolver, opts.Method)
+}
+
+// parseGRPCMessages builds the request messages from JSON, an array meaning one message per element
+func parseGRPCMessages(descriptor protoreflect.MessageDescriptor, data string) ([]*dynamicpb.Message, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		data = "{}"
+	}
+
+	raws := []json.RawMessage{json.RawMessage(data)}
+	if strings.HasPrefix(data, "[") {
+		if err := json.Unmarshal([]byte(data), &raws); err != nil {
+			return nil, err
+		}
+	}
+
+	messages := make([]*dynamicpb.Message, 0, len(raws))
+	for _, raw := range raws {
+		message := dynamicpb.NewMessage(descriptor)
+		if err := protojson.Unmarshal(raw, message); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// grpcCall is the call every virtual user makes, counting the messages of streaming calls
+type grpcCall struct {
+	method        protoreflect.MethodDescriptor
+	fullName      string
+	messages      []*dynamicpb.Message
+	headers       map[string]string
+	authenticator auth.Authenticator
+	timeout       time.Duration
+	sent          atomic.Int64
+	received      atomic.Int64
+}
+
+// do makes a single call and returns its status code
+func (c *grpcCall) do(ctx context.Context, conn *grpc.ClientConn) codes.Code {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// Headers are sent as metadata
+	md := metadata.MD{}
+	for name, value := range c.headers {
+		md.Set(name, value)
+	}
+	if c.authenticator != nil {
+		md.Set("authorization", c.authenticator.Authorization())
+	}
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	if !c.method.IsStreamingClient() && !c.method.IsStreamingServer() {
+		response := dynamicpb.NewMessage(c.method.Output())
+		err := conn.Invoke(ctx, c.fullName, c.messages[0], response)
+		c.sent.Add(1)
+		if err == nil {
+			c.received.Add(1)
+		}
+		return status.Code(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	desc := &grpc.StreamDesc{ServerStreams: c.method.IsStreamingServer(), ClientStreams: c.method.IsStreamingClient()}
+	stream, err := conn.NewStream(ctx, desc, c.fullName)
+	if err != nil {
+		return status.Code(err)
+	}
+
+	// Replies are read while the messages are sent, so bidi calls never block on a full window
+	done := make(chan error, 1)
+	go func() {
+		for {
+			response := dynamicpb.NewMessage(c.method.Output())
+			if err := stream.RecvMsg(response); err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				done <- err
+				return
+			}
+			c.received.Add(1)
+		}
+	}()
+
+	for _, message := range c.messages {
+		if err := stream.SendMsg(message); err != nil {
+			// The real error comes from the receiving side
+			break
+		}
+		c.sent.Add(1)
+	}
+	stream.CloseSend()
+	return status.Code(<-done)
+}
+
+// GRPCStatusName returns the status code the way gRPC documents it, like DEADLINE_EXCEEDED
+func GRPCStatusName(code codes.Code) string {
+	name := code.String()
+	if name == "OK" {
+		return name
+	}
+	var snake strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			snake.WriteRune('_')
+		}
+		snake.WriteRune(unicode.ToUpper(r))
+	}
+	return snake.String()
+}
+
+func grpcCallType(method protoreflect.MethodDescriptor) string {
+	switch {
+	case method.IsStreamingClient() && method.IsStreamingServer():
+		return GRPCBidiStream
+	case method.IsStreamingClient():
+		return GRPCClientStream
+	case method.IsStreamingServer():
+		return GRPCServerStream
+	default:
+		return GRPCUnary
+	}
+}
@@ -0,0 +1,203 @@
+package run
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const ProtocolH3 = "h3"
+
+// quicTransport sends requests over HTTP/3, keeping every QUIC connection it dials to report on them
+type quicTransport struct {
+	http3 *http3.Transport
+	stats *quicStats
+}
+
+func newQUICTransport(tlsConfig *tls.Config, disableCompression bool) *quicTransport {
+	t := &quicTransport{stats: &quicStats{handshakes: newHistogram()}}
+	t.http3 = &http3.Transport{
+		TLSClientConfig:    tlsConfig,
+		QUICConfig:         &quic.Config{},
+		DisableCompression: disableCompression,
+		Dial:               t.dial,
+	}
+	return t
+}
+
+func (t *quicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.http3.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// HTTP/3 traces a new connection value on every request, the QUIC connection of the host is what identifies it
+	if trace := requestTraceFrom(req.Context()); trace != nil {
+		host := req.URL.Host
+		if req.URL.Port() == "" {
+			host += ":443"
+		}
+		if id := t.stats.current(host); id != "" {
+			trace.gotConn(id)
+		}
+	}
+	return resp, nil
+}
+
+// dial opens a QUIC connection and waits for its handshake, with 0-RTT when the session can be resumed
+func (t *quicTransport) dial(ctx context.Context, addr string, tlsConfig *tls.Config, config *quic.Config) (*quic.Conn, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	start := time.Now()
+	conn, err := quic.DialAddrEarly(ctx, addr, tlsConfig, config)
+	if err == nil {
+		select {
+		case <-conn.HandshakeComplete():
+		case <-ctx.Done():
+			conn.CloseWithError(0, "")
+			err = context.Cause(ctx)
+		}
+	}
+	if err != nil {
+		t.stats.add(func(s *quicStats) { s.failed++ })
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(tls.ConnectionState{}, err)
+		}
+		return nil, err
+	}
+
+	state := conn.ConnectionState()
+	if trace != nil && trace.TLSHandshakeDone != nil {
+		trace.TLSHandshakeDone(state.TLS, nil)
+	}
+	t.stats.add(func(s *quicStats) {
+		s.handshakes.record(time.Since(start))
+		if state.Used0RTT {
+			s.used0RTT++
+		}
+		s.conns = append(s.conns, quicConn{conn: conn, id: connID(conn.LocalAddr(), conn.RemoteAddr()), host: addr, local: conn.LocalAddr().String(), remote: conn.RemoteAddr().String()})
+	})
+	// A closed connection is added to the totals and dropped, so it isn't kept until the run ends
+	go func() {
+		<-conn.Context().Done()
+		t.stats.add(func(s *quicStats) { s.retire(conn) })
+	}()
+	return conn, nil
+}
+
+func (t *quicTransport) CloseIdleConnections() {
+	t.http3.CloseIdleConnections()
+}
+
+// quicConn is a dialed connection along with the path it started on
+type quicConn struct {
+	conn   *quic.Conn
+	id     string
+	host   string
+	local  string
+	remote string
+}
+
+// addTo counts the connection, its packets and whether it migrated in the report
+func (c quicConn) addTo(report *QUICReportDTO) {
+	stats := c.conn.ConnectionStats()
+	report.Connections++
+	report.PacketsSent += stats.PacketsSent
+	report.PacketsLost += stats.PacketsLost
+	report.BytesLost += stats.BytesLost
+	// A connection that ends on another path than it started on has migrated
+	if c.conn.LocalAddr().String() != c.local || c.conn.RemoteAddr().String() != c.remote {
+		report.Migrations++
+	}
+}
+
+// quicStats counts the QUIC connections of a transport
+type quicStats struct {
+	mu sync.Mutex
+	// conns are the connections still open, closed has what the closed ones added up to
+	conns      []quicConn
+	closed     QUICReportDTO
+	failed     int
+	used0RTT   int
+	handshakes *histogram
+}
+
+func (s *quicStats) add(update func(s *quicStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s)
+}
+
+// retire adds a closed connection to the totals and forgets it
+func (s *quicStats) retire(conn *quic.Conn) {
+	for i, c := range s.conns {
+		if c.conn == conn {
+			c.addTo(&s.closed)
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+// current returns the id of the latest connection dialed to host
+func (s *quicStats) current(host string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.conns) - 1; i >= 0; i-- {
+		if s.conns[i].host == host {
+			return s.conns[i].id
+		}
+	}
+	return ""
+}
+
+// quicReport adds up the QUIC connections of every client of a run, nil when none of them used HTTP/3
+func quicReport(clients []*http.Client) *QUICReportDTO {
+	seen := make(map[*quicStats]bool)
+	handshakes := newHistogram()
+	var report *QUICReportDTO
+
+	for _, client := range clients {
+		transport, ok := unwrapTransport(client.Transport).(*quicTransport)
+		if !ok || seen[transport.stats] {
+			continue
+		}
+		seen[transport.stats] = true
+		if report == nil {
+			report = &QUICReportDTO{}
+		}
+
+		s := transport.stats
+		s.mu.Lock()
+		report.Connections += s.closed.Connections
+		report.PacketsSent += s.closed.PacketsSent
+		report.PacketsLost += s.closed.PacketsLost
+		report.BytesLost += s.closed.BytesLost
+		report.Migrations += s.closed.Migrations
+		report.FailedConnections += s.failed
+		report.Used0RTT += s.used0RTT
+		handshakes.merge(s.handshakes)
+		for _, c := range s.conns {
+			c.addTo(report)
+		}
+		s.mu.Unlock()
+	}
+
+	if report != nil {
+		report.HandshakeTime = handshakes.percentiles()
+		report.handshakes = handshakes
+	}
+	return report
+}
+
+// unwrapTransport returns the transport under the run's auth
+func unwrapTransport(transport http.RoundTripper) http.RoundTripper {
+	if auth, ok := transport.(*authTransport); ok {
+		return auth.base
+	}
+	return transport
+}
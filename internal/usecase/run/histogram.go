@@ -0,0 +1,125 @@
+package run
+
+import (
+	"encoding/json"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// histogramPrecisionBits is how many significant bits a bucket keeps, bounding the error of a percentile to under 1%
+const histogramPrecisionBits = 7
+
+// histogram counts durations in log-linear buckets of microseconds
+// Percentiles are read from the buckets, so memory doesn't grow with the number of samples
+type histogram struct {
+	counts map[int]int64
+	total  int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make(map[int]int64)}
+}
+
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[histogramBucket(uint64(d.Microseconds()))]++
+	h.total++
+}
+
+// recordCorrected records d and, like HdrHistogram does for coordinated omission, the samples a stall of d kept
+// from being sent: one every interval, with the latency each would have seen, down to the interval
+func (h *histogram) recordCorrected(d, interval time.Duration) {
+	h.record(d)
+	if interval <= 0 {
+		return
+	}
+	for missing := d - interval; missing >= interval; missing -= interval {
+		h.record(missing)
+	}
+}
+
+// merge adds the counts of another histogram, the result is the same as if every sample was recorded here
+func (h *histogram) merge(other *histogram) {
+	if other == nil {
+		return
+	}
+	for bucket, count := range other.counts {
+		h.counts[bucket] += count
+	}
+	h.total += other.total
+}
+
+// percentile returns the value below which p percent of the samples fall, zero when there are no samples
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	buckets := make([]int, 0, len(h.counts))
+	for bucket := range h.counts {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	var seen int64
+	for _, bucket := range buckets {
+		seen += h.counts[bucket]
+		if seen >= rank {
+			return time.Duration(histogramValue(bucket)) * time.Microsecond
+		}
+	}
+	return time.Duration(histogramValue(buckets[len(buckets)-1])) * time.Microsecond
+}
+
+// percentiles returns the percentiles shown in reports, in milliseconds
+func (h *histogram) percentiles() PercentilesDTO {
+	ms := func(p float64) float64 {
+		return float64(h.percentile(p).Microseconds()) / 1000
+	}
+	return PercentilesDTO{P50: ms(50), P90: ms(90), P95: ms(95), P99: ms(99)}
+}
+
+// histogramBucket keeps the value exact while it fits the precision, and only its top bits after that
+func histogramBucket(v uint64) int {
+	shift := bits.Len64(v) - histogramPrecisionBits
+	if shift <= 0 {
+		return int(v)
+	}
+	return shift<<histogramPrecisionBits | int(v>>shift)
+}
+
+// histogramValue returns the middle of the range a bucket covers
+func histogramValue(bucket int) uint64 {
+	shift := bucket >> histogramPrecisionBits
+	mantissa := uint64(bucket & (1<<histogramPrecisionBits - 1))
+	if shift == 0 {
+		return mantissa
+	}
+	return mantissa<<shift + 1<<(shift-1)
+}
+
+// MarshalJSON writes the bucket counts, so histograms of different machines can be merged
+func (h *histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.counts)
+}
+
+func (h *histogram) UnmarshalJSON(data []byte) error {
+	counts := make(map[int]int64)
+	if err := json.Unmarshal(data, &counts); err != nil {
+		return err
+	}
+	h.counts = counts
+	h.total = 0
+	for _, count := range counts {
+		h.total += count
+	}
+	return nil
+}
@@ -4,4 +4,6 @@ import "context"
 
 type RunUseCaseInterface interface {
 	Run(ctx context.Context, input RunInputDTO) (RunOutputDTO, error)
+	RunDistributed(ctx context.Context, input RunInputDTO, agents []string, token string) (RunOutputDTO, error)
+	Replay(ctx context.Context, input ReplayInputDTO) (ReplayOutputDTO, error)
 }
@@ -0,0 +1,133 @@
+package run
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Phases of an HTTP request, as traced while it's sent
+const (
+	PhaseDNS     = "dns"
+	PhaseConnect = "connect"
+	PhaseTLS     = "tls"
+	PhaseWait    = "wait" // from the request being written to the first byte of the response
+)
+
+// Classes of requests that failed, transport errors first and then the error statuses of HTTP
+const (
+	ErrorClassTimeout  = "timeout"
+	ErrorClassCanceled = "canceled"
+	ErrorClassDNS      = "dns"
+	ErrorClassRefused  = "connection_refused"
+	ErrorClassReset    = "connection_reset"
+	ErrorClassTLS      = "tls"
+	ErrorClassOther    = "other"
+	ErrorClassHTTP4xx  = "http_4xx"
+	ErrorClassHTTP5xx  = "http_5xx"
+)
+
+// Measurement is a result of a run as it goes into the report
+type Measurement struct {
+	Status   string
+	Start    time.Time
+	Duration time.Duration
+	// Request tells if the measurement counts as a request of the run, and so goes into the total
+	Request bool
+	// Phases, BytesReceived and ErrorClass are only known for HTTP requests
+	Phases        map[string]time.Duration
+	BytesReceived int64
+	ErrorClass    string
+	// Span is only known for HTTP requests of a traced run
+	Span *SpanContext
+}
+
+// Observer is told about a run as it happens, every field is optional
+type Observer struct {
+	// Measured is called for every measurement recorded in the report
+	Measured func(m Measurement)
+	// InFlight is called with 1 when an HTTP request is sent and -1 when it's done
+	InFlight func(delta int)
+}
+
+type observerKey struct{}
+
+// WithObserver returns a context that makes the runs started with it tell the observer what happens
+// Observers added to a context that already has some are told after them
+func WithObserver(ctx context.Context, observer Observer) context.Context {
+	previous := observersFrom(ctx)
+	// The slice is capped so contexts derived from the same parent never share observers
+	observers := append(previous[:len(previous):len(previous)], observer)
+	return context.WithValue(ctx, observerKey{}, observers)
+}
+
+func observersFrom(ctx context.Context) []Observer {
+	observers, _ := ctx.Value(observerKey{}).([]Observer)
+	return observers
+}
+
+func notifyInFlight(ctx context.Context, delta int) {
+	for _, observer := range observersFrom(ctx) {
+		if observer.InFlight != nil {
+			observer.InFlight(delta)
+		}
+	}
+}
+
+// errorClass tells why a request failed, empty when it didn't
+func errorClass(result RequestResult) string {
+	if result.Err == nil {
+		switch {
+		case result.Status >= 500:
+			return ErrorClassHTTP5xx
+		case result.Status >= 400:
+			return ErrorClassHTTP4xx
+		}
+		return ""
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var recordErr tls.RecordHeaderError
+	var alert tls.AlertError
+	switch err := result.Err; {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ErrorClassReset
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &recordErr), errors.As(err, &alert):
+		return ErrorClassTLS
+	}
+	return ErrorClassOther
+}
+
+// LiveReport aggregates the measurements of a run while it happens, for callers that show partial results
+type LiveReport struct {
+	results *collector
+}
+
+func NewLiveReport() *LiveReport {
+	return &LiveReport{results: newCollector(context.Background(), false)}
+}
+
+// Observer returns the observer that feeds the report, to be passed to WithObserver
+func (l *LiveReport) Observer() Observer {
+	return Observer{Measured: l.results.recordMeasurement}
+}
+
+// Report returns the report of the measurements so far
+func (l *LiveReport) Report() []StatusReportDTO {
+	return l.results.finalReport()
+}
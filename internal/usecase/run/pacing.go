@@ -0,0 +1,154 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+const (
+	ErrInvalidThinkTime   = "invalid think time, use a duration like 500ms, uniform:200ms-800ms, normal:500ms,100ms or exponential:500ms"
+	ErrInvalidPacing      = "invalid pacing, must not be negative"
+	ErrPacingNotSupported = "think time and pacing are only supported for HTTP and GraphQL runs"
+
+	// Distributions of the think time
+	ThinkTimeConstant    = "constant"
+	ThinkTimeUniform     = "uniform"
+	ThinkTimeNormal      = "normal"
+	ThinkTimeExponential = "exponential"
+)
+
+// ParseThinkTime reads a think time like 500ms (constant), uniform:200ms-800ms, normal:500ms,100ms (mean and
+// standard deviation) or exponential:500ms (mean)
+func ParseThinkTime(value string) (ThinkTimeDTO, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ThinkTimeDTO{}, nil
+	}
+	distribution, params, found := strings.Cut(value, ":")
+	if !found {
+		distribution, params = ThinkTimeConstant, value
+	}
+
+	var durations []int
+	separator := ","
+	if distribution == ThinkTimeUniform {
+		separator = "-"
+	}
+	for _, param := range strings.Split(params, separator) {
+		d, err := time.ParseDuration(strings.TrimSpace(param))
+		if err != nil {
+			return ThinkTimeDTO{}, errors.New(ErrInvalidThinkTime)
+		}
+		durations = append(durations, int(d.Milliseconds()))
+	}
+
+	thinkTime := ThinkTimeDTO{Distribution: distribution}
+	switch {
+	case distribution == ThinkTimeUniform && len(durations) == 2:
+		thinkTime.MinInMs, thinkTime.MaxInMs = durations[0], durations[1]
+	case distribution == ThinkTimeNormal && len(durations) == 2:
+		thinkTime.MeanInMs, thinkTime.StdDevInMs = durations[0], durations[1]
+	case (distribution == ThinkTimeConstant || distribution == ThinkTimeExponential) && len(durations) == 1:
+		thinkTime.MeanInMs = durations[0]
+	default:
+		return ThinkTimeDTO{}, errors.New(ErrInvalidThinkTime)
+	}
+	if _, err := thinkTime.sampler(); err != nil {
+		return ThinkTimeDTO{}, err
+	}
+	return thinkTime, nil
+}
+
+// sampler returns the func that draws think times from the distribution, nil when there's no think time
+func (t ThinkTimeDTO) sampler() (func() time.Duration, error) {
+	if t.MinInMs < 0 || t.MaxInMs < 0 || t.MeanInMs < 0 || t.StdDevInMs < 0 {
+		return nil, errors.New(ErrInvalidThinkTime)
+	}
+	ms := func(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+	mean := float64(t.MeanInMs)
+	switch t.Distribution {
+	case "":
+		return nil, nil
+	case ThinkTimeConstant:
+		return func() time.Duration { return ms(mean) }, nil
+	case ThinkTimeUniform:
+		if t.MaxInMs < t.MinInMs {
+			return nil, errors.New(ErrInvalidThinkTime)
+		}
+		low, spread := float64(t.MinInMs), float64(t.MaxInMs-t.MinInMs)
+		return func() time.Duration { return ms(low + rand.Float64()*spread) }, nil
+	case ThinkTimeNormal:
+		// Draws below zero are no pause at all
+		stdDev := float64(t.StdDevInMs)
+		return func() time.Duration { return ms(max(0, mean+rand.NormFloat64()*stdDev)) }, nil
+	case ThinkTimeExponential:
+		return func() time.Duration { return ms(rand.ExpFloat64() * mean) }, nil
+	default:
+		return nil, errors.New(ErrInvalidThinkTime)
+	}
+}
+
+// validatePacing checks the think time and pacing of the input, which only request loops support
+func validatePacing(input RunInputDTO, streaming bool) error {
+	if _, err := input.ThinkTime.sampler(); err != nil {
+		return err
+	}
+	if input.PacingInMs < 0 {
+		return errors.New(ErrInvalidPacing)
+	}
+	if streaming && (input.ThinkTime.Distribution != "" || input.PacingInMs > 0) {
+		return errors.New(ErrPacingNotSupported)
+	}
+	return nil
+}
+
+// expectedInterval is the interval the percentiles are corrected for, paced runs expect one request per pacing
+func (input RunInputDTO) expectedInterval() int {
+	if input.ExpectedIntervalInMs > 0 {
+		return input.ExpectedIntervalInMs
+	}
+	return input.PacingInMs
+}
+
+// pacer spaces the iterations of a virtual user: it pauses for a think time after every request and,
+// with pacing, starts an iteration every interval. An iteration that runs late starts the next one right away,
+// without bursts to catch up
+type pacer struct {
+	thinkTime func() time.Duration
+	pacing    time.Duration
+	next      time.Time
+	started   bool
+}
+
+// newPacer returns the pacer of a virtual user, nil when the run sends requests back to back
+func newPacer(input RunInputDTO) *pacer {
+	thinkTime, _ := input.ThinkTime.sampler()
+	if thinkTime == nil && input.PacingInMs <= 0 {
+		return nil
+	}
+	return &pacer{thinkTime: thinkTime, pacing: time.Duration(input.PacingInMs) * time.Millisecond}
+}
+
+// wait blocks until the next iteration of the virtual user may start, returning the error of ctx when it's done
+func (p *pacer) wait(ctx context.Context) error {
+	if p == nil {
+		return ctx.Err()
+	}
+	if p.started && p.thinkTime != nil {
+		if err := sleep(ctx, p.thinkTime()); err != nil {
+			return err
+		}
+	}
+	p.started = true
+	if p.pacing <= 0 {
+		return nil
+	}
+	if err := sleep(ctx, time.Until(p.next)); err != nil {
+		return err
+	}
+	p.next = time.Now().Add(p.pacing)
+	return nil
+}
@@ -0,0 +1,82 @@
+package run
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"stresstest/internal/auth"
+	"stresstest/internal/grpcdesc"
+	"strings"
+)
+
+const (
+	ErrLocalInput   = "runs received over the network can't name local files or environment variables, send their contents instead"
+	ErrInvalidToken = "missing or invalid token"
+)
+
+// CheckRemoteInput refuses the fields that make the process read its own files or environment,
+// so whoever sends a run can't have those secrets sent to a target of their choice
+func CheckRemoteInput(input RunInputDTO) error {
+	if input.Auth.TokenEnv != "" || input.Auth.TokenFile != "" ||
+		input.Transport.CertFile != "" || input.Transport.KeyFile != "" || input.Transport.CAFile != "" ||
+		input.Socket.PayloadFile != "" || len(input.GRPC.ProtoFiles) > 0 || len(input.GRPC.ImportPaths) > 0 {
+		return errors.New(ErrLocalInput)
+	}
+	return nil
+}
+
+// ResolveLocalInput replaces the files and environment variables the input names with their contents,
+// so the run can be sent to another process
+func ResolveLocalInput(ctx context.Context, input RunInputDTO) (RunInputDTO, error) {
+	if input.Auth.Token == "" && (input.Auth.TokenEnv != "" || input.Auth.TokenFile != "") {
+		bearer, err := auth.Bearer("", input.Auth.TokenEnv, input.Auth.TokenFile)
+		if err != nil {
+			return RunInputDTO{}, err
+		}
+		input.Auth.Token = strings.TrimPrefix(bearer.Authorization(), "Bearer ")
+	}
+	input.Auth.TokenEnv, input.Auth.TokenFile = "", ""
+
+	cert, key, ca, err := input.Transport.pems()
+	if err != nil {
+		return RunInputDTO{}, err
+	}
+	input.Transport.CertPEM, input.Transport.KeyPEM, input.Transport.CAPEM = string(cert), string(key), string(ca)
+	input.Transport.CertFile, input.Transport.KeyFile, input.Transport.CAFile = "", "", ""
+
+	if input.Socket.PayloadFile != "" {
+		payload, err := socketPayload(input.Socket)
+		if err != nil {
+			return RunInputDTO{}, err
+		}
+		input.Socket.PayloadHex, input.Socket.PayloadFile = hex.EncodeToString(payload), ""
+	}
+
+	if len(input.GRPC.ProtoFiles) > 0 {
+		descriptors, err := grpcdesc.DescriptorSet(ctx, input.GRPC.ProtoFiles, input.GRPC.ImportPaths)
+		if err != nil {
+			return RunInputDTO{}, err
+		}
+		input.GRPC.Descriptors = descriptors
+	}
+	input.GRPC.ProtoFiles, input.GRPC.ImportPaths = nil, nil
+	return input, nil
+}
+
+// RequireToken lets through only the requests that carry the token as a bearer token, an empty token lets none
+func RequireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := []byte(r.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(given, expected) != 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrInvalidToken})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
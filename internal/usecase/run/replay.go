@@ -0,0 +1,191 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"stresstest/internal/accesslog"
+	"stresstest/internal/entity"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ErrEmptyReplayLog = "access log has no requests to replay"
+)
+
+// Replay sends the requests found in an access log to the target host
+// Requests keep their original relative timing when asked to, otherwise they are sent as fast as the concurrency allows
+func (u *RunUseCase) Replay(ctx context.Context, input ReplayInputDTO) (ReplayOutputDTO, error) {
+
+	// Read the access log
+	entries, skipped, err := accesslog.ParseFile(input.LogFile, input.Format)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+	if len(entries) == 0 {
+		return ReplayOutputDTO{}, errors.New(ErrEmptyReplayLog)
+	}
+
+	// Validate input
+	testOpts := &entity.TestRunOptions{Requests: len(entries), Concurrency: input.Concurrency, Headers: input.Headers}
+	testRun, err := entity.NewTestRun(input.Target, testOpts)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+	target, err := url.Parse(testRun.Url)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+
+	client, err := NewHTTPClient(input.Transport)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+
+	// Tokens are fetched before the test starts and refreshed until it ends
+	authCtx, stopAuth := context.WithCancel(ctx)
+	defer stopAuth()
+	authenticator, err := newAuthenticator(authCtx, input.Auth, testRun.Headers, input.Transport.Insecure)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+	client = withAuth(client, authenticator)
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return ReplayOutputDTO{}, err
+	}
+
+	speed := input.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	// Entries without a time sort first and are sent right away, the offsets of the others start at the first time
+	var firstTime time.Time
+	if input.KeepTiming {
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+		for _, entry := range entries {
+			if !entry.Time.IsZero() {
+				firstTime = entry.Time
+				break
+			}
+		}
+	}
+
+	// Replay the log
+	var wg sync.WaitGroup
+	results := newCollector(ctx, input.ShowData)
+	comparison := newReplayComparison()
+	requestsChannel := make(chan struct{}, testRun.Concurrency)
+	replayStart := time.Now()
+
+	for _, entry := range entries {
+		if input.KeepTiming && !entry.Time.IsZero() {
+			offset := time.Duration(float64(entry.Time.Sub(firstTime)) / speed)
+			if wait := time.Until(replayStart.Add(offset)); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+				}
+			}
+		}
+		// A canceled replay stops dispatching, the requests already sent finish on their own
+		if ctx.Err() != nil {
+			break
+		}
+
+		requestsChannel <- struct{}{}
+		wg.Add(1)
+
+		go func(entry accesslog.Entry) {
+			defer wg.Done()
+			defer func() { <-requestsChannel }()
+
+			request := HTTPRequest{Method: entry.Method, Url: rewriteURL(target, entry.Path), Headers: testRun.Headers}
+			result := MakeRequest(ctx, client, request)
+			results.record(result)
+			comparison.record(entry, result.Status, result.Duration)
+		}(entry)
+	}
+
+	wg.Wait() // Wait for all requests to finish
+
+	return ReplayOutputDTO{
+		RunOutputDTO: RunOutputDTO{
+			Id:                    testRun.Id,
+			Mode:                  ModeHTTP,
+			Url:                   testRun.Url,
+			Requests:              testRun.Requests,
+			Concurrency:           testRun.Concurrency,
+			TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+			TimestampEnd:          FormatTimeToUTCString(time.Now()),
+			TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
+			Data:                  results.data,
+			Report:                results.finalReport(),
+			Auth:                  authReport(input.Auth, authenticator),
+			TLS:                   results.tlsReport(),
+			Traces:                results.traceReport(),
+		},
+		LogFile:      input.LogFile,
+		SkippedLines: skipped,
+		KeepTiming:   input.KeepTiming,
+		Speed:        speed,
+		Comparison:   comparison.result(),
+	}, nil
+}
+
+// rewriteURL points a logged path at the replay target, keeping any base path the target has
+func rewriteURL(target *url.URL, path string) string {
+	return strings.TrimSuffix(target.Scheme+"://"+target.Host+target.Path, "/") + path
+}
+
+// replayComparison compares the latency recorded in the log with the replayed one
+// Only requests that had their latency logged are compared
+type replayComparison struct {
+	mu            sync.Mutex
+	compared      int
+	statusMatches int
+	reportMap     map[string]*StatusReportDTO
+}
+
+func newReplayComparison() *replayComparison {
+	return &replayComparison{reportMap: make(map[string]*StatusReportDTO)}
+}
+
+func (c *replayComparison) record(entry accesslog.Entry, status, duration int) {
+	if !entry.HasLatency || status == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.compared++
+	if entry.Status == status {
+		c.statusMatches++
+	}
+	updateReport(c.reportMap, "recorded", int(entry.Latency.Milliseconds()))
+	updateReport(c.reportMap, "replayed", duration)
+}
+
+func (c *replayComparison) result() ReplayComparisonDTO {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := ReplayComparisonDTO{Compared: c.compared, StatusMatches: c.statusMatches}
+	for _, report := range finalizeReport(c.reportMap) {
+		if report.Status == "recorded" {
+			result.Recorded = report
+		} else {
+			result.Replayed = report
+		}
+	}
+	if c.compared > 0 {
+		result.AverageDeltaInMs = result.Replayed.AverageTime - result.Recorded.AverageTime
+	}
+	return result
+}
@@ -0,0 +1,223 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ErrInvalidRetry      = "invalid retry policy, retries and delays must not be negative and statuses must be HTTP status codes"
+	ErrRetryNotSupported = "retries are only supported for HTTP and GraphQL runs"
+
+	defaultRetryBaseDelay = 100 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
+// DefaultRetryStatuses are the statuses retried when the policy doesn't name any, the ones rate limiters answer with
+var DefaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+
+func (r RetryDTO) validate() error {
+	if r.MaxRetries < 0 || r.BaseDelayInMs < 0 || r.MaxDelayInMs < 0 {
+		return errors.New(ErrInvalidRetry)
+	}
+	for _, status := range r.Statuses {
+		// Status 0 retries requests that got no response
+		if status < 0 || status > 599 {
+			return errors.New(ErrInvalidRetry)
+		}
+	}
+	return nil
+}
+
+// retrier sends the requests of a run again while they get a status of the policy, waiting for the Retry-After
+// of the response or, without it, an exponential backoff with full jitter. It also keeps the retries of the run
+type retrier struct {
+	maxRetries int
+	statuses   map[int]bool
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	stats      retryStats
+}
+
+// newRetrier returns the retrier of the policy, nil when requests aren't retried
+func newRetrier(policy RetryDTO) *retrier {
+	if policy.MaxRetries <= 0 {
+		return nil
+	}
+	r := &retrier{
+		maxRetries: policy.MaxRetries,
+		statuses:   make(map[int]bool),
+		baseDelay:  time.Duration(policy.BaseDelayInMs) * time.Millisecond,
+		maxDelay:   time.Duration(policy.MaxDelayInMs) * time.Millisecond,
+		stats:      retryStats{first: make(map[string]int), final: make(map[string]int), outcomes: make(map[string]int)},
+	}
+	if r.baseDelay == 0 {
+		r.baseDelay = defaultRetryBaseDelay
+	}
+	if r.maxDelay == 0 {
+		r.maxDelay = defaultRetryMaxDelay
+	}
+	statuses := policy.Statuses
+	if len(statuses) == 0 {
+		statuses = DefaultRetryStatuses
+	}
+	for _, status := range statuses {
+		r.statuses[status] = true
+	}
+	return r
+}
+
+// retryAttempts is how a request went through its attempts
+type retryAttempts struct {
+	count       int
+	firstStatus int
+	retryAfter  int // waits that came from a Retry-After
+}
+
+// send sends the request until it gets a status the policy doesn't retry or runs out of retries.
+// The result is the one of the last attempt, timed from the start of the first, so it's what a client waited
+func (r *retrier) send(ctx context.Context, client *http.Client, request HTTPRequest, onResponse func(resp *http.Response)) (RequestResult, retryAttempts) {
+	if r == nil {
+		return sendRequest(ctx, client, request, onResponse), retryAttempts{count: 1}
+	}
+
+	var attempts retryAttempts
+	var start time.Time
+	for {
+		var retryAfter string
+		result := sendRequest(ctx, client, request, func(resp *http.Response) {
+			retryAfter = resp.Header.Get("Retry-After")
+			if onResponse != nil {
+				onResponse(resp)
+			}
+		})
+		attempts.count++
+		if attempts.count == 1 {
+			start = result.Start
+			attempts.firstStatus = result.Status
+		}
+		if !r.statuses[result.Status] || attempts.count > r.maxRetries || ctx.Err() != nil {
+			return fromFirstAttempt(result, start), attempts
+		}
+
+		delay, fromHeader := parseRetryAfter(retryAfter, time.Now())
+		if fromHeader {
+			attempts.retryAfter++
+		} else {
+			delay = r.backoff(attempts.count - 1)
+		}
+		if sleep(ctx, min(delay, r.maxDelay)) != nil {
+			return fromFirstAttempt(result, start), attempts
+		}
+	}
+}
+
+// fromFirstAttempt times the result of the last attempt from the start of the first, requests without response
+// keep no duration like they do without retries
+func fromFirstAttempt(result RequestResult, start time.Time) RequestResult {
+	result.Start = start
+	if result.Status != 0 {
+		result.Duration = int(result.End.Sub(start).Milliseconds())
+	}
+	return result
+}
+
+// backoff returns the wait before retry n, counted from 0, drawn between zero and the exponential delay
+func (r *retrier) backoff(n int) time.Duration {
+	delay := r.maxDelay
+	if n < 32 {
+		delay = min(r.baseDelay<<n, r.maxDelay)
+	}
+	return rand.N(delay + 1)
+}
+
+// parseRetryAfter reads a Retry-After given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now)), true
+	}
+	return 0, false
+}
+
+// track adds a request of the run, with the status it ended with, to the retries of the run
+func (r *retrier) track(attempts retryAttempts, finalStatus int) {
+	if r == nil {
+		return
+	}
+	r.stats.add(attempts, finalStatus, r.statuses[finalStatus])
+}
+
+func (r *retrier) report() *RetryReportDTO {
+	if r == nil {
+		return nil
+	}
+	return r.stats.report()
+}
+
+type retryStats struct {
+	mu          sync.Mutex
+	requests    int
+	retried     int
+	attempts    int
+	maxAttempts int
+	retryAfter  int
+	recovered   int
+	exhausted   int
+	first       map[string]int
+	final       map[string]int
+	outcomes    map[string]int
+}
+
+// add counts a request, retryable tells if it ended with a status the policy retries
+func (s *retryStats) add(attempts retryAttempts, finalStatus int, retryable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first, final := strconv.Itoa(attempts.firstStatus), strconv.Itoa(finalStatus)
+	s.requests++
+	s.attempts += attempts.count
+	s.maxAttempts = max(s.maxAttempts, attempts.count)
+	s.retryAfter += attempts.retryAfter
+	s.first[first]++
+	s.final[final]++
+	if attempts.count > 1 {
+		s.retried++
+		s.outcomes[first+" → "+final]++
+		if retryable {
+			s.exhausted++
+		} else {
+			s.recovered++
+		}
+	}
+}
+
+func (s *retryStats) report() *RetryReportDTO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := &RetryReportDTO{
+		Requests:        s.requests,
+		Retried:         s.retried,
+		Attempts:        s.attempts,
+		MaxAttempts:     s.maxAttempts,
+		RetryAfterWaits: s.retryAfter,
+		FirstAttempt:    s.first,
+		FinalOutcome:    s.final,
+		RetriedOutcomes: s.outcomes,
+		Recovered:       s.recovered,
+		Exhausted:       s.exhausted,
+	}
+	if s.requests > 0 {
+		report.AttemptsPerRequest = float64(s.attempts) / float64(s.requests)
+	}
+	return report
+}
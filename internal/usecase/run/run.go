@@ -2,14 +2,28 @@ package run
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
 	"net/http"
-	"strconv"
 	"stresstest/internal/entity"
 	"stresstest/internal/repository"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	ModeHTTP      = "http"
+	ModeWebSocket = "websocket"
+	ModeGRPC      = "grpc"
+	ModeSSE       = "sse"
+	ModeGraphQL   = "graphql"
+	ModeTCP       = "tcp"
+	ModeUDP       = "udp"
+)
+
 type RunUseCase struct {
 	repo repository.RepositoryInterface
 }
@@ -21,118 +35,277 @@ func NewRunUseCase(repo repository.RepositoryInterface) RunUseCase {
 func (u *RunUseCase) Run(ctx context.Context, input RunInputDTO) (RunOutputDTO, error) {
 
 	// Validate input
-	testOpts := &entity.TestRunOptions{Requests: input.Requests, Concurrency: input.Concurrency}
-	testRun, err := entity.NewTestRun(input.Url, testOpts)
+	testRun, err := newTestRun(input)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	client, err := NewHTTPClient(input.Transport)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	// Tokens are fetched before the test starts and refreshed until it ends
+	authCtx, stopAuth := context.WithCancel(ctx)
+	defer stopAuth()
+	authenticator, err := newAuthenticator(authCtx, input.Auth, testRun.Headers, input.Transport.Insecure)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	// WebSocket targets keep connections open instead of sending requests
+	if entity.IsWebSocketURL(testRun.Url) {
+		return u.runWebSocket(ctx, input, testRun, authenticator)
+	}
+	if entity.IsGRPCURL(testRun.Url) {
+		return u.runGRPC(ctx, input, testRun, authenticator)
+	}
+	if entity.IsSocketURL(testRun.Url) {
+		return u.runSocket(ctx, input, testRun)
+	}
+
+	// Every virtual user gets its client up front, so a bad transport fails the run before it starts
+	userSessions, err := newSessions(input.Session, input.Transport, client, authenticator)
 	if err != nil {
 		return RunOutputDTO{}, err
 	}
+	clients := make([]*http.Client, testRun.Concurrency)
+	for vu := range clients {
+		if clients[vu], err = userSessions.client(); err != nil {
+			return RunOutputDTO{}, err
+		}
+	}
+	if input.SSE.Enabled {
+		return u.runSSE(ctx, input, testRun, clients, authenticator)
+	}
+	if input.GraphQL.Query != "" {
+		return u.runGraphQL(ctx, input, testRun, clients, authenticator)
+	}
+	request := HTTPRequest{Method: testRun.Method, Url: testRun.Url, Headers: testRun.Headers, Body: testRun.Body}
 
-	// Won't actually save anything, just a placeholder for future implementations
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
 	err = u.repo.Save(ctx, testRun)
 	if err != nil {
 		return RunOutputDTO{}, err
 	}
 
 	// Run the Stress Test
+	// Each virtual user sends its requests back to back until the run has sent them all or is canceled
 	var wg sync.WaitGroup
-	var mu sync.Mutex
-	data := make([]DataOutputDTO, 0)
-	reportMap := make(map[string]*StatusReportDTO)
-	requestsChannel := make(chan struct{}, testRun.Concurrency)
+	var sent atomic.Int64
+	results := newCollector(ctx, input.ShowData).expectInterval(input.expectedInterval())
+	warm := newWarmup(input.Warmup)
+	retries := newRetrier(input.Retry)
 
-	for i := 0; i < testRun.Requests; i++ {
-		requestsChannel <- struct{}{}
+	for _, client := range clients {
 		wg.Add(1)
 
-		go func() {
+		go func(client *http.Client) {
 			defer wg.Done()
-			defer func() { <-requestsChannel }()
-
-			status, duration, requestStart, requestEnd := MakeRequest(ctx, testRun.Url)
-
-			// Save data if requested
-			if input.ShowData {
-				mu.Lock()
-				data = append(data, DataOutputDTO{
-					StatusCode:            status,
-					DurationInMs:          duration,
-					RequestStartTimestamp: FormatTimeToUTCString(requestStart),
-					RequestEndTimestamp:   FormatTimeToUTCString(requestEnd),
-				})
-				mu.Unlock()
-			}
 
-			// Save report data
-			updateReport(&mu, reportMap, strconv.Itoa(status), duration)
-			updateReport(&mu, reportMap, "total", duration) // 9999 = total for all statuses
-		}()
+			pace := newPacer(input)
+			for ctx.Err() == nil {
+				if warm.claim() {
+					if pace.wait(ctx) != nil {
+						return
+					}
+					result, _ := retries.send(ctx, client, request, nil)
+					warm.results.record(result)
+					continue
+				}
+				if sent.Add(1) > int64(testRun.Requests) || pace.wait(ctx) != nil {
+					return
+				}
+				result, attempts := retries.send(ctx, client, request, nil)
+				retries.track(attempts, result.Status)
+				results.record(result)
+			}
+		}(client)
 	}
 
 	wg.Wait() // Wait for all requests to finish
-
-	// Calculate average time
-	var FinalReport []StatusReportDTO
-	for _, report := range reportMap {
-		report.AverageTime = float64(report.TotalTime) / float64(report.Count)
-		FinalReport = append(FinalReport, *report)
-	}
+	start := warm.measuredFrom(testRun.Timestamp)
 
 	// Return output
 	return RunOutputDTO{
 		Id:                    testRun.Id,
+		Mode:                  ModeHTTP,
 		Url:                   testRun.Url,
+		Method:                testRun.Method,
 		Requests:              testRun.Requests,
 		Concurrency:           testRun.Concurrency,
-		TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+		TimestampStart:        FormatTimeToUTCString(start),
 		TimestampEnd:          FormatTimeToUTCString(time.Now()),
-		TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
-		Data:                  data,
-		Report:                FinalReport,
+		TestDurationInSeconds: int(time.Since(start).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Auth:                  authReport(input.Auth, authenticator),
+		TLS:                   results.tlsReport(),
+		Protocol:              results.protocolReport(),
+		Traces:                results.traceReport(),
+		QUIC:                  quicReport(clients),
+		Warmup:                warm.report(),
+		Retries:               retries.report(),
 	}, nil
 }
 
-// MakeRequest makes a GET request to the given URL and returns the status code, duration, and start/end times
-func MakeRequest(ctx context.Context, url string) (status, duration int, start, end time.Time) {
-	start = time.Now()
+// ValidateInput checks the input describes a valid test run, without running it
+func ValidateInput(input RunInputDTO) error {
+	_, err := newTestRun(input)
+	return err
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// newTestRun validates the input and returns the test run it describes
+func newTestRun(input RunInputDTO) (*entity.TestRun, error) {
+	testOpts := &entity.TestRunOptions{
+		Id:          input.Id,
+		Requests:    input.Requests,
+		Concurrency: input.Concurrency,
+		Method:      input.Method,
+		Headers:     input.Headers,
+		Body:        input.Body,
+	}
+	// Streams are held open for a duration, so every virtual user gets one no matter the requests
+	if input.SSE.Enabled && input.Concurrency > input.Requests {
+		testOpts.Requests = input.Concurrency
+	}
+	testRun, err := entity.NewTestRun(input.Url, testOpts)
 	if err != nil {
-		return 0, 0, start, time.Now()
+		return nil, err
 	}
+	// Warm-up, think time, pacing and retries go into the request loops of HTTP and GraphQL runs
+	streaming := input.SSE.Enabled || entity.IsWebSocketURL(testRun.Url) || entity.IsGRPCURL(testRun.Url) || entity.IsSocketURL(testRun.Url)
+	if input.Warmup.Requests < 0 || input.Warmup.DurationInMs < 0 {
+		return nil, errors.New(ErrInvalidWarmup)
+	}
+	if input.Warmup.enabled() && streaming {
+		return nil, errors.New(ErrWarmupNotSupported)
+	}
+	if err := validatePacing(input, streaming); err != nil {
+		return nil, err
+	}
+	if err := input.Retry.validate(); err != nil {
+		return nil, err
+	}
+	if input.Retry.MaxRetries > 0 && streaming {
+		return nil, errors.New(ErrRetryNotSupported)
+	}
+	return testRun, nil
+}
+
+// HTTPRequest is the request sent on every iteration of a run
+type HTTPRequest struct {
+	Method  string
+	Url     string
+	Headers map[string]string
+	Body    string
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// RequestResult is the outcome of a single request
+type RequestResult struct {
+	Status   int
+	Duration int
+	Start    time.Time
+	End      time.Time
+	// Handshake is set when the request had to open a new TLS connection
+	Handshake    *tls.ConnectionState
+	HandshakeErr error
+	// Protocol is the one the response came with, like HTTP/1.1 or HTTP/2.0
+	Protocol string
+	// Err is why the request got no response
+	Err error
+	// Phases is the time the request spent in each phase it went through, like PhaseDNS
+	Phases map[string]time.Duration
+	// BytesReceived counts the body of the response, read after the request is timed
+	BytesReceived int64
+	// Span is the trace context sent with the request, nil when the run isn't traced
+	Span *SpanContext
+	// conn identifies the connection the request was sent on, see connID
+	conn string
+}
+
+// MakeRequest sends the request with the given client and returns the status code, duration, start/end times
+// and the TLS handshake it made, if any
+func MakeRequest(ctx context.Context, client *http.Client, r HTTPRequest) RequestResult {
+	return sendRequest(ctx, client, r, nil)
+}
+
+// sendRequest sends the request like MakeRequest, letting onResponse read the response before the request is timed
+func sendRequest(ctx context.Context, client *http.Client, r HTTPRequest, onResponse func(resp *http.Response)) (result RequestResult) {
+	result.Start = time.Now()
+
+	trace := &requestTrace{}
+	defer trace.fill(&result)
+	req, err := newRequest(traceRequest(ctx, trace), r)
 	if err != nil {
-		return 0, 0, start, time.Now()
+		result.End = time.Now()
+		result.Err = err
+		return result
+	}
+	if ratio, ok := tracingFrom(ctx); ok {
+		result.Span = newSpanContext(ratio)
+		req.Header.Set(TraceparentHeader, result.Span.traceparent())
+	}
+
+	notifyInFlight(ctx, 1)
+	defer notifyInFlight(ctx, -1)
+	resp, err := client.Do(req)
+	if err != nil {
+		result.End = time.Now()
+		result.Err = err
+		return result
 	}
 	defer resp.Body.Close()
+	body := &countingReader{Reader: resp.Body}
+	resp.Body = io.NopCloser(body)
+	if onResponse != nil {
+		onResponse(resp)
+	}
+
+	result.End = time.Now()
+	result.Duration = int(time.Since(result.Start).Milliseconds())
+	result.Status = resp.StatusCode
+	result.Protocol = resp.Proto
 
-	end = time.Now()
-	duration = int(time.Since(start).Milliseconds())
-	status = resp.StatusCode
-	return status, duration, start, end
+	// The rest of the body is read so the connection can be reused and the bytes counted
+	io.Copy(io.Discard, body)
+	result.BytesReceived = body.n
+	return result
 }
 
-// FormatTimeToUTCString formats a time.Time to UTC in the format "YYYY-MM-DD HH:MM:SS.sssssss"
-func FormatTimeToUTCString(t time.Time) string {
-	return t.UTC().Format("2006-01-02 15:04:05.0000000")
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
 }
 
-// updateReport updates the report map with the new data
-func updateReport(mu *sync.Mutex, reportMap map[string]*StatusReportDTO, status string, duration int) {
-	mu.Lock()
-	defer mu.Unlock()
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
 
-	report, exists := reportMap[status]
-	if !exists {
-		report = &StatusReportDTO{Status: status, MinTime: duration, MaxTime: duration}
-		reportMap[status] = report
+// newRequest builds the http.Request of an HTTPRequest
+func newRequest(ctx context.Context, r HTTPRequest) (*http.Request, error) {
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
 	}
-	report.Count++
-	report.TotalTime += duration
-	if duration < report.MinTime {
-		report.MinTime = duration
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.Url, body)
+	if err != nil {
+		return nil, err
 	}
-	if duration > report.MaxTime {
-		report.MaxTime = duration
+	for name, value := range r.Headers {
+		req.Header.Set(name, value)
+		// Host can't be set through the header map
+		if http.CanonicalHeaderKey(name) == "Host" {
+			req.Host = value
+		}
 	}
+	return req, nil
+}
+
+// FormatTimeToUTCString formats a time.Time to UTC in the format "YYYY-MM-DD HH:MM:SS.sssssss"
+func FormatTimeToUTCString(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05.0000000")
 }
@@ -1,15 +1,50 @@
 package run_test
 
 import (
+	"bufio"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"stresstest/internal/entity"
+	"stresstest/internal/grpcdesc"
 	"stresstest/internal/usecase/run"
 	"stresstest/mocks/repository"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
 func Test_RunUseCase_MustFailForInvalidParams(t *testing.T) {
@@ -186,3 +221,2489 @@ func Test_MustMakeRequestsConcurrently(t *testing.T) {
 	}
 	t.Log(output)
 }
+
+func Test_MustSendMethodHeadersAndBody(t *testing.T) {
+	// Arrange
+	var received []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r.Method+" "+r.Host+" "+r.Header.Get("X-Test")+" "+string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    3,
+		Concurrency: 1,
+		Method:      "POST",
+		Headers:     map[string]string{"X-Test": "yes", "host": "api.example.com"},
+		Body:        `{"a":1}`,
+	}
+	ctx := context.Background()
+
+	// Act
+	output, err := uc.Run(ctx, input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", output.Method)
+	assert.Equal(t, []string{`POST api.example.com yes {"a":1}`, `POST api.example.com yes {"a":1}`, `POST api.example.com yes {"a":1}`}, received)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"201", "total"}, report.Status)
+		assert.Equal(t, 3, report.Count)
+	}
+}
+
+func Test_MustReplayAccessLogAgainstTarget(t *testing.T) {
+	// Arrange
+	var paths []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.RequestURI())
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	log := `10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET /a?x=1 HTTP/1.1" 200 1 "-" "-" 0.050
+10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "POST /b HTTP/1.1" 500 1 "-" "-" 0.020
+garbage
+`
+	assert.NoError(t, os.WriteFile(logFile, []byte(log), 0644))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.ReplayInputDTO{LogFile: logFile, Target: server.URL, Concurrency: 1, KeepTiming: true, Speed: 10}
+	ctx := context.Background()
+
+	// Act
+	output, err := uc.Replay(ctx, input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"GET /a?x=1", "POST /b"}, paths)
+	assert.Equal(t, 2, output.Requests)
+	assert.Equal(t, 1, output.SkippedLines)
+	assert.Equal(t, 2, output.Comparison.Compared)
+	assert.Equal(t, 1, output.Comparison.StatusMatches)
+	assert.Equal(t, 50, output.Comparison.Recorded.MaxTime)
+	assert.Equal(t, 2, output.Comparison.Replayed.Count)
+}
+
+func Test_MustStopReplayingWhenCanceled(t *testing.T) {
+	// Arrange
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	log := `10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET /a HTTP/1.1" 200 1 "-" "-" 0.050
+10.0.0.1 - - [26/Mar/2025:10:23:53 +0000] "GET /b HTTP/1.1" 200 1 "-" "-" 0.050
+10.0.0.1 - - [26/Mar/2025:10:23:55 +0000] "GET /c HTTP/1.1" 200 1 "-" "-" 0.050
+`
+	assert.NoError(t, os.WriteFile(logFile, []byte(log), 0644))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.ReplayInputDTO{LogFile: logFile, Target: server.URL, Concurrency: 1, KeepTiming: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	_, err := uc.Replay(ctx, input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func Test_MustKeepTheTimingOfLogsWithEntriesWithoutTime(t *testing.T) {
+	// Arrange
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	log := `{"path": "/untimed"}
+10.0.0.1 - - [26/Mar/2025:10:23:51 +0000] "GET /a HTTP/1.1" 200 1 "-" "-" 0.050
+10.0.0.1 - - [26/Mar/2025:10:23:52 +0000] "GET /b HTTP/1.1" 200 1 "-" "-" 0.050
+`
+	assert.NoError(t, os.WriteFile(logFile, []byte(log), 0644))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.ReplayInputDTO{LogFile: logFile, Target: server.URL, Concurrency: 1, KeepTiming: true, Speed: 10}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	output, err := uc.Replay(ctx, input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, 3, output.Requests)
+}
+
+func Test_ReplayMustFailForEmptyLog(t *testing.T) {
+	// Arrange
+	logFile := filepath.Join(t.TempDir(), "access.log")
+	assert.NoError(t, os.WriteFile(logFile, []byte("garbage\n"), 0644))
+
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.ReplayInputDTO{LogFile: logFile, Target: "http://example.com", Concurrency: 1}
+
+	// Act
+	_, err := uc.Replay(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, err, run.ErrEmptyReplayLog)
+}
+
+func Test_MustAuthenticateWithOAuth2ClientCredentials(t *testing.T) {
+	// Arrange
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	var authorized atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer abc" {
+			authorized.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    4,
+		Concurrency: 2,
+		Auth:        run.AuthDTO{Type: "oauth2", TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), authorized.Load())
+	assert.NotNil(t, output.Auth)
+	assert.Equal(t, "oauth2", output.Auth.Type)
+	assert.Equal(t, 1, output.Auth.TokenFetches)
+	assert.Equal(t, 0, output.Auth.RefreshFailures)
+}
+
+func Test_MustFetchOAuth2TokensOutsideTheProtocolOfTheRun(t *testing.T) {
+	// Arrange
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"abc","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	var authorized atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && r.Header.Get("Authorization") == "Bearer abc" {
+			authorized.Add(1)
+		}
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    4,
+		Concurrency: 2,
+		Transport:   run.TransportDTO{Protocol: run.ProtocolH2C},
+		Auth:        run.AuthDTO{Type: "oauth2", TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), authorized.Load())
+	assert.Equal(t, 1, output.Auth.TokenFetches)
+}
+
+func Test_RunMustFailForUnknownAuthType(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "http://example.com", Requests: 1, Concurrency: 1, Auth: run.AuthDTO{Type: "digest"}}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, err, run.ErrUnknownAuthType)
+}
+
+func Test_RunMustFailForAuthAndAnAuthorizationHeader(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "http://example.com",
+		Requests:    1,
+		Concurrency: 1,
+		Headers:     map[string]string{"authorization": "Bearer from-header"},
+		Auth:        run.AuthDTO{Type: "bearer", Token: "from-auth"},
+	}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, err, run.ErrAuthHeaderConflict)
+}
+
+func Test_MustTrustCABundleAndReportTLSHandshakes(t *testing.T) {
+	// Arrange
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close") // forces a new handshake per request
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, caPEM, 0644))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    3,
+		Concurrency: 1,
+		Transport:   run.TransportDTO{CAFile: caFile, MinTLSVersion: "1.3"},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, output.TLS)
+	assert.Equal(t, 3, output.TLS.Handshakes)
+	assert.Equal(t, 1, output.TLS.Full)
+	assert.Equal(t, 2, output.TLS.Resumed)
+	assert.Equal(t, 3, output.TLS.Versions["TLS 1.3"])
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"200", "total"}, report.Status)
+	}
+}
+
+func Test_MustPresentClientCertificate(t *testing.T) {
+	// Arrange
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	certFile, keyFile := writeClientCertificate(t)
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    2,
+		Concurrency: 1,
+		Transport:   run.TransportDTO{Insecure: true, CertFile: certFile, KeyFile: keyFile, MaxTLSVersion: "1.2"},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, output.TLS.Versions["TLS 1.2"])
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"200", "total"}, report.Status)
+		assert.Equal(t, 2, report.Count)
+	}
+}
+
+func Test_RunMustFailForInvalidTLSOptions(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "https://example.com", Requests: 1, Concurrency: 1}
+
+	// Act
+	input.Transport = run.TransportDTO{MinTLSVersion: "2.0"}
+	_, versionErr := uc.Run(context.Background(), input)
+	input.Transport = run.TransportDTO{CipherSuites: []string{"TLS_NOT_A_SUITE"}}
+	_, cipherErr := uc.Run(context.Background(), input)
+	input.Transport = run.TransportDTO{CertFile: "client.pem"}
+	_, certErr := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, versionErr, run.ErrInvalidTLSVersion)
+	assert.ErrorContains(t, cipherErr, run.ErrUnknownCipherSuite)
+	assert.EqualError(t, certErr, run.ErrMissingCertOrKey)
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key to PEM files
+func writeClientCertificate(t *testing.T) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stresstest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func Test_MustIsolateCookiesPerVirtualUser(t *testing.T) {
+	// Arrange
+	var sessions atomic.Int32
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: strconv.Itoa(int(sessions.Add(1)))})
+			return
+		}
+		mu.Lock()
+		seen[cookie.Value]++
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    12,
+		Concurrency: 3,
+		Session:     run.SessionDTO{Mode: run.SessionIsolated, PoolPerVU: true},
+	}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), sessions.Load())
+	assert.Len(t, seen, 3)
+}
+
+func Test_MustShareCookiesBetweenRequests(t *testing.T) {
+	// Arrange
+	var withCookie atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err == nil {
+			withCookie.Add(1)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Twice()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: server.URL, Requests: 4, Concurrency: 1, Session: run.SessionDTO{Mode: run.SessionShared}}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+	input.Session.Mode = ""
+	_, errWithoutSession := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, errWithoutSession)
+	assert.Equal(t, int32(3), withCookie.Load())
+}
+
+func Test_RunMustFailForUnknownSessionMode(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "http://example.com", Requests: 1, Concurrency: 1, Session: run.SessionDTO{Mode: "sticky"}}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, err, run.ErrUnknownSessionMode)
+}
+
+// newWebSocketEchoServer starts a server that echoes every message back
+func newWebSocketEchoServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if conn.WriteMessage(messageType, message) != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_MustExchangeWebSocketMessages(t *testing.T) {
+	// Arrange
+	server := newWebSocketEchoServer(t)
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "ws" + strings.TrimPrefix(server.URL, "http"),
+		Requests:    20,
+		Concurrency: 2,
+		WebSocket:   run.WebSocketDTO{Messages: []string{"ping", "pong"}},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeWebSocket, output.Mode)
+	assert.Equal(t, 2, output.WebSocket.Connections)
+	assert.Equal(t, 20, output.WebSocket.MessagesSent)
+	assert.Equal(t, 20, output.WebSocket.MessagesReceived)
+	assert.Equal(t, 2, output.WebSocket.CloseCodes["close 1000"])
+	assert.Equal(t, 0, output.WebSocket.Disconnects)
+	counts := make(map[string]int)
+	for _, report := range output.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Equal(t, map[string]int{"connect": 2, "message": 20, "total": 20, "close 1000": 2}, counts)
+}
+
+func Test_MustSendWebSocketMessagesAtRate(t *testing.T) {
+	// Arrange
+	server := newWebSocketEchoServer(t)
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "ws" + strings.TrimPrefix(server.URL, "http"),
+		Requests:    5,
+		Concurrency: 1,
+		WebSocket:   run.WebSocketDTO{Messages: []string{"hello"}, Rate: 50},
+	}
+
+	// Act
+	start := time.Now()
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+	assert.Equal(t, 5, output.WebSocket.MessagesReceived)
+}
+
+func Test_MustStopSendingWebSocketMessagesAtRateWhenTheRunOrConnectionEnds(t *testing.T) {
+	// Arrange
+	echo := newWebSocketEchoServer(t)
+	upgrader := websocket.Upgrader{}
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(messageType, message)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+		time.Sleep(time.Second)
+	}))
+	defer closing.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Twice()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "ws" + strings.TrimPrefix(echo.URL, "http"),
+		Requests:    10,
+		Concurrency: 1,
+		WebSocket:   run.WebSocketDTO{Messages: []string{"hello"}, Rate: 1},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// Act
+	canceled, errCanceled := uc.Run(ctx, input)
+	input.Url = "ws" + strings.TrimPrefix(closing.URL, "http")
+	start := time.Now()
+	dropped, errDropped := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, errCanceled)
+	assert.Equal(t, 1, canceled.WebSocket.MessagesSent)
+	assert.NoError(t, errDropped)
+	assert.Less(t, time.Since(start), 800*time.Millisecond)
+	assert.Equal(t, 5, dropped.WebSocket.MessagesSent)
+	assert.Equal(t, 5, dropped.WebSocket.Disconnects)
+	counts := make(map[string]int)
+	for _, report := range dropped.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Equal(t, 5, counts["error"])
+	assert.Equal(t, 10, counts["total"])
+}
+
+func Test_MustConnectAndCloseWebSocketsWithoutMessages(t *testing.T) {
+	// Arrange
+	server := newWebSocketEchoServer(t)
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "ws" + strings.TrimPrefix(server.URL, "http"), Requests: 4, Concurrency: 2}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 4, output.WebSocket.Connections)
+	assert.Equal(t, 4, output.WebSocket.CloseCodes["close 1000"])
+}
+
+func Test_MustReportWebSocketConnectFailures(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "ws" + strings.TrimPrefix(server.URL, "http"),
+		Requests:    3,
+		Concurrency: 1,
+		WebSocket:   run.WebSocketDTO{Messages: []string{"hello"}},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, output.WebSocket.FailedConnections)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"error", "total"}, report.Status)
+		assert.Equal(t, 3, report.Count)
+	}
+}
+
+const echoProto = `syntax = "proto3";
+package echo;
+
+message Msg {
+  string text = 1;
+}
+
+service Echo {
+  rpc Unary(Msg) returns (Msg);
+  rpc ServerStream(Msg) returns (stream Msg);
+  rpc ClientStream(stream Msg) returns (Msg);
+  rpc Bidi(stream Msg) returns (stream Msg);
+  rpc Fail(Msg) returns (Msg);
+  rpc Slow(Msg) returns (Msg);
+}
+`
+
+// newGRPCEchoServer starts a gRPC server for the echo.Echo service, with server reflection enabled
+// It returns the server address and the import path of echo.proto
+func newGRPCEchoServer(t *testing.T) (addr, importPath string) {
+	importPath = t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(importPath, "echo.proto"), []byte(echoProto), 0644))
+	resolver, err := grpcdesc.FromProtoFiles(context.Background(), []string{"echo.proto"}, []string{importPath})
+	assert.NoError(t, err)
+	descriptor, err := resolver.FindDescriptorByName("echo.Msg")
+	assert.NoError(t, err)
+	msg := descriptor.(protoreflect.MessageDescriptor)
+
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		if md, _ := metadata.FromIncomingContext(stream.Context()); len(md.Get("x-denied")) > 0 {
+			return status.Error(codes.PermissionDenied, "denied")
+		}
+
+		switch method {
+		case "/echo.Echo/Fail":
+			return status.Error(codes.Unavailable, "unavailable")
+		case "/echo.Echo/Slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		var last *dynamicpb.Message
+		for {
+			in := dynamicpb.NewMessage(msg)
+			err := stream.RecvMsg(in)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			last = in
+			switch method {
+			case "/echo.Echo/Bidi":
+				if err := stream.SendMsg(in); err != nil {
+					return err
+				}
+			case "/echo.Echo/ServerStream":
+				for i := 0; i < 3; i++ {
+					if err := stream.SendMsg(in); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if method == "/echo.Echo/Bidi" || method == "/echo.Echo/ServerStream" {
+			return nil
+		}
+		return stream.SendMsg(last)
+	}))
+	reflectionpb.RegisterServerReflectionServer(server, reflection.NewServerV1(reflection.ServerOptions{
+		Services:           server,
+		DescriptorResolver: resolver.(protodesc.Resolver),
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), importPath
+}
+
+func Test_MustCallUnaryGRPCMethodFromProtoFiles(t *testing.T) {
+	// Arrange
+	addr, importPath := newGRPCEchoServer(t)
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "grpc://" + addr,
+		Requests:    6,
+		Concurrency: 2,
+		Headers:     map[string]string{"x-tenant": "acme"},
+		GRPC: run.GRPCDTO{
+			Method:      "echo.Echo/Unary",
+			Data:        `{"text":"hello"}`,
+			ProtoFiles:  []string{"echo.proto"},
+			ImportPaths: []string{importPath},
+		},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeGRPC, output.Mode)
+	assert.Equal(t, "/echo.Echo/Unary", output.GRPC.Method)
+	assert.Equal(t, run.GRPCUnary, output.GRPC.CallType)
+	assert.Equal(t, 6, output.GRPC.MessagesReceived)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"OK", "total"}, report.Status)
+		assert.Equal(t, 6, report.Count)
+	}
+}
+
+func Test_MustCallStreamingGRPCMethodsThroughReflection(t *testing.T) {
+	// Arrange
+	addr, _ := newGRPCEchoServer(t)
+	cases := []struct {
+		method   string
+		data     string
+		callType string
+		sent     int
+		received int
+	}{
+		{"echo.Echo/Bidi", `[{"text":"a"},{"text":"b"}]`, run.GRPCBidiStream, 6, 6},
+		{"echo.Echo.ClientStream", `[{"text":"a"},{"text":"b"}]`, run.GRPCClientStream, 6, 3},
+		{"echo.Echo/ServerStream", `{"text":"a"}`, run.GRPCServerStream, 3, 9},
+	}
+
+	for _, c := range cases {
+		repo := &repository.MockRepository{}
+		repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+		uc := run.NewRunUseCase(repo)
+		input := run.RunInputDTO{
+			Url:         "grpc://" + addr,
+			Requests:    3,
+			Concurrency: 1,
+			GRPC:        run.GRPCDTO{Method: c.method, Data: c.data},
+		}
+
+		// Act
+		output, err := uc.Run(context.Background(), input)
+
+		// Assert
+		assert.NoError(t, err, c.method)
+		assert.Equal(t, c.callType, output.GRPC.CallType, c.method)
+		assert.Equal(t, c.sent, output.GRPC.MessagesSent, c.method)
+		assert.Equal(t, c.received, output.GRPC.MessagesReceived, c.method)
+	}
+}
+
+func Test_MustReportGRPCStatusCodes(t *testing.T) {
+	// Arrange
+	addr, _ := newGRPCEchoServer(t)
+	cases := map[string]run.RunInputDTO{
+		"UNAVAILABLE":       {GRPC: run.GRPCDTO{Method: "echo.Echo/Fail"}},
+		"DEADLINE_EXCEEDED": {GRPC: run.GRPCDTO{Method: "echo.Echo/Slow", TimeoutInMs: 50}},
+		"PERMISSION_DENIED": {GRPC: run.GRPCDTO{Method: "echo.Echo/Unary"}, Headers: map[string]string{"x-denied": "1"}},
+	}
+
+	for expected, input := range cases {
+		repo := &repository.MockRepository{}
+		repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+		uc := run.NewRunUseCase(repo)
+		input.Url = "grpc://" + addr
+		input.Requests = 2
+		input.Concurrency = 1
+
+		// Act
+		output, err := uc.Run(context.Background(), input)
+
+		// Assert
+		assert.NoError(t, err)
+		for _, report := range output.Report {
+			assert.Contains(t, []string{expected, "total"}, report.Status)
+		}
+	}
+}
+
+func Test_GRPCRunMustFailForUnknownMethod(t *testing.T) {
+	// Arrange
+	addr, _ := newGRPCEchoServer(t)
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "grpc://" + addr, Requests: 1, Concurrency: 1, GRPC: run.GRPCDTO{Method: "echo.Echo/Missing"}}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.ErrorContains(t, err, grpcdesc.ErrMethodNotFound)
+}
+
+func Test_GRPCStatusName(t *testing.T) {
+	assert.Equal(t, "OK", run.GRPCStatusName(codes.OK))
+	assert.Equal(t, "DEADLINE_EXCEEDED", run.GRPCStatusName(codes.DeadlineExceeded))
+	assert.Equal(t, "UNAVAILABLE", run.GRPCStatusName(codes.Unavailable))
+	assert.Equal(t, "RESOURCE_EXHAUSTED", run.GRPCStatusName(codes.ResourceExhausted))
+}
+
+// newSSEServer sends events 10ms apart, closing the stream after the given number of events (zero keeps it open)
+func newSSEServer(t *testing.T, events int, lastEventIDs *sync.Map) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get("Last-Event-ID"); id != "" {
+			lastEventIDs.Store(id, true)
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprint(w, "retry: 20\n\n")
+		for i := 1; events == 0 || i <= events; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			fmt.Fprintf(w, "event: tick\nid: %d\ndata: {\"n\":%d}\n\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func Test_MustHoldSSEStreamsOpenForTheDuration(t *testing.T) {
+	// Arrange
+	server := newSSEServer(t, 0, &sync.Map{})
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Concurrency: 3,
+		SSE:         run.SSEDTO{Enabled: true, DurationInMs: 300},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeSSE, output.Mode)
+	assert.Equal(t, 3, output.Concurrency)
+	assert.Equal(t, 3, output.SSE.Streams)
+	assert.Equal(t, 0, output.SSE.Drops)
+	assert.Equal(t, 0, output.SSE.Reconnects)
+	assert.Greater(t, output.SSE.EventsReceived, 3*10)
+	assert.Equal(t, output.SSE.EventsReceived, output.SSE.EventTypes["tick"])
+	assert.GreaterOrEqual(t, output.SSE.EventGap.P50, 8.0)
+	assert.GreaterOrEqual(t, output.SSE.EventGap.P99, output.SSE.EventGap.P50)
+	assert.GreaterOrEqual(t, output.SSE.TimeToFirstEvent.P50, 8.0)
+	for _, report := range output.Report {
+		switch report.Status {
+		case "first event", "stream", "total":
+			assert.Equal(t, 3, report.Count, report.Status)
+		case "event gap":
+			assert.Equal(t, output.SSE.EventsReceived-3, report.Count)
+		default:
+			t.Errorf("unexpected status %s", report.Status)
+		}
+	}
+}
+
+func Test_MustReconnectDroppedSSEStreams(t *testing.T) {
+	// Arrange
+	var lastEventIDs sync.Map
+	server := newSSEServer(t, 3, &lastEventIDs)
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Concurrency: 2,
+		SSE:         run.SSEDTO{Enabled: true, DurationInMs: 400},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Greater(t, output.SSE.Drops, 2)
+	assert.Greater(t, output.SSE.Reconnects, 2)
+	assert.Equal(t, output.SSE.Streams, output.Requests)
+	_, sentLastID := lastEventIDs.Load("3")
+	assert.True(t, sentLastID)
+}
+
+func Test_MustStopReconnectingWhenSSEServerSaysNoContent(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Concurrency: 2,
+		SSE:         run.SSEDTO{Enabled: true, DurationInMs: 5000},
+	}
+
+	// Act
+	start := time.Now()
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 0, output.SSE.Streams)
+	assert.Equal(t, 2, output.SSE.FailedConnections)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"204", "total"}, report.Status)
+	}
+}
+
+func Test_MustReportGraphQLErrorsByOperationAndClass(t *testing.T) {
+	// Arrange
+	var refs sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var envelope struct {
+			Query     string `json:"query"`
+			Variables struct {
+				Id  int    `json:"id"`
+				Ref string `json:"ref"`
+			} `json:"variables"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&envelope))
+		assert.Contains(t, envelope.Query, "query GetUser")
+		refs.Store(envelope.Variables.Ref, true)
+
+		switch {
+		case envelope.Variables.Id == 4:
+			fmt.Fprint(w, `{"data":null,"errors":[{"message":"boom"}]}`)
+		case envelope.Variables.Id%2 == 1:
+			fmt.Fprint(w, `{"data":{"user":null},"errors":[{"message":"not found","extensions":{"code":"NOT_FOUND"}}]}`)
+		default:
+			fmt.Fprint(w, `{"data":{"user":{"id":1}}}`)
+		}
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    6,
+		Concurrency: 2,
+		GraphQL: run.GraphQLDTO{
+			Query:     "# users\nquery GetUser($id: Int!) { user(id: $id) { id } }",
+			Variables: `{"id": {{.Iteration}}, "ref": "{{uuid}}"}`,
+		},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeGraphQL, output.Mode)
+	assert.Equal(t, "GetUser", output.GraphQL.Operation)
+	assert.Equal(t, 6, output.GraphQL.Responses)
+	assert.Equal(t, 4, output.GraphQL.WithErrors)
+	assert.Equal(t, map[string]int{"NOT_FOUND": 3, run.GraphQLError: 1}, output.GraphQL.ErrorClasses)
+	counts := make(map[string]int)
+	for _, report := range output.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Equal(t, map[string]int{"200": 6, "total": 6, "GetUser OK": 2, "GetUser NOT_FOUND": 3, "GetUser GRAPHQL_ERROR": 1}, counts)
+	distinctRefs := 0
+	refs.Range(func(_, _ any) bool { distinctRefs++; return true })
+	assert.Equal(t, 6, distinctRefs)
+}
+
+func Test_MustReportInvalidGraphQLResponses(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html>bad gateway</html>")
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: server.URL, Requests: 2, Concurrency: 1, GraphQL: run.GraphQLDTO{Query: "{ health }"}}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.GraphQLAnonymous, output.GraphQL.Operation)
+	assert.Equal(t, map[string]int{run.GraphQLInvalidResponse: 2}, output.GraphQL.ErrorClasses)
+}
+
+func Test_GraphQLRunMustFailForInvalidVariables(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: "http://localhost", GraphQL: run.GraphQLDTO{Query: "{ health }", Variables: `[{{.VU}}]`}}
+
+	// Act
+	_, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.EqualError(t, err, run.ErrInvalidGraphQLVariables)
+}
+
+func Test_MustSkipGraphQLRequestsWhoseVariablesDontRender(t *testing.T) {
+	// Arrange
+	var ids sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope struct {
+			Variables struct {
+				Id int `json:"id"`
+			} `json:"variables"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&envelope))
+		ids.Store(envelope.Variables.Id, true)
+		fmt.Fprint(w, `{"data":{"health":"ok"}}`)
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    3,
+		Concurrency: 1,
+		GraphQL: run.GraphQLDTO{
+			Query:     "{ health }",
+			Variables: `{{if eq .Iteration 1}}[]{{else}}{"id": {{.Iteration}}}{{end}}`,
+		},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, output.GraphQL.Responses)
+	assert.Equal(t, map[string]int{run.GraphQLTemplateError: 1}, output.GraphQL.ErrorClasses)
+	_, sentBroken := ids.Load(1)
+	assert.False(t, sentBroken)
+	counts := make(map[string]int)
+	for _, report := range output.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Equal(t, 1, counts["anonymous template"])
+	assert.Equal(t, 2, counts["total"])
+}
+
+func Test_MustPaceAndRecordGraphQLWarmupRequestsWhoseVariablesDontRender(t *testing.T) {
+	// Arrange
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"health":"ok"}}`)
+	}))
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    1,
+		Concurrency: 1,
+		PacingInMs:  20,
+		Warmup:      run.WarmupDTO{DurationInMs: 100},
+		GraphQL:     run.GraphQLDTO{Query: "{ health }", Variables: `{{if eq .Iteration 0}}{}{{else}}[]{{end}}`},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	counts := make(map[string]int)
+	for _, report := range output.Warmup.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Positive(t, counts["anonymous template"])
+	assert.LessOrEqual(t, counts["anonymous template"], 10)
+}
+
+func Test_GraphQLOperationName(t *testing.T) {
+	assert.Equal(t, "CreateUser", run.GraphQLOperationName("mutation CreateUser($name: String) { createUser(name: $name) { id } }"))
+	assert.Equal(t, "Feed", run.GraphQLOperationName("fragment F on User { id }\n\nquery Feed { feed { ...F } }"))
+	assert.Equal(t, run.GraphQLAnonymous, run.GraphQLOperationName("{ health }"))
+}
+
+func Test_MustMultiplexH2CStreamsOverFixedConnections(t *testing.T) {
+	// Arrange
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 2, r.ProtoMajor)
+		time.Sleep(5 * time.Millisecond)
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetHTTP1(true)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         server.URL,
+		Requests:    40,
+		Concurrency: 8,
+		ShowData:    true,
+		Transport:   run.TransportDTO{Protocol: run.ProtocolH2C, Connections: 2},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"HTTP/2.0": 40}, output.Protocol.Negotiated)
+	assert.Equal(t, 2, output.Protocol.Connections)
+	assert.Equal(t, 20.0, output.Protocol.AvgStreamsPerConnection)
+	for _, data := range output.Data {
+		assert.Equal(t, "HTTP/2.0", data.Protocol)
+	}
+}
+
+func Test_MustNegotiateTheChosenProtocolOverTLS(t *testing.T) {
+	// Arrange
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	cases := map[string]string{
+		"":                "HTTP/2.0",
+		run.ProtocolH2:    "HTTP/2.0",
+		run.ProtocolHTTP1: "HTTP/1.1",
+	}
+
+	for protocol, expected := range cases {
+		repo := &repository.MockRepository{}
+		repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+		uc := run.NewRunUseCase(repo)
+		input := run.RunInputDTO{
+			Url:         server.URL,
+			Requests:    4,
+			Concurrency: 2,
+			Transport:   run.TransportDTO{Insecure: true, Protocol: protocol},
+		}
+
+		// Act
+		output, err := uc.Run(context.Background(), input)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{expected: 4}, output.Protocol.Negotiated, protocol)
+	}
+}
+
+func Test_RunMustFailForInvalidProtocolOptions(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	cases := map[string]run.RunInputDTO{
+		run.ErrUnknownProtocol:          {Transport: run.TransportDTO{Protocol: "spdy"}},
+		run.ErrInvalidConnections:       {Transport: run.TransportDTO{Connections: -1}},
+		run.ErrConnectionsWithPoolPerVU: {Transport: run.TransportDTO{Connections: 2}, Session: run.SessionDTO{Mode: run.SessionIsolated, PoolPerVU: true}},
+		run.ErrPoolPerVUWithoutIsolated: {Session: run.SessionDTO{Mode: run.SessionShared, PoolPerVU: true}},
+	}
+
+	for expected, input := range cases {
+		input.Url = "http://localhost"
+
+		// Act
+		_, err := uc.Run(context.Background(), input)
+
+		// Assert
+		assert.EqualError(t, err, expected)
+	}
+}
+
+// newHTTP3Server starts an HTTP/3 server on a local UDP port that accepts 0-RTT
+func newHTTP3Server(t *testing.T, handler http.Handler) string {
+	certFile, keyFile := writeClientCertificate(t)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	server := &http3.Server{
+		Handler:    handler,
+		TLSConfig:  http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+		QUICConfig: &quic.Config{Allow0RTT: true},
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Close()
+		conn.Close()
+	})
+	return "https://" + conn.LocalAddr().String()
+}
+
+func Test_MustSendRequestsOverHTTP3(t *testing.T) {
+	// Arrange
+	url := newHTTP3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, 3, r.ProtoMajor)
+	}))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         url,
+		Requests:    10,
+		Concurrency: 2,
+		Transport:   run.TransportDTO{Insecure: true, Protocol: run.ProtocolH3},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"HTTP/3.0": 10}, output.Protocol.Negotiated)
+	assert.Equal(t, 1, output.Protocol.Connections)
+	assert.Equal(t, 10, output.Protocol.MaxStreamsPerConnection)
+	assert.Equal(t, 1, output.QUIC.Connections)
+	assert.Equal(t, 0, output.QUIC.Used0RTT)
+	assert.Greater(t, output.QUIC.HandshakeTime.P50, 0.0)
+	assert.Greater(t, output.QUIC.PacketsSent, uint64(0))
+	assert.Equal(t, 1, output.TLS.Versions["TLS 1.3"])
+}
+
+func Test_MustResumeHTTP3ConnectionsWith0RTT(t *testing.T) {
+	// Arrange
+	var requests atomic.Int32
+	url := newHTTP3Server(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first connection is dropped so the next request dials again with the session ticket
+		if requests.Add(1) == 1 {
+			w.(http3.Hijacker).Connection().CloseWithError(0, "")
+		}
+	}))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         url,
+		Requests:    3,
+		Concurrency: 1,
+		Transport:   run.TransportDTO{Insecure: true, Protocol: run.ProtocolH3},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, output.QUIC.Connections)
+	assert.Equal(t, 1, output.QUIC.Used0RTT)
+	assert.Equal(t, 1, output.TLS.Full)
+	assert.Equal(t, 1, output.TLS.Resumed)
+}
+
+// newTCPServer answers every line it reads with reply, or never answers when reply is empty
+func newTCPServer(t *testing.T, reply string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if reply != "" {
+						conn.Write([]byte(reply))
+					}
+				}
+			}()
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+func Test_MustExchangeTCPMessagesUntilDelimiter(t *testing.T) {
+	// Arrange
+	url := newTCPServer(t, "pong\r\n")
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         url,
+		Requests:    10,
+		Concurrency: 2,
+		Socket:      run.SocketDTO{PayloadHex: "70 69 6e 67 0a", ResponseDelimiterHex: "0x0d 0x0a"},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeTCP, output.Mode)
+	assert.Equal(t, 2, output.Socket.Connections)
+	assert.Equal(t, int64(50), output.Socket.BytesSent)
+	assert.Equal(t, int64(60), output.Socket.BytesReceived)
+	assert.Greater(t, output.Socket.BytesPerSecond, 0.0)
+	counts := make(map[string]int)
+	for _, report := range output.Report {
+		counts[report.Status] = report.Count
+	}
+	assert.Equal(t, map[string]int{"connect": 2, "response": 10, "total": 10}, counts)
+}
+
+func Test_MustReportTCPTimeoutsAndReconnect(t *testing.T) {
+	// Arrange
+	url := newTCPServer(t, "")
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         url,
+		Requests:    3,
+		Concurrency: 1,
+		Socket:      run.SocketDTO{PayloadHex: "0a", ResponseLength: 4, TimeoutInMs: 50},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, output.Socket.Connections)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"connect", "timeout", "total"}, report.Status)
+		assert.Equal(t, 3, report.Count)
+	}
+}
+
+func Test_MustSendUDPDatagramsFromFile(t *testing.T) {
+	// Arrange
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buffer := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP(buffer[:n], addr)
+		}
+	}()
+	payloadFile := filepath.Join(t.TempDir(), "payload.bin")
+	assert.NoError(t, os.WriteFile(payloadFile, []byte{0xca, 0xfe, 0xba, 0xbe}, 0644))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{
+		Url:         "udp://" + conn.LocalAddr().String(),
+		Requests:    6,
+		Concurrency: 3,
+		Socket:      run.SocketDTO{PayloadFile: payloadFile, ResponseLength: 4},
+	}
+
+	// Act
+	output, err := uc.Run(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, run.ModeUDP, output.Mode)
+	assert.Equal(t, int64(24), output.Socket.BytesReceived)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"connect", "response", "total"}, report.Status)
+	}
+}
+
+func Test_SocketRunMustFailForInvalidPayload(t *testing.T) {
+	// Arrange
+	repo := &repository.MockRepository{}
+	uc := run.NewRunUseCase(repo)
+	emptyFile := filepath.Join(t.TempDir(), "empty.bin")
+	assert.NoError(t, os.WriteFile(emptyFile, nil, 0644))
+	cases := []struct {
+		expected string
+		socket   run.SocketDTO
+	}{
+		{run.ErrMissingPayload, run.SocketDTO{}},
+		{run.ErrMissingPayload, run.SocketDTO{PayloadFile: emptyFile}},
+		{run.ErrInvalidHex, run.SocketDTO{PayloadHex: "zz"}},
+		{run.ErrInvalidHex, run.SocketDTO{PayloadHex: "a0x1"}},
+		{run.ErrPayloadConflict, run.SocketDTO{PayloadHex: "0a", PayloadFile: "payload.bin"}},
+	}
+
+	for _, c := range cases {
+		input := run.RunInputDTO{Url: "tcp://127.0.0.1:9", Socket: c.socket}
+
+		// Act
+		_, err := uc.Run(context.Background(), input)
+
+		// Assert
+		assert.EqualError(t, err, c.expected)
+	}
+}
+
+const agentToken = "agent-secret"
+
+func newAgent(t *testing.T) string {
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+	server := httptest.NewServer(run.NewAgent(&uc, agentToken).Handler())
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func postToAgent(t *testing.T, url, token, body string) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func Test_MustSplitRunAcrossAgentsAndMergePercentiles(t *testing.T) {
+	// Arrange
+	// Every other request is slow, so the merged percentiles have to come from both agents
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served.Add(1)%2 == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: target.URL, Requests: 11, Concurrency: 3, ShowData: true}
+	agents := []string{newAgent(t), newAgent(t)}
+
+	// Act
+	output, err := uc.RunDistributed(context.Background(), input, agents, agentToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, output.Degraded)
+	assert.Equal(t, run.ModeHTTP, output.Mode)
+	assert.Equal(t, 11, output.Requests)
+	assert.Len(t, output.Data, 11)
+	assert.Equal(t, []run.AgentReportDTO{
+		{Address: agents[0], Requests: 6, Concurrency: 2, Status: run.AgentOK},
+		{Address: agents[1], Requests: 5, Concurrency: 1, Status: run.AgentOK},
+	}, output.Agents)
+	for _, report := range output.Report {
+		assert.Equal(t, 11, report.Count)
+		assert.NotNil(t, report.Percentiles)
+		assert.GreaterOrEqual(t, report.Percentiles.P99, 20.0)
+		assert.Less(t, report.Percentiles.P50, report.Percentiles.P99)
+	}
+}
+
+func Test_MustMergeSSEPercentilesOfTheAgents(t *testing.T) {
+	// Arrange
+	// The first stream ticks every 5ms and the second every 60ms, so most gaps of the run are short
+	var streams atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gap := 60 * time.Millisecond
+		if streams.Add(1) == 1 {
+			gap = 5 * time.Millisecond
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(gap):
+			}
+			fmt.Fprint(w, "data: tick\n\n")
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: target.URL, Requests: 2, Concurrency: 2, SSE: run.SSEDTO{Enabled: true, DurationInMs: 400}}
+
+	// Act
+	output, err := uc.RunDistributed(context.Background(), input, []string{newAgent(t), newAgent(t)}, agentToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, output.Degraded)
+	assert.Equal(t, 2, output.SSE.Streams)
+	assert.Less(t, output.SSE.EventGap.P50, 30.0)
+	assert.GreaterOrEqual(t, output.SSE.EventGap.P99, 50.0)
+	assert.Greater(t, output.SSE.TimeToFirstEvent.P50, 0.0)
+}
+
+func Test_MustMergeWarmupPercentilesOfTheAgents(t *testing.T) {
+	// Arrange
+	// The first three requests on the first connection are slow, so one agent's median is slow but not the run's
+	var mu sync.Mutex
+	first, slow := "", 0
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if first == "" {
+			first = r.RemoteAddr
+		}
+		delay := r.RemoteAddr == first && slow < 3
+		if delay {
+			slow++
+		}
+		mu.Unlock()
+		if delay {
+			time.Sleep(40 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: target.URL, Requests: 2, Concurrency: 2, Warmup: run.WarmupDTO{Requests: 10}}
+
+	// Act
+	output, err := uc.RunDistributed(context.Background(), input, []string{newAgent(t), newAgent(t)}, agentToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 10, output.Warmup.Requests)
+	for _, row := range output.Warmup.Report {
+		if row.Status == "total" {
+			assert.Equal(t, 10, row.Count)
+			assert.Less(t, row.Percentiles.P50, 20.0)
+			assert.GreaterOrEqual(t, row.Percentiles.P99, 40.0)
+		}
+	}
+}
+
+func Test_MustMarkDistributedRunDegradedWhenAnAgentFails(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: target.URL, Requests: 8, Concurrency: 2}
+	agents := []string{newAgent(t), unreachable}
+
+	// Act
+	output, err := uc.RunDistributed(context.Background(), input, agents, agentToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, output.Degraded)
+	assert.Equal(t, run.AgentOK, output.Agents[0].Status)
+	assert.Equal(t, run.AgentFailed, output.Agents[1].Status)
+	assert.NotEmpty(t, output.Agents[1].Error)
+	for _, report := range output.Report {
+		assert.Equal(t, 4, report.Count)
+	}
+}
+
+func Test_RunDistributedMustFailWhenTheRunCantBeSplit(t *testing.T) {
+	// Arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+	agent := newAgent(t)
+	input := run.RunInputDTO{Url: "http://127.0.0.1:1", Requests: 2, Concurrency: 1}
+
+	// Act
+	_, errTooMany := uc.RunDistributed(context.Background(), input, []string{agent, agent}, agentToken)
+	_, errUnreachable := uc.RunDistributed(context.Background(), input, []string{unreachable}, agentToken)
+	observed := run.WithObserver(context.Background(), run.Observer{Measured: func(run.Measurement) {}})
+	_, errObserved := uc.RunDistributed(observed, input, []string{agent}, agentToken)
+	input.Url = "ftp://127.0.0.1"
+	_, errInvalid := uc.RunDistributed(context.Background(), input, []string{agent}, agentToken)
+
+	// Assert
+	assert.EqualError(t, errTooMany, run.ErrTooManyAgents)
+	assert.EqualError(t, errUnreachable, run.ErrNoAgentPrepared)
+	assert.EqualError(t, errObserved, run.ErrDistributedObservers)
+	assert.EqualError(t, errInvalid, entity.ErrInvalidURL)
+}
+
+func Test_AgentMustRunOneRunAtATime(t *testing.T) {
+	// Arrange
+	agent := "http://" + newAgent(t)
+	body := `{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1}`
+
+	// Act
+	first := postToAgent(t, agent+"/runs", agentToken, body)
+	second := postToAgent(t, agent+"/runs", agentToken, body)
+	unknown := postToAgent(t, agent+"/runs/unknown/start", agentToken, "")
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, first.StatusCode)
+	assert.Equal(t, http.StatusConflict, second.StatusCode)
+	assert.Equal(t, http.StatusNotFound, unknown.StatusCode)
+}
+
+func Test_AgentMustRefuseRunsWithoutTheTokenOrNamingLocalFiles(t *testing.T) {
+	// Arrange
+	agent := "http://" + newAgent(t)
+	bodies := []string{
+		`{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1, "auth": {"type": "bearer", "token_file": "/etc/passwd"}}`,
+		`{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1, "auth": {"type": "bearer", "token_env": "HOME"}}`,
+		`{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1, "transport": {"ca_file": "/etc/ssl/certs/ca.pem"}}`,
+		`{"url": "tcp://127.0.0.1:1", "requests": 1, "concurrency": 1, "socket": {"payload_file": "/etc/passwd"}}`,
+		`{"url": "grpc://127.0.0.1:1", "requests": 1, "concurrency": 1, "grpc": {"method": "a.B/C", "proto_files": ["/etc/passwd"]}}`,
+	}
+	valid := `{"url": "http://127.0.0.1:1", "requests": 1, "concurrency": 1}`
+
+	// Act
+	withoutToken := postToAgent(t, agent+"/runs", "", valid)
+	wrongToken := postToAgent(t, agent+"/runs", "wrong", valid)
+	var localFiles []*http.Response
+	for _, body := range bodies {
+		localFiles = append(localFiles, postToAgent(t, agent+"/runs", agentToken, body))
+	}
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, withoutToken.StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, wrongToken.StatusCode)
+	for i, resp := range localFiles {
+		var message struct {
+			Error string `json:"error"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&message))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, bodies[i])
+		assert.Equal(t, run.ErrLocalInput, message.Error, bodies[i])
+	}
+}
+
+func Test_MustSendAgentsTheContentsOfLocalFiles(t *testing.T) {
+	// Arrange
+	var authorization atomic.Value
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+	}))
+	defer target.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0600))
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil).Once()
+
+	uc := run.NewRunUseCase(repo)
+	input := run.RunInputDTO{Url: target.URL, Requests: 2, Concurrency: 1, Auth: run.AuthDTO{Type: "bearer", TokenFile: tokenFile}}
+
+	// Act
+	output, err := uc.RunDistributed(context.Background(), input, []string{newAgent(t)}, agentToken)
+	resolved, errResolve := run.ResolveLocalInput(context.Background(), input)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, output.Degraded)
+	assert.Equal(t, "Bearer from-file", authorization.Load())
+	assert.NoError(t, errResolve)
+	assert.NoError(t, run.CheckRemoteInput(resolved))
+	assert.Equal(t, "from-file", resolved.Auth.Token)
+}
+
+func Test_MustTellObserversAboutEveryMeasurement(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("fail") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+	var mu sync.Mutex
+	observed := make(map[string]int)
+	var bytes int64
+	var inFlight, maxInFlight int
+	classes := make(map[string]int)
+	ctx := run.WithObserver(context.Background(), run.Observer{
+		Measured: func(m run.Measurement) {
+			mu.Lock()
+			defer mu.Unlock()
+			observed[m.Status]++
+			bytes += m.BytesReceived
+			classes[m.ErrorClass]++
+			assert.True(t, m.Request)
+			assert.Contains(t, m.Phases, run.PhaseWait)
+		},
+		InFlight: func(delta int) {
+			mu.Lock()
+			defer mu.Unlock()
+			inFlight += delta
+			maxInFlight = max(maxInFlight, inFlight)
+		},
+	})
+
+	// Act
+	_, err := uc.Run(ctx, run.RunInputDTO{Url: target.URL, Requests: 5, Concurrency: 2})
+	assert.NoError(t, err)
+	_, err = uc.Run(ctx, run.RunInputDTO{Url: target.URL + "?fail", Requests: 2, Concurrency: 1})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, map[string]int{"200": 5, "503": 2}, observed)
+	assert.Equal(t, map[string]int{"": 5, run.ErrorClassHTTP5xx: 2}, classes)
+	assert.Equal(t, int64(35), bytes)
+	assert.Equal(t, 0, inFlight)
+	assert.LessOrEqual(t, maxInFlight, 2)
+}
+
+func Test_MustClassifyTransportErrors(t *testing.T) {
+	// Arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	refused := "http://" + listener.Addr().String()
+	listener.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+	var classes sync.Map
+	ctx := run.WithObserver(context.Background(), run.Observer{
+		Measured: func(m run.Measurement) { classes.Store(m.ErrorClass, true) },
+	})
+
+	// Act
+	_, err = uc.Run(ctx, run.RunInputDTO{Url: refused, Requests: 1, Concurrency: 1})
+
+	// Assert
+	assert.NoError(t, err)
+	_, ok := classes.Load(run.ErrorClassRefused)
+	assert.True(t, ok)
+}
+
+func Test_MustInjectTraceparentAndListSlowestAndFailedTraces(t *testing.T) {
+	// Arrange
+	var mu sync.Mutex
+	traceparents := make(map[string]string) // trace id to the flags sent
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.Header.Get(run.TraceparentHeader), "-")
+		if assert.Len(t, parts, 4) {
+			assert.Equal(t, "00", parts[0])
+			assert.Len(t, parts[1], 32)
+			assert.Len(t, parts[2], 16)
+			mu.Lock()
+			traceparents[parts[1]] = parts[3]
+			mu.Unlock()
+		}
+		if r.URL.Query().Has("fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+	var spans []*run.SpanContext
+	ctx := run.WithObserver(run.WithTracing(context.Background(), 1), run.Observer{
+		Measured: func(m run.Measurement) { spans = append(spans, m.Span) },
+	})
+
+	// Act
+	ok, err := uc.Run(ctx, run.RunInputDTO{Url: target.URL, Requests: 12, Concurrency: 3})
+	assert.NoError(t, err)
+	failed, err := uc.Run(ctx, run.RunInputDTO{Url: target.URL + "?fail", Requests: 2, Concurrency: 1})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Len(t, traceparents, 14)
+	assert.Len(t, spans, 14)
+	for _, span := range spans {
+		assert.True(t, span.Sampled)
+		assert.Equal(t, "01", traceparents[span.TraceID])
+	}
+	if assert.NotNil(t, ok.Traces) {
+		assert.Len(t, ok.Traces.Slowest, 10)
+		assert.Empty(t, ok.Traces.Failed)
+		for i, trace := range ok.Traces.Slowest {
+			assert.Contains(t, traceparents, trace.TraceId)
+			assert.Equal(t, "200", trace.Status)
+			if i > 0 {
+				assert.LessOrEqual(t, trace.DurationInMs, ok.Traces.Slowest[i-1].DurationInMs)
+			}
+		}
+	}
+	if assert.NotNil(t, failed.Traces) && assert.Len(t, failed.Traces.Failed, 2) {
+		assert.Equal(t, "500", failed.Traces.Failed[0].Status)
+		assert.Equal(t, run.ErrorClassHTTP5xx, failed.Traces.Failed[0].Error)
+		assert.Contains(t, traceparents, failed.Traces.Failed[0].TraceId)
+	}
+}
+
+func Test_MustPropagateUnsampledTracesWithoutListingThem(t *testing.T) {
+	// Arrange
+	var mu sync.Mutex
+	var headers []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		headers = append(headers, r.Header.Get(run.TraceparentHeader))
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	untraced, err := uc.Run(context.Background(), run.RunInputDTO{Url: target.URL, Requests: 2, Concurrency: 1})
+	assert.NoError(t, err)
+	unsampled, err := uc.Run(run.WithTracing(context.Background(), 0), run.RunInputDTO{Url: target.URL, Requests: 2, Concurrency: 1})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Nil(t, untraced.Traces)
+	assert.Nil(t, unsampled.Traces)
+	assert.Len(t, headers, 4)
+	assert.Empty(t, headers[0])
+	assert.Empty(t, headers[1])
+	assert.True(t, strings.HasSuffix(headers[2], "-00"))
+	assert.True(t, strings.HasSuffix(headers[3], "-00"))
+}
+
+func Test_MustParseThresholds(t *testing.T) {
+	// Arrange
+	expressions := map[string]run.Threshold{
+		"p95<500":          {Expression: "p95<500", Metric: "p95", Operator: "<", Value: 500},
+		"avg <= 200ms":     {Expression: "avg <= 200ms", Metric: "avg", Operator: "<=", Value: 200},
+		"error_rate<1%":    {Expression: "error_rate<1%", Metric: run.ThresholdErrorRate, Operator: "<", Value: 0.01},
+		"error_rate>=0.05": {Expression: "error_rate>=0.05", Metric: run.ThresholdErrorRate, Operator: ">=", Value: 0.05},
+	}
+
+	for expression, expected := range expressions {
+		// Act
+		threshold, err := run.ParseThreshold(expression)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, threshold)
+	}
+
+	_, err := run.ParseThreshold("p95=500")
+	assert.EqualError(t, err, run.ErrInvalidThreshold)
+	_, err = run.ParseThreshold("p95<fast")
+	assert.EqualError(t, err, run.ErrInvalidThreshold)
+	_, err = run.ParseThreshold("error_rate<1ms")
+	assert.EqualError(t, err, run.ErrInvalidThreshold)
+	_, err = run.ParseThreshold("p42<500")
+	assert.EqualError(t, err, run.ErrUnknownThresholdMetric)
+}
+
+func Test_MustEvaluateThresholdsAgainstTheTotalRow(t *testing.T) {
+	// Arrange
+	report := run.RunOutputDTO{Report: []run.StatusReportDTO{
+		{Status: "total", Count: 10, MinTime: 5, MaxTime: 900, AverageTime: 120, Percentiles: &run.PercentilesDTO{P95: 600}},
+		{Status: "200", Count: 7},
+		{Status: "503", Count: 2},
+		{Status: "0", Count: 1},
+	}}
+	var thresholds []run.Threshold
+	for _, expression := range []string{"p95<500", "avg<=120", "max<1000", "error_rate<1%", "error_rate<=30%"} {
+		threshold, err := run.ParseThreshold(expression)
+		assert.NoError(t, err)
+		thresholds = append(thresholds, threshold)
+	}
+
+	// Act
+	results := run.EvaluateThresholds(report, thresholds)
+	empty := run.EvaluateThresholds(run.RunOutputDTO{}, thresholds[:1])
+
+	// Assert
+	passed := make(map[string]bool)
+	for _, result := range results {
+		assert.True(t, result.Measured)
+		passed[result.Threshold] = result.Passed
+	}
+	assert.Equal(t, map[string]bool{"p95<500": false, "avg<=120": true, "max<1000": true, "error_rate<1%": false, "error_rate<=30%": true}, passed)
+	assert.Equal(t, 600.0, results[0].Actual)
+	assert.InDelta(t, 0.3, results[3].Actual, 1e-9)
+	assert.Equal(t, []run.ThresholdResultDTO{{Threshold: "p95<500", Metric: "p95", Operator: "<", Expected: 500}}, empty)
+}
+
+func Test_MustCountFailuresOfEveryModeInTheErrorRate(t *testing.T) {
+	// Arrange
+	threshold, err := run.ParseThreshold("error_rate<1%")
+	assert.NoError(t, err)
+	reports := map[string][]run.StatusReportDTO{
+		run.ModeWebSocket: {{Status: "message", Count: 6}, {Status: "timeout", Count: 2}, {Status: "error", Count: 2}},
+		run.ModeTCP:       {{Status: "response", Count: 6}, {Status: "timeout", Count: 4}},
+		run.ModeGRPC:      {{Status: "OK", Count: 6}, {Status: "DEADLINE_EXCEEDED", Count: 3}, {Status: "UNAVAILABLE", Count: 1}},
+		run.ModeSSE:       {{Status: "first event", Count: 6}, {Status: "204", Count: 1}, {Status: "content type", Count: 1}, {Status: "no events", Count: 2}},
+	}
+
+	for mode, rows := range reports {
+		report := run.RunOutputDTO{Mode: mode, Report: append(rows, run.StatusReportDTO{Status: "total", Count: 10})}
+
+		// Act
+		results := run.EvaluateThresholds(report, []run.Threshold{threshold})
+
+		// Assert
+		assert.False(t, results[0].Passed, mode)
+		assert.InDelta(t, 0.4, results[0].Actual, 1e-9, mode)
+	}
+}
+
+// capacityServer answers with 503 while more than limit requests are in flight
+func capacityServer(limit int64) *httptest.Server {
+	var inFlight atomic.Int64
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer inFlight.Add(-1)
+		if inFlight.Add(1) > limit {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+}
+
+func Test_MustStepConcurrencyUntilTheSLOIsBreached(t *testing.T) {
+	// Arrange
+	target := capacityServer(4)
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	output, err := uc.FindCapacity(context.Background(), run.CapacityInputDTO{
+		Run:            run.RunInputDTO{Url: target.URL},
+		SLO:            []string{"error_rate<10%", "p95<1000"},
+		Strategy:       run.CapacityStrategyStep,
+		Start:          1,
+		Step:           2,
+		Max:            11,
+		RequestsPerVU:  10,
+		WarmupRequests: 5,
+		CooldownInMs:   1,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, output.MaxConcurrency)
+	assert.True(t, output.Breached)
+	var levels []int
+	for _, step := range output.Steps {
+		levels = append(levels, step.Concurrency)
+		assert.Equal(t, 10*step.Concurrency, step.Requests)
+		assert.Greater(t, step.Throughput, 0.0)
+		assert.Len(t, step.Thresholds, 2)
+	}
+	assert.Equal(t, []int{1, 3, 5}, levels)
+	assert.True(t, output.Steps[1].Passed)
+	assert.Zero(t, output.Steps[1].ErrorRate)
+	assert.False(t, output.Steps[2].Passed)
+	assert.Greater(t, output.Steps[2].ErrorRate, 0.1)
+}
+
+func Test_MustBinarySearchTheHighestConcurrencyWithinTheSLO(t *testing.T) {
+	// Arrange
+	target := capacityServer(4)
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	output, err := uc.FindCapacity(context.Background(), run.CapacityInputDTO{
+		Run:           run.RunInputDTO{Url: target.URL},
+		SLO:           []string{"error_rate<10%"},
+		Strategy:      run.CapacityStrategyBinary,
+		Start:         1,
+		Step:          1,
+		Max:           16,
+		RequestsPerVU: 10,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 4, output.MaxConcurrency)
+	assert.True(t, output.Breached)
+	var levels []int
+	for _, step := range output.Steps {
+		levels = append(levels, step.Concurrency)
+	}
+	assert.Equal(t, []int{1, 16, 8, 4, 6, 5}, levels)
+}
+
+func Test_MustWarmUpTheConnectionsEveryCapacityStepMeasures(t *testing.T) {
+	// Arrange
+	var opened atomic.Int32
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	target.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	target.Start()
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	output, err := uc.FindCapacity(context.Background(), run.CapacityInputDTO{
+		Run:            run.RunInputDTO{Url: target.URL},
+		SLO:            []string{"error_rate<10%"},
+		Strategy:       run.CapacityStrategyStep,
+		Start:          1,
+		Step:           1,
+		Max:            2,
+		RequestsPerVU:  5,
+		WarmupRequests: 5,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, output.Steps, 2)
+	assert.Equal(t, 5, output.Steps[0].Requests)
+	// A connection for the only virtual user of the first step, up to two for the second
+	assert.LessOrEqual(t, opened.Load(), int32(3))
+}
+
+func Test_FindCapacityMustFailForInvalidInput(t *testing.T) {
+	// Arrange
+	uc := run.NewRunUseCase(&repository.MockRepository{})
+	valid := run.CapacityInputDTO{Run: run.RunInputDTO{Url: "http://localhost"}, SLO: []string{"p95<500"}, Start: 1, Step: 1, Max: 10}
+	strategy, noSLO, badSLO, badRange, badUrl := valid, valid, valid, valid, valid
+	strategy.Strategy = "random"
+	noSLO.SLO = nil
+	badSLO.SLO = []string{"p95"}
+	badRange.Start = 20
+	badUrl.Run.Url = "localhost"
+
+	// Act
+	var errs []string
+	for _, input := range []run.CapacityInputDTO{strategy, noSLO, badSLO, badRange} {
+		_, err := uc.FindCapacity(context.Background(), input)
+		errs = append(errs, err.Error())
+	}
+	_, urlErr := uc.FindCapacity(context.Background(), badUrl)
+
+	// Assert
+	assert.Equal(t, []string{run.ErrInvalidCapacityStrategy, run.ErrEmptyCapacitySLO, run.ErrInvalidThreshold, run.ErrInvalidCapacityRange}, errs)
+	assert.Error(t, urlErr)
+}
+
+func Test_MustParseWarmups(t *testing.T) {
+	// Act
+	requests, err := run.ParseWarmup("500")
+	assert.NoError(t, err)
+	duration, err := run.ParseWarmup("15s")
+	assert.NoError(t, err)
+	none, err := run.ParseWarmup("")
+	assert.NoError(t, err)
+	_, negative := run.ParseWarmup("-5")
+	_, invalid := run.ParseWarmup("15 minutes")
+
+	// Assert
+	assert.Equal(t, run.WarmupDTO{Requests: 500}, requests)
+	assert.Equal(t, run.WarmupDTO{DurationInMs: 15000}, duration)
+	assert.Equal(t, run.WarmupDTO{}, none)
+	assert.EqualError(t, negative, run.ErrInvalidWarmup)
+	assert.EqualError(t, invalid, run.ErrInvalidWarmup)
+}
+
+func Test_MustReportWarmupRequestsApartFromTheRun(t *testing.T) {
+	// Arrange
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first requests hit a cold cache
+		if served.Add(1) <= 10 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+	var measured atomic.Int64
+	ctx := run.WithObserver(context.Background(), run.Observer{Measured: func(m run.Measurement) { measured.Add(1) }})
+
+	// Act
+	output, err := uc.Run(ctx, run.RunInputDTO{Url: target.URL, Requests: 40, Concurrency: 2, Warmup: run.WarmupDTO{Requests: 10}})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50), served.Load())
+	assert.Equal(t, int64(40), measured.Load())
+	for _, report := range output.Report {
+		assert.Equal(t, 40, report.Count)
+		assert.Less(t, report.MaxTime, 50)
+	}
+	assert.NotNil(t, output.Warmup)
+	assert.Equal(t, 10, output.Warmup.Requests)
+	for _, report := range output.Warmup.Report {
+		assert.Contains(t, []string{"200", "total"}, report.Status)
+		assert.Equal(t, 10, report.Count)
+		assert.GreaterOrEqual(t, report.MinTime, 50)
+	}
+}
+
+func Test_MustWarmUpForADuration(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	begin := time.Now()
+	output, err := uc.Run(context.Background(), run.RunInputDTO{Url: target.URL, Requests: 10, Concurrency: 2, Warmup: run.WarmupDTO{DurationInMs: 100}})
+
+	// Assert
+	assert.NoError(t, err)
+	for _, report := range output.Report {
+		assert.Equal(t, 10, report.Count)
+	}
+	assert.NotNil(t, output.Warmup)
+	assert.Greater(t, output.Warmup.Requests, 0)
+	assert.GreaterOrEqual(t, output.Warmup.DurationInMs, 100)
+	// The run is timed from the end of the warm-up
+	start, err := time.Parse("2006-01-02 15:04:05.0000000", output.TimestampStart)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, start.Sub(begin), 100*time.Millisecond)
+}
+
+func Test_WarmupMustFailForStreamingRuns(t *testing.T) {
+	// Act
+	err := run.ValidateInput(run.RunInputDTO{Url: "ws://localhost", Requests: 1, Concurrency: 1, Warmup: run.WarmupDTO{Requests: 5}})
+
+	// Assert
+	assert.EqualError(t, err, run.ErrWarmupNotSupported)
+}
+
+func Test_MustCorrectPercentilesForCoordinatedOmission(t *testing.T) {
+	// Arrange
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A single stall, that held back the requests the virtual user would have sent meanwhile
+		if served.Add(1) == 50 {
+			time.Sleep(500 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	corrected, err := uc.Run(context.Background(), run.RunInputDTO{Url: target.URL, Requests: 100, Concurrency: 1, ExpectedIntervalInMs: 5})
+	assert.NoError(t, err)
+	served.Store(0)
+	uncorrected, err := uc.Run(context.Background(), run.RunInputDTO{Url: target.URL, Requests: 100, Concurrency: 1})
+	assert.NoError(t, err)
+
+	// Assert
+	for _, report := range corrected.Report {
+		assert.Equal(t, 100, report.Count)
+		assert.Less(t, report.Percentiles.P95, 100.0)
+		assert.NotNil(t, report.CorrectedPercentiles)
+		assert.Greater(t, report.CorrectedPercentiles.P95, 400.0)
+		assert.Less(t, report.CorrectedPercentiles.P50, report.CorrectedPercentiles.P95)
+	}
+	for _, report := range uncorrected.Report {
+		assert.Nil(t, report.CorrectedPercentiles)
+	}
+}
+
+func Test_MustParseThinkTimes(t *testing.T) {
+	// Act
+	constant, err := run.ParseThinkTime("500ms")
+	assert.NoError(t, err)
+	uniform, err := run.ParseThinkTime("uniform:200ms-800ms")
+	assert.NoError(t, err)
+	normal, err := run.ParseThinkTime("normal:1s,100ms")
+	assert.NoError(t, err)
+	exponential, err := run.ParseThinkTime("exponential:250ms")
+	assert.NoError(t, err)
+	var errs []error
+	for _, invalid := range []string{"uniform:800ms-200ms", "normal:1s", "poisson:1s", "fast", "-1s"} {
+		_, err := run.ParseThinkTime(invalid)
+		errs = append(errs, err)
+	}
+
+	// Assert
+	assert.Equal(t, run.ThinkTimeDTO{Distribution: run.ThinkTimeConstant, MeanInMs: 500}, constant)
+	assert.Equal(t, run.ThinkTimeDTO{Distribution: run.ThinkTimeUniform, MinInMs: 200, MaxInMs: 800}, uniform)
+	assert.Equal(t, run.ThinkTimeDTO{Distribution: run.ThinkTimeNormal, MeanInMs: 1000, StdDevInMs: 100}, normal)
+	assert.Equal(t, run.ThinkTimeDTO{Distribution: run.ThinkTimeExponential, MeanInMs: 250}, exponential)
+	for _, err := range errs {
+		assert.EqualError(t, err, run.ErrInvalidThinkTime)
+	}
+}
+
+func Test_MustPauseForTheThinkTimeBetweenRequests(t *testing.T) {
+	// Arrange
+	var mu sync.Mutex
+	var arrivals []time.Time
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		arrivals = append(arrivals, time.Now())
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	output, err := uc.Run(context.Background(), run.RunInputDTO{
+		Url:         target.URL,
+		Requests:    4,
+		Concurrency: 1,
+		ThinkTime:   run.ThinkTimeDTO{Distribution: run.ThinkTimeUniform, MinInMs: 40, MaxInMs: 60},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, output.Report, 2)
+	assert.Len(t, arrivals, 4)
+	for i := 1; i < len(arrivals); i++ {
+		assert.GreaterOrEqual(t, arrivals[i].Sub(arrivals[i-1]), 40*time.Millisecond)
+	}
+}
+
+func Test_MustStartAnIterationEveryPacingIntervalPerVU(t *testing.T) {
+	// Arrange
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// One request outlasts the pacing, so the next one starts right away instead of bursting
+		if served.Add(1) == 2 {
+			time.Sleep(150 * time.Millisecond)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	start := time.Now()
+	output, err := uc.Run(context.Background(), run.RunInputDTO{Url: target.URL, Requests: 8, Concurrency: 2, PacingInMs: 100})
+	elapsed := time.Since(start)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), served.Load())
+	// Every virtual user starts 4 iterations, at 0, 100, 200 and 300ms, the slow one pushes its schedule back by 50ms
+	assert.GreaterOrEqual(t, elapsed, 300*time.Millisecond)
+	assert.Less(t, elapsed, 600*time.Millisecond)
+	for _, report := range output.Report {
+		// Paced runs correct their percentiles for the pacing
+		assert.NotNil(t, report.CorrectedPercentiles)
+	}
+}
+
+func Test_PacingMustFailForStreamingRuns(t *testing.T) {
+	// Act
+	err := run.ValidateInput(run.RunInputDTO{Url: "grpc://localhost:50051", Requests: 1, Concurrency: 1, PacingInMs: 1000})
+	negative := run.ValidateInput(run.RunInputDTO{Url: "http://localhost", Requests: 1, Concurrency: 1, PacingInMs: -1})
+
+	// Assert
+	assert.EqualError(t, err, run.ErrPacingNotSupported)
+	assert.EqualError(t, negative, run.ErrInvalidPacing)
+}
+
+func Test_MustRetryRateLimitedRequestsAndReportTheirAttempts(t *testing.T) {
+	// Arrange
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch served.Add(1) {
+		case 1, 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 4:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	output, err := uc.Run(context.Background(), run.RunInputDTO{
+		Url:         target.URL,
+		Requests:    4,
+		Concurrency: 1,
+		Retry:       run.RetryDTO{MaxRetries: 3, BaseDelayInMs: 1},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), served.Load())
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"200", "total"}, report.Status)
+		assert.Equal(t, 4, report.Count)
+	}
+	assert.Equal(t, &run.RetryReportDTO{
+		Requests:           4,
+		Retried:            2,
+		Recovered:          2,
+		Attempts:           7,
+		AttemptsPerRequest: 1.75,
+		MaxAttempts:        3,
+		RetryAfterWaits:    2,
+		FirstAttempt:       map[string]int{"200": 2, "429": 1, "503": 1},
+		FinalOutcome:       map[string]int{"200": 4},
+		RetriedOutcomes:    map[string]int{"429 → 200": 1, "503 → 200": 1},
+	}, output.Retries)
+}
+
+func Test_MustCapRetriesAndTheirWaits(t *testing.T) {
+	// Arrange
+	var served atomic.Int64
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.Header().Set("Retry-After", time.Now().Add(5*time.Second).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer target.Close()
+
+	repo := &repository.MockRepository{}
+	repo.On("Save", mock.Anything, mock.Anything).Return(nil)
+	uc := run.NewRunUseCase(repo)
+
+	// Act
+	start := time.Now()
+	output, err := uc.Run(context.Background(), run.RunInputDTO{
+		Url:         target.URL,
+		Requests:    2,
+		Concurrency: 1,
+		Retry:       run.RetryDTO{MaxRetries: 2, MaxDelayInMs: 10},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int64(6), served.Load())
+	assert.Equal(t, 2, output.Retries.Exhausted)
+	assert.Equal(t, 4, output.Retries.RetryAfterWaits)
+	assert.Equal(t, map[string]int{"429 → 429": 2}, output.Retries.RetriedOutcomes)
+	for _, report := range output.Report {
+		assert.Contains(t, []string{"429", "total"}, report.Status)
+		// Requests are timed from their first attempt
+		assert.GreaterOrEqual(t, report.MinTime, 20)
+	}
+}
+
+func Test_RetryMustFailForInvalidPolicies(t *testing.T) {
+	// Act
+	negative := run.ValidateInput(run.RunInputDTO{Url: "http://localhost", Requests: 1, Concurrency: 1, Retry: run.RetryDTO{MaxRetries: -1}})
+	status := run.ValidateInput(run.RunInputDTO{Url: "http://localhost", Requests: 1, Concurrency: 1, Retry: run.RetryDTO{MaxRetries: 1, Statuses: []int{700}}})
+	streaming := run.ValidateInput(run.RunInputDTO{Url: "ws://localhost", Requests: 1, Concurrency: 1, Retry: run.RetryDTO{MaxRetries: 1}})
+
+	// Assert
+	assert.EqualError(t, negative, run.ErrInvalidRetry)
+	assert.EqualError(t, status, run.ErrInvalidRetry)
+	assert.EqualError(t, streaming, run.ErrRetryNotSupported)
+}
@@ -0,0 +1,113 @@
+package run
+
+import (
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"stresstest/internal/auth"
+)
+
+const (
+	SessionShared   = "shared"
+	SessionIsolated = "isolated"
+
+	ErrUnknownSessionMode       = "unknown session mode, must be shared or isolated"
+	ErrInvalidConnections       = "connections must not be negative"
+	ErrConnectionsWithPoolPerVU = "a fixed number of connections can't be combined with a pool per virtual user"
+	ErrPoolPerVUWithoutIsolated = "a pool per virtual user needs isolated sessions"
+)
+
+// validate checks the mode and that a pool per virtual user only comes with isolated sessions
+func (s SessionDTO) validate() error {
+	if s.Mode != "" && s.Mode != SessionShared && s.Mode != SessionIsolated {
+		return errors.New(ErrUnknownSessionMode)
+	}
+	if s.PoolPerVU && s.Mode != SessionIsolated {
+		return errors.New(ErrPoolPerVUWithoutIsolated)
+	}
+	return nil
+}
+
+// sessions hands out the client each virtual user sends its requests with
+// Without a mode no cookies are kept. Shared sessions give every virtual user the same cookie jar,
+// like tabs of a single browser, isolated ones give each virtual user its own jar and, when asked,
+// its own connection pool
+// With a fixed number of connections the virtual users take turns on that many pools, one connection each
+type sessions struct {
+	opts          SessionDTO
+	transport     TransportDTO
+	base          *http.Client
+	authenticator auth.Authenticator
+	shared        *http.Client
+	pools         []*http.Client
+	next          int
+}
+
+func newSessions(opts SessionDTO, transport TransportDTO, base *http.Client, authenticator auth.Authenticator) (*sessions, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if transport.Connections < 0 {
+		return nil, errors.New(ErrInvalidConnections)
+	}
+	if transport.Connections > 0 && opts.PoolPerVU {
+		return nil, errors.New(ErrConnectionsWithPoolPerVU)
+	}
+
+	s := &sessions{opts: opts, transport: transport, base: base, authenticator: authenticator}
+	for i := 0; i < transport.Connections; i++ {
+		pool, err := NewHTTPClient(transport)
+		if err != nil {
+			return nil, err
+		}
+		s.pools = append(s.pools, pool)
+	}
+	if opts.Mode != SessionIsolated {
+		client, err := s.newClient(base)
+		if err != nil {
+			return nil, err
+		}
+		s.shared = client
+	}
+	return s, nil
+}
+
+// client returns the client of a new virtual user
+func (s *sessions) client() (*http.Client, error) {
+	if len(s.pools) > 0 {
+		pool := s.pools[s.next%len(s.pools)]
+		s.next++
+		if s.shared != nil {
+			// Same cookie jar, if any, different connection
+			client := *s.shared
+			client.Transport = withAuth(pool, s.authenticator).Transport
+			return &client, nil
+		}
+		return s.newClient(pool)
+	}
+	if s.shared != nil {
+		return s.shared, nil
+	}
+
+	base := s.base
+	if s.opts.PoolPerVU {
+		var err error
+		if base, err = NewHTTPClient(s.transport); err != nil {
+			return nil, err
+		}
+	}
+	return s.newClient(base)
+}
+
+// newClient copies base with the run's auth and, in a session mode, a fresh cookie jar
+func (s *sessions) newClient(base *http.Client) (*http.Client, error) {
+	client := *base
+	if s.opts.Mode != "" {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		client.Jar = jar
+	}
+	return withAuth(&client, s.authenticator), nil
+}
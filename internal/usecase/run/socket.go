@@ -0,0 +1,254 @@
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"stresstest/internal/entity"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ErrMissingPayload  = "tcp and udp runs need a payload, in hex or from a file"
+	ErrInvalidHex      = "invalid hex"
+	ErrPayloadConflict = "payload must come from hex or from a file, not both"
+
+	// socketDefaultTimeout is how long a connect or a response waits when the run doesn't say
+	socketDefaultTimeout = 10 * time.Second
+	// socketReadSize is how much is read from the socket at a time
+	socketReadSize = 64 * 1024
+)
+
+// runSocket sends the payload over raw TCP or UDP, keeping one connection per virtual user
+// With a response length or delimiter each request waits for the matching response to measure its round trip
+func (u *RunUseCase) runSocket(ctx context.Context, input RunInputDTO, testRun *entity.TestRun) (RunOutputDTO, error) {
+	target, err := url.Parse(testRun.Url)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	payload, err := socketPayload(input.Socket)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	delimiter, err := decodeHex(input.Socket.ResponseDelimiterHex)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	timeout := socketDefaultTimeout
+	if input.Socket.TimeoutInMs > 0 {
+		timeout = time.Duration(input.Socket.TimeoutInMs) * time.Millisecond
+	}
+	s := &socketRun{
+		network:   target.Scheme,
+		address:   target.Host,
+		payload:   payload,
+		length:    input.Socket.ResponseLength,
+		delimiter: delimiter,
+		timeout:   timeout,
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	var wg sync.WaitGroup
+	var sent atomic.Int64
+	results := newCollector(ctx, input.ShowData).expectInterval(input.expectedInterval())
+
+	for vu := 0; vu < testRun.Concurrency; vu++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			var conn *socketConn
+			for ctx.Err() == nil && sent.Add(1) <= int64(testRun.Requests) {
+				if conn == nil {
+					if conn = s.connect(ctx, results); conn == nil {
+						// A failed connect takes the place of the request it would have sent
+						now := time.Now()
+						results.recordEvent("error", now, now, true)
+						continue
+					}
+				}
+				// A connection that failed or timed out may still hold part of an old response, so it's replaced
+				if !s.exchange(conn, results) {
+					conn.Close()
+					conn = nil
+				}
+			}
+			if conn != nil {
+				conn.Close()
+			}
+		}()
+	}
+
+	wg.Wait() // Wait for all requests to finish
+
+	report := s.report()
+	if elapsed := time.Since(testRun.Timestamp).Seconds(); elapsed > 0 {
+		report.BytesPerSecond = float64(report.BytesSent+report.BytesReceived) / elapsed
+	}
+
+	// Return output
+	mode := ModeTCP
+	if s.network == "udp" {
+		mode = ModeUDP
+	}
+	return RunOutputDTO{
+		Id:                    testRun.Id,
+		Mode:                  mode,
+		Url:                   testRun.Url,
+		Requests:              testRun.Requests,
+		Concurrency:           testRun.Concurrency,
+		TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+		TimestampEnd:          FormatTimeToUTCString(time.Now()),
+		TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Socket:                &report,
+	}, nil
+}
+
+// socketPayload returns the bytes every request sends
+func socketPayload(opts SocketDTO) ([]byte, error) {
+	if opts.PayloadHex != "" && opts.PayloadFile != "" {
+		return nil, errors.New(ErrPayloadConflict)
+	}
+	var payload []byte
+	var err error
+	if opts.PayloadFile != "" {
+		payload, err = os.ReadFile(opts.PayloadFile)
+	} else {
+		payload, err = decodeHex(opts.PayloadHex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, errors.New(ErrMissingPayload)
+	}
+	return payload, nil
+}
+
+// decodeHex decodes hex that may be written with spaces and a 0x prefix on each token, like 0x0d 0x0a
+func decodeHex(s string) ([]byte, error) {
+	var digits strings.Builder
+	for _, token := range strings.Fields(s) {
+		digits.WriteString(strings.TrimPrefix(token, "0x"))
+	}
+	decoded, err := hex.DecodeString(digits.String())
+	if err != nil {
+		return nil, errors.New(ErrInvalidHex)
+	}
+	return decoded, nil
+}
+
+// socketRun is the exchange every virtual user of a TCP or UDP run makes
+type socketRun struct {
+	network   string
+	address   string
+	payload   []byte
+	length    int
+	delimiter []byte
+	timeout   time.Duration
+
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	connections   atomic.Int64
+	failed        atomic.Int64
+}
+
+// socketConn is a connection along with what was read past the last response
+type socketConn struct {
+	net.Conn
+	pending []byte
+}
+
+// connect opens a connection and records its connect time, nil when it fails
+func (s *socketRun) connect(ctx context.Context, results *collector) *socketConn {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		s.failed.Add(1)
+		return nil
+	}
+	s.connections.Add(1)
+	results.recordEvent("connect", start, time.Now(), false)
+	return &socketConn{Conn: conn}
+}
+
+// exchange sends the payload and waits for the response, returning whether the connection can be reused
+func (s *socketRun) exchange(conn *socketConn, results *collector) bool {
+	start := time.Now()
+	conn.SetDeadline(start.Add(s.timeout))
+	n, err := conn.Write(s.payload)
+	s.bytesSent.Add(int64(n))
+	if err != nil {
+		results.recordEvent(socketErrorStatus(err), start, time.Now(), true)
+		return false
+	}
+	if s.length <= 0 && len(s.delimiter) == 0 {
+		results.recordEvent("sent", start, time.Now(), true)
+		return true
+	}
+
+	buffer := make([]byte, socketReadSize)
+	for {
+		// A response can arrive along with the error, like data right before the server closes
+		if end := s.responseEnd(conn.pending); end > 0 {
+			conn.pending = conn.pending[end:]
+			results.recordEvent("response", start, time.Now(), true)
+			return err == nil
+		}
+		if err != nil {
+			results.recordEvent(socketErrorStatus(err), start, time.Now(), true)
+			return false
+		}
+		n, err = conn.Read(buffer)
+		s.bytesReceived.Add(int64(n))
+		conn.pending = append(conn.pending, buffer[:n]...)
+	}
+}
+
+// responseEnd returns where the response in data ends, zero when it's still incomplete
+// The response ends at the delimiter or at the length, whichever comes first
+func (s *socketRun) responseEnd(data []byte) int {
+	if i := bytes.Index(data, s.delimiter); len(s.delimiter) > 0 && i >= 0 {
+		if end := i + len(s.delimiter); s.length <= 0 || end <= s.length {
+			return end
+		}
+	}
+	if s.length > 0 && len(data) >= s.length {
+		return s.length
+	}
+	return 0
+}
+
+// socketErrorStatus tells timeouts apart from other failures
+func socketErrorStatus(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	return "error"
+}
+
+func (s *socketRun) report() SocketReportDTO {
+	return SocketReportDTO{
+		Connections:       int(s.connections.Load()),
+		FailedConnections: int(s.failed.Load()),
+		BytesSent:         s.bytesSent.Load(),
+		BytesReceived:     s.bytesReceived.Load(),
+	}
+}
@@ -0,0 +1,224 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"stresstest/internal/auth"
+	"stresstest/internal/entity"
+	"stresstest/internal/sse"
+	"sync"
+	"time"
+)
+
+const (
+	ErrInvalidSSEDuration = "sse duration must be greater than zero"
+
+	// sseDefaultDuration is how long the streams are held open when the run doesn't say
+	sseDefaultDuration = 30 * time.Second
+	// sseDefaultRetry is the reconnection time used until the server sends its own
+	sseDefaultRetry = time.Second
+)
+
+// runSSE holds one stream per virtual user open for the duration of the run, reconnecting when it drops
+// Instead of request latency it measures the time to the first event and the gaps between events
+func (u *RunUseCase) runSSE(ctx context.Context, input RunInputDTO, testRun *entity.TestRun, clients []*http.Client, authenticator auth.Authenticator) (RunOutputDTO, error) {
+	duration := sseDefaultDuration
+	if input.SSE.DurationInMs < 0 {
+		return RunOutputDTO{}, errors.New(ErrInvalidSSEDuration)
+	}
+	if input.SSE.DurationInMs > 0 {
+		duration = time.Duration(input.SSE.DurationInMs) * time.Millisecond
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err := u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	headers := map[string]string{"Accept": "text/event-stream", "Cache-Control": "no-cache"}
+	for name, value := range testRun.Headers {
+		headers[name] = value
+	}
+	request := HTTPRequest{Method: testRun.Method, Url: testRun.Url, Headers: headers, Body: testRun.Body}
+
+	var wg sync.WaitGroup
+	results := newCollector(ctx, input.ShowData)
+	stats := newSSEStats()
+
+	for _, client := range clients {
+		wg.Add(1)
+
+		go func(client *http.Client) {
+			defer wg.Done()
+
+			var lastID string
+			retry := sseDefaultRetry
+			for attempt := 0; runCtx.Err() == nil; attempt++ {
+				if attempt > 0 {
+					stats.add(func(s *sseStats) { s.reconnects++ })
+				}
+				reconnect := sseStream(runCtx, client, request, &lastID, &retry, results, stats)
+				if !reconnect {
+					return
+				}
+
+				// The server's retry time is honoured before reconnecting
+				select {
+				case <-time.After(retry):
+				case <-runCtx.Done():
+				}
+			}
+		}(client)
+	}
+
+	wg.Wait() // Wait for the streams to close
+
+	report := stats.report()
+	if elapsed := time.Since(testRun.Timestamp).Seconds(); elapsed > 0 {
+		report.EventsPerSecond = float64(report.EventsReceived) / elapsed
+	}
+
+	// Return output
+	return RunOutputDTO{
+		Id:                    testRun.Id,
+		Mode:                  ModeSSE,
+		Url:                   testRun.Url,
+		Method:                testRun.Method,
+		Requests:              report.Streams + report.FailedConnections,
+		Concurrency:           testRun.Concurrency,
+		TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+		TimestampEnd:          FormatTimeToUTCString(time.Now()),
+		TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Auth:                  authReport(input.Auth, authenticator),
+		SSE:                   &report,
+	}, nil
+}
+
+// sseStream opens a stream and reads its events until it ends, returning whether it should be reopened
+func sseStream(ctx context.Context, client *http.Client, request HTTPRequest, lastID *string, retry *time.Duration, results *collector, stats *sseStats) bool {
+	start := time.Now()
+	req, err := newRequest(ctx, request)
+	if err != nil {
+		results.recordEvent("error", start, time.Now(), true)
+		return false
+	}
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			stats.add(func(s *sseStats) { s.failed++ })
+			results.recordEvent("error", start, time.Now(), true)
+		}
+		return true
+	}
+	defer resp.Body.Close()
+
+	// 204 is how a server tells its clients to stop reconnecting
+	if resp.StatusCode == http.StatusNoContent {
+		stats.add(func(s *sseStats) { s.failed++ })
+		results.recordEvent(strconv.Itoa(resp.StatusCode), start, time.Now(), true)
+		return false
+	}
+	if resp.StatusCode != http.StatusOK {
+		stats.add(func(s *sseStats) { s.failed++ })
+		results.recordEvent(strconv.Itoa(resp.StatusCode), start, time.Now(), true)
+		return true
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		stats.add(func(s *sseStats) { s.failed++ })
+		results.recordEvent("content type", start, time.Now(), true)
+		return true
+	}
+	stats.add(func(s *sseStats) { s.streams++ })
+
+	reader := sse.NewReader(resp.Body)
+	var previous time.Time
+	for {
+		event, err := reader.Next()
+		now := time.Now()
+		if err != nil {
+			break
+		}
+
+		if previous.IsZero() {
+			results.recordEvent("first event", start, now, true)
+			stats.add(func(s *sseStats) { s.firstEvent.record(now.Sub(start)) })
+		} else {
+			results.recordEvent("event gap", previous, now, false)
+			stats.add(func(s *sseStats) { s.gaps.record(now.Sub(previous)) })
+		}
+		stats.add(func(s *sseStats) {
+			s.events++
+			s.eventTypes[event.Type]++
+		})
+		previous = now
+	}
+
+	*lastID = reader.LastID()
+	if reader.Retry() > 0 {
+		*retry = reader.Retry()
+	}
+	end := time.Now()
+	if previous.IsZero() {
+		results.recordEvent("no events", start, end, true)
+	}
+	results.recordEvent("stream", start, end, false)
+
+	// Streams still open when the run ends are closed by it, any other end is a drop
+	if ctx.Err() == nil {
+		stats.add(func(s *sseStats) { s.drops++ })
+	}
+	return true
+}
+
+// sseStats counts what happened to the streams of an SSE run
+type sseStats struct {
+	mu         sync.Mutex
+	streams    int
+	failed     int
+	events     int
+	reconnects int
+	drops      int
+	eventTypes map[string]int
+	firstEvent *histogram
+	gaps       *histogram
+}
+
+func newSSEStats() *sseStats {
+	return &sseStats{eventTypes: make(map[string]int), firstEvent: newHistogram(), gaps: newHistogram()}
+}
+
+func (s *sseStats) add(update func(s *sseStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s)
+}
+
+func (s *sseStats) report() SSEReportDTO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SSEReportDTO{
+		Streams:           s.streams,
+		FailedConnections: s.failed,
+		EventsReceived:    s.events,
+		Reconnects:        s.reconnects,
+		Drops:             s.drops,
+		EventTypes:        s.eventTypes,
+		TimeToFirstEvent:  s.firstEvent.percentiles(),
+		EventGap:          s.gaps.percentiles(),
+		firstEvent:        copyHistogram(s.firstEvent),
+		gaps:              copyHistogram(s.gaps),
+	}
+}
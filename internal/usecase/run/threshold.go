@@ -0,0 +1,155 @@
+package run
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	ErrInvalidThreshold       = "invalid threshold, use a metric, an operator (<, <=, > or >=) and a value, like p95<500"
+	ErrUnknownThresholdMetric = "unknown threshold metric, must be avg, min, max, p50, p90, p95, p99 or error_rate"
+
+	// ThresholdErrorRate is the fraction of requests that failed, see failedStatus
+	ThresholdErrorRate = "error_rate"
+)
+
+// streamFailures are the statuses the modes without an HTTP response record their failed requests with,
+// gRPC calls fail with any status but OK
+var streamFailures = func() map[string]bool {
+	statuses := map[string]bool{"error": true, "timeout": true, "content type": true, "no events": true}
+	for code := codes.Canceled; code <= codes.Unauthenticated; code++ {
+		statuses[GRPCStatusName(code)] = true
+	}
+	return statuses
+}()
+
+// thresholdOperators are tried in order, so <= isn't taken for <
+var thresholdOperators = []string{"<=", ">=", "<", ">"}
+
+// Threshold is a limit the run must stay within, on the latencies of the total row (in ms) or the error rate
+type Threshold struct {
+	Expression string
+	Metric     string
+	Operator   string
+	Value      float64
+}
+
+// ParseThreshold reads a threshold like p95<500, avg<=200ms or error_rate<1%
+func ParseThreshold(expression string) (Threshold, error) {
+	for _, operator := range thresholdOperators {
+		metric, value, found := strings.Cut(expression, operator)
+		if !found {
+			continue
+		}
+		threshold := Threshold{Expression: expression, Metric: strings.TrimSpace(metric), Operator: operator}
+		switch threshold.Metric {
+		case "avg", "min", "max", "p50", "p90", "p95", "p99", ThresholdErrorRate:
+		default:
+			return Threshold{}, errors.New(ErrUnknownThresholdMetric)
+		}
+
+		value = strings.TrimSpace(value)
+		scale := 1.0
+		if trimmed, ok := strings.CutSuffix(value, "%"); ok && threshold.Metric == ThresholdErrorRate {
+			value, scale = trimmed, 0.01
+		} else if trimmed, ok := strings.CutSuffix(value, "ms"); ok && threshold.Metric != ThresholdErrorRate {
+			value = trimmed
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return Threshold{}, errors.New(ErrInvalidThreshold)
+		}
+		threshold.Value = number * scale
+		return threshold, nil
+	}
+	return Threshold{}, errors.New(ErrInvalidThreshold)
+}
+
+// EvaluateThresholds checks the report against every threshold, a run without requests fails them all
+func EvaluateThresholds(report RunOutputDTO, thresholds []Threshold) []ThresholdResultDTO {
+	total, failed := reportTotals(report)
+	results := make([]ThresholdResultDTO, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		result := ThresholdResultDTO{
+			Threshold: threshold.Expression,
+			Metric:    threshold.Metric,
+			Operator:  threshold.Operator,
+			Expected:  threshold.Value,
+		}
+		if total != nil && total.Count > 0 {
+			result.Actual, result.Measured = thresholdActual(threshold.Metric, *total, failed)
+		}
+		result.Passed = result.Measured && compareThreshold(result.Actual, threshold.Operator, threshold.Value)
+		results = append(results, result)
+	}
+	return results
+}
+
+// reportTotals returns the total row of the report, nil when it has none, and how many requests failed
+func reportTotals(report RunOutputDTO) (*StatusReportDTO, int) {
+	var total *StatusReportDTO
+	failed := 0
+	for i, row := range report.Report {
+		if row.Status == "total" {
+			total = &report.Report[i]
+			continue
+		}
+		if failedStatus(report.Mode, row.Status) {
+			failed += row.Count
+		}
+	}
+	return total, failed
+}
+
+// failedStatus tells if the requests of a report row failed: the ones without response or with a status of 400
+// or more, the streams that didn't open with a 200 and the errors and timeouts of the other modes
+func failedStatus(mode, status string) bool {
+	if code, err := strconv.Atoi(status); err == nil {
+		// Open streams record their events instead of their status
+		return code == 0 || code >= 400 || mode == ModeSSE
+	}
+	return streamFailures[status]
+}
+
+// thresholdActual returns the value of the metric in the total row, false when the row doesn't have it
+func thresholdActual(metric string, total StatusReportDTO, failed int) (float64, bool) {
+	switch metric {
+	case "avg":
+		return total.AverageTime, true
+	case "min":
+		return float64(total.MinTime), true
+	case "max":
+		return float64(total.MaxTime), true
+	case ThresholdErrorRate:
+		return float64(failed) / float64(total.Count), true
+	}
+	if total.Percentiles == nil {
+		return 0, false
+	}
+	switch metric {
+	case "p50":
+		return total.Percentiles.P50, true
+	case "p90":
+		return total.Percentiles.P90, true
+	case "p95":
+		return total.Percentiles.P95, true
+	default:
+		return total.Percentiles.P99, true
+	}
+}
+
+func compareThreshold(actual float64, operator string, expected float64) bool {
+	switch operator {
+	case "<":
+		return actual < expected
+	case "<=":
+		return actual <= expected
+	case ">":
+		return actual > expected
+	default:
+		return actual >= expected
+	}
+}
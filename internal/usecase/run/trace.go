@@ -0,0 +1,100 @@
+package run
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace records what happened on the connection of a single request
+// Dials can finish after the request is done with them, so every access goes through the lock
+type requestTrace struct {
+	mu           sync.Mutex
+	handshake    *tls.ConnectionState
+	handshakeErr error
+	conn         string // identifies the connection the request was sent on, see connID
+
+	// When each phase of the request started and ended, zero for phases it didn't go through
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+}
+
+type requestTraceKey struct{}
+
+// requestTraceFrom returns the trace of the request ctx belongs to, for transports that trace on their own
+func requestTraceFrom(ctx context.Context) *requestTrace {
+	trace, _ := ctx.Value(requestTraceKey{}).(*requestTrace)
+	return trace
+}
+
+func (t *requestTrace) gotConn(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.conn = id
+}
+
+// connID identifies a connection by its addresses, so reports count connections without keeping them reachable
+func connID(local, remote net.Addr) string {
+	return local.String() + "->" + remote.String()
+}
+
+// traceRequest attaches a trace to ctx that records the connection details of the request
+func traceRequest(ctx context.Context, trace *requestTrace) context.Context {
+	ctx = context.WithValue(ctx, requestTraceKey{}, trace)
+	// at sets the time of a phase, keeping the first start and the last end when a dial tries more than one address
+	at := func(phase *time.Time, first bool) {
+		trace.mu.Lock()
+		defer trace.mu.Unlock()
+		if !first || phase.IsZero() {
+			*phase = time.Now()
+		}
+	}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			trace.gotConn(connID(info.Conn.LocalAddr(), info.Conn.RemoteAddr()))
+		},
+		DNSStart:          func(httptrace.DNSStartInfo) { at(&trace.dnsStart, true) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { at(&trace.dnsDone, false) },
+		ConnectStart:      func(string, string) { at(&trace.connectStart, true) },
+		ConnectDone:       func(string, string, error) { at(&trace.connectDone, false) },
+		TLSHandshakeStart: func() { at(&trace.tlsStart, true) },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			at(&trace.tlsDone, false)
+			trace.mu.Lock()
+			defer trace.mu.Unlock()
+			trace.handshake = &state
+			trace.handshakeErr = err
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { at(&trace.wroteRequest, false) },
+		GotFirstResponseByte: func() { at(&trace.firstByte, true) },
+	})
+}
+
+// fill copies what the trace recorded into the result of the request
+func (t *requestTrace) fill(result *RequestResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result.Handshake = t.handshake
+	result.HandshakeErr = t.handshakeErr
+	result.conn = t.conn
+
+	phases := map[string][2]time.Time{
+		PhaseDNS:     {t.dnsStart, t.dnsDone},
+		PhaseConnect: {t.connectStart, t.connectDone},
+		PhaseTLS:     {t.tlsStart, t.tlsDone},
+		PhaseWait:    {t.wroteRequest, t.firstByte},
+	}
+	for phase, times := range phases {
+		if !times[0].IsZero() && !times[1].IsZero() {
+			if result.Phases == nil {
+				result.Phases = make(map[string]time.Duration)
+			}
+			result.Phases[phase] = times[1].Sub(times[0])
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package run
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"sort"
+)
+
+const (
+	// TraceparentHeader carries the W3C trace context of a request to the server
+	TraceparentHeader = "traceparent"
+	// maxTraces is how many of the slowest and of the failed requests the report lists
+	maxTraces = 10
+)
+
+// SpanContext identifies the client span of a request in the W3C trace context
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	// Sampled tells if the span is exported, the server is told so through the trace flags
+	Sampled bool
+}
+
+type tracingKey struct{}
+
+// WithTracing returns a context that makes the HTTP requests of the runs started with it carry a traceparent header,
+// with ratio of them sampled
+func WithTracing(ctx context.Context, ratio float64) context.Context {
+	return context.WithValue(ctx, tracingKey{}, ratio)
+}
+
+func tracingFrom(ctx context.Context) (ratio float64, ok bool) {
+	ratio, ok = ctx.Value(tracingKey{}).(float64)
+	return ratio, ok
+}
+
+// newSpanContext starts the trace of a request, sampled with the given ratio
+func newSpanContext(ratio float64) *SpanContext {
+	var traceID [16]byte
+	var spanID [8]byte
+	binary.BigEndian.PutUint64(traceID[:8], rand.Uint64())
+	binary.BigEndian.PutUint64(traceID[8:], rand.Uint64()|1) // all zeros is an invalid trace id
+	binary.BigEndian.PutUint64(spanID[:], rand.Uint64()|1)
+	return &SpanContext{
+		TraceID: hex.EncodeToString(traceID[:]),
+		SpanID:  hex.EncodeToString(spanID[:]),
+		Sampled: rand.Float64() < ratio,
+	}
+}
+
+// traceparent formats the span context as the value of the traceparent header
+func (s *SpanContext) traceparent() string {
+	flags := "00"
+	if s.Sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceID + "-" + s.SpanID + "-" + flags
+}
+
+// traceRecorder keeps the sampled requests worth looking at in the tracing backend
+type traceRecorder struct {
+	slowest []TraceDTO // sorted from the slowest
+	failed  []TraceDTO // the first ones to fail
+}
+
+// record keeps a sampled request if it's among the slowest or failed
+func (t *traceRecorder) record(result RequestResult, status, class string) {
+	if result.Span == nil || !result.Span.Sampled {
+		return
+	}
+	trace := TraceDTO{
+		TraceId:               result.Span.TraceID,
+		SpanId:                result.Span.SpanID,
+		Status:                status,
+		DurationInMs:          int(result.End.Sub(result.Start).Milliseconds()),
+		Error:                 class,
+		RequestStartTimestamp: FormatTimeToUTCString(result.Start),
+	}
+	if class != "" && len(t.failed) < maxTraces {
+		t.failed = append(t.failed, trace)
+	}
+	t.slowest = addSlowest(t.slowest, trace)
+}
+
+func (t *traceRecorder) report() *TracesReportDTO {
+	if len(t.slowest) == 0 {
+		return nil
+	}
+	return &TracesReportDTO{Slowest: t.slowest, Failed: t.failed}
+}
+
+// addSlowest adds a trace to a list sorted from the slowest, keeping no more than maxTraces
+func addSlowest(slowest []TraceDTO, trace TraceDTO) []TraceDTO {
+	i := sort.Search(len(slowest), func(i int) bool { return slowest[i].DurationInMs < trace.DurationInMs })
+	if i >= maxTraces {
+		return slowest
+	}
+	slowest = append(slowest, TraceDTO{})
+	copy(slowest[i+1:], slowest[i:])
+	slowest[i] = trace
+	return slowest[:min(len(slowest), maxTraces)]
+}
+
+// mergeTraces adds the traces of a sub-report, keeping the slowest and the first failed ones
+func mergeTraces(dst, src *TracesReportDTO) *TracesReportDTO {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		dst = &TracesReportDTO{}
+	}
+	for _, trace := range src.Slowest {
+		dst.Slowest = addSlowest(dst.Slowest, trace)
+	}
+	for _, trace := range src.Failed {
+		if len(dst.Failed) < maxTraces {
+			dst.Failed = append(dst.Failed, trace)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,159 @@
+package run
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const (
+	ErrInvalidTLSVersion  = "invalid tls version, must be 1.0, 1.1, 1.2 or 1.3"
+	ErrUnknownCipherSuite = "unknown tls cipher suite"
+	ErrMissingCertOrKey   = "client certificate and key must be given together"
+	ErrInvalidCABundle    = "ca bundle has no valid certificates"
+	ErrUnknownProtocol    = "unknown protocol, must be http1, h2, h2c or h3"
+)
+
+const (
+	ProtocolHTTP1 = "http1"
+	ProtocolH2    = "h2"
+	ProtocolH2C   = "h2c"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewHTTPClient builds the client used by a run from its transport options
+// The default transport is cloned so runs never share connection pools or TLS settings
+func NewHTTPClient(opts TransportDTO) (*http.Client, error) {
+	tlsConfig, err := NewTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Protocol == ProtocolH3 {
+		return &http.Client{Transport: newQUICTransport(tlsConfig, opts.DisableCompression)}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = opts.DisableCompression
+	transport.TLSClientConfig = tlsConfig
+
+	// Without a protocol, https negotiates HTTP/2 through ALPN and http uses HTTP/1.1
+	if opts.Protocol != "" {
+		transport.Protocols = new(http.Protocols)
+		switch opts.Protocol {
+		case ProtocolHTTP1:
+			transport.Protocols.SetHTTP1(true)
+		case ProtocolH2:
+			transport.Protocols.SetHTTP2(true)
+		case ProtocolH2C:
+			// Prior knowledge, the connection starts as HTTP/2 without an upgrade
+			transport.Protocols.SetUnencryptedHTTP2(true)
+		default:
+			return nil, errors.New(ErrUnknownProtocol)
+		}
+	}
+
+	// Each client of a run with fixed connections holds a single one, HTTP/2 streams queue on it
+	if opts.Connections > 0 {
+		transport.MaxConnsPerHost = 1
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// NewTLSConfig builds the TLS settings of a run
+// A session cache is always set so resumed handshakes can be told apart from full ones
+func NewTLSConfig(opts TransportDTO) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.Insecure,
+		ServerName:         opts.ServerName,
+		ClientSessionCache: tls.NewLRUClientSessionCache(0),
+	}
+
+	// Client certificate for mutual TLS
+	if (opts.CertFile == "" && opts.CertPEM == "") != (opts.KeyFile == "" && opts.KeyPEM == "") {
+		return nil, errors.New(ErrMissingCertOrKey)
+	}
+	cert, key, ca, err := opts.pems()
+	if err != nil {
+		return nil, err
+	}
+	if len(cert) > 0 {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	// Private CA, the system pool is replaced so only the bundle is trusted
+	if len(ca) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New(ErrInvalidCABundle)
+		}
+		config.RootCAs = pool
+	}
+
+	if config.MinVersion, err = parseTLSVersion(opts.MinTLSVersion); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = parseTLSVersion(opts.MaxTLSVersion); err != nil {
+		return nil, err
+	}
+
+	// Go doesn't allow choosing TLS 1.3 suites, so the list only affects older versions
+	for _, name := range opts.CipherSuites {
+		id, ok := cipherSuiteByName(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", ErrUnknownCipherSuite, name)
+		}
+		config.CipherSuites = append(config.CipherSuites, id)
+	}
+
+	return config, nil
+}
+
+// pems returns the client certificate, key and CA bundle, read from their files when the files are given
+func (opts TransportDTO) pems() (cert, key, ca []byte, err error) {
+	read := func(file, content string) []byte {
+		if file == "" || err != nil {
+			return []byte(content)
+		}
+		var data []byte
+		data, err = os.ReadFile(file)
+		return data
+	}
+	cert, key, ca = read(opts.CertFile, opts.CertPEM), read(opts.KeyFile, opts.KeyPEM), read(opts.CAFile, opts.CAPEM)
+	return cert, key, ca, err
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, errors.New(ErrInvalidTLSVersion)
+	}
+	return v, nil
+}
+
+func cipherSuiteByName(name string) (uint16, bool) {
+	for _, suites := range [][]*tls.CipherSuite{tls.CipherSuites(), tls.InsecureCipherSuites()} {
+		for _, suite := range suites {
+			if suite.Name == name {
+				return suite.ID, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ErrInvalidWarmup      = "invalid warm-up, use a number of requests like 500 or a duration like 15s"
+	ErrWarmupNotSupported = "warm-up is only supported for HTTP and GraphQL runs"
+)
+
+// ParseWarmup reads a warm-up given as a number of requests, like 500, or as a duration, like 15s
+func ParseWarmup(value string) (WarmupDTO, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return WarmupDTO{}, nil
+	}
+	if requests, err := strconv.Atoi(value); err == nil {
+		if requests < 0 {
+			return WarmupDTO{}, errors.New(ErrInvalidWarmup)
+		}
+		return WarmupDTO{Requests: requests}, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return WarmupDTO{}, errors.New(ErrInvalidWarmup)
+	}
+	return WarmupDTO{DurationInMs: int(duration.Milliseconds())}, nil
+}
+
+func (w WarmupDTO) enabled() bool {
+	return w.Requests > 0 || w.DurationInMs > 0
+}
+
+// warmup is the first phase of a run, whose requests are sent like the others but kept out of the report.
+// Virtual users claim warm-up requests until there are none left or its duration is over, then move on to the run
+type warmup struct {
+	requests int64
+	deadline time.Time
+	claimed  atomic.Int64
+	start    time.Time
+	over     sync.Once
+	end      time.Time
+	results  *collector
+}
+
+// newWarmup starts the warm-up, nil when the input has none
+func newWarmup(input WarmupDTO) *warmup {
+	if !input.enabled() {
+		return nil
+	}
+	start := time.Now()
+	return &warmup{
+		requests: int64(input.Requests),
+		deadline: start.Add(time.Duration(input.DurationInMs) * time.Millisecond),
+		start:    start,
+		// Observers aren't told about the warm-up, so metrics and samples start with the run too
+		results: newCollector(context.Background(), false),
+	}
+}
+
+// claim tells if the next request of a virtual user belongs to the warm-up
+func (w *warmup) claim() bool {
+	if w == nil {
+		return false
+	}
+	if w.requests > 0 && w.claimed.Add(1) <= w.requests {
+		return true
+	}
+	if w.requests == 0 && time.Now().Before(w.deadline) {
+		return true
+	}
+	w.over.Do(func() { w.end = time.Now() })
+	return false
+}
+
+// measuredFrom returns when the run itself started, at the end of the warm-up or at start when there's none
+func (w *warmup) measuredFrom(start time.Time) time.Time {
+	if w == nil {
+		return start
+	}
+	w.over.Do(func() { w.end = time.Now() })
+	return w.end
+}
+
+// report returns the results of the warm-up, to be called once the virtual users are done
+func (w *warmup) report() *WarmupReportDTO {
+	if w == nil {
+		return nil
+	}
+	w.over.Do(func() { w.end = time.Now() })
+	snapshot := w.results.snapshot()
+	report := &WarmupReportDTO{
+		DurationInMs: int(w.end.Sub(w.start).Milliseconds()),
+		Report:       snapshot.Report,
+		TLS:          w.results.tlsReport(),
+		histograms:   snapshot.Histograms,
+	}
+	for _, row := range report.Report {
+		if row.Status == "total" {
+			report.Requests = row.Count
+		}
+	}
+	return report
+}
@@ -0,0 +1,371 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"stresstest/internal/auth"
+	"stresstest/internal/entity"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsReplyTimeout is how long a message waits for its reply before it counts as a timeout
+	wsReplyTimeout = 10 * time.Second
+	// wsCloseTimeout is how long a connection waits for the server to acknowledge its close
+	wsCloseTimeout = time.Second
+	// wsMaxPending is how many messages of a connection wait for a reply before the oldest counts as a timeout
+	wsMaxPending = 1024
+)
+
+// runWebSocket opens one connection per virtual user and exchanges the scripted messages over them
+// Every message waits for a reply to measure its round trip, without messages each request is a connect and close
+func (u *RunUseCase) runWebSocket(ctx context.Context, input RunInputDTO, testRun *entity.TestRun, authenticator auth.Authenticator) (RunOutputDTO, error) {
+	tlsConfig, err := NewTLSConfig(input.Transport)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+	dialer := &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  45 * time.Second,
+		TLSClientConfig:   tlsConfig,
+		EnableCompression: !input.Transport.DisableCompression,
+	}
+
+	// Kept in memory for the history of serve, which prunes it along with its finished runs
+	err = u.repo.Save(ctx, testRun)
+	if err != nil {
+		return RunOutputDTO{}, err
+	}
+
+	var wg sync.WaitGroup
+	var sent atomic.Int64
+	results := newCollector(ctx, input.ShowData).expectInterval(input.expectedInterval())
+	stats := &wsStats{closeCodes: make(map[string]int)}
+	claim := func() bool { return ctx.Err() == nil && sent.Add(1) <= int64(testRun.Requests) }
+
+	for vu := 0; vu < testRun.Concurrency; vu++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if len(input.WebSocket.Messages) == 0 {
+				for claim() {
+					conn, ok := wsConnect(ctx, dialer, testRun, authenticator, results, stats, true)
+					if ok {
+						wsClose(conn, time.Now(), results, stats)
+					}
+				}
+				return
+			}
+
+			// A connection is kept for as many messages as it can, a new one replaces it after a drop
+			for sent.Load() < int64(testRun.Requests) {
+				conn, ok := wsConnect(ctx, dialer, testRun, authenticator, results, stats, false)
+				if !ok {
+					if !claim() {
+						return
+					}
+					// A failed connect takes the place of the message it would have sent
+					now := time.Now()
+					results.recordEvent("error", now, now, true)
+					continue
+				}
+				wsExchange(ctx, conn, input.WebSocket, claim, results, stats)
+			}
+		}()
+	}
+
+	wg.Wait() // Wait for all connections to finish
+
+	report := stats.report()
+	if elapsed := time.Since(testRun.Timestamp).Seconds(); elapsed > 0 {
+		report.MessagesPerSecond = float64(report.MessagesReceived) / elapsed
+	}
+
+	// Return output
+	return RunOutputDTO{
+		Id:                    testRun.Id,
+		Mode:                  ModeWebSocket,
+		Url:                   testRun.Url,
+		Requests:              testRun.Requests,
+		Concurrency:           testRun.Concurrency,
+		TimestampStart:        FormatTimeToUTCString(testRun.Timestamp),
+		TimestampEnd:          FormatTimeToUTCString(time.Now()),
+		TestDurationInSeconds: int(time.Since(testRun.Timestamp).Seconds()),
+		Data:                  results.data,
+		Report:                results.finalReport(),
+		Auth:                  authReport(input.Auth, authenticator),
+		WebSocket:             &report,
+	}, nil
+}
+
+// wsConnect opens a connection and records its connect time
+// When the connect is the request itself, failures are recorded as errors of the run
+func wsConnect(ctx context.Context, dialer *websocket.Dialer, testRun *entity.TestRun, authenticator auth.Authenticator, results *collector, stats *wsStats, request bool) (*websocket.Conn, bool) {
+	header := http.Header{}
+	for name, value := range testRun.Headers {
+		header.Set(name, value)
+	}
+	if authenticator != nil {
+		header.Set("Authorization", authenticator.Authorization())
+	}
+
+	start := time.Now()
+	conn, _, err := dialer.DialContext(ctx, testRun.Url, header)
+	end := time.Now()
+	if err != nil {
+		stats.add(func(s *wsStats) { s.failed++ })
+		if request {
+			results.recordEvent("error", start, end, true)
+		}
+		return nil, false
+	}
+
+	stats.add(func(s *wsStats) { s.connections++ })
+	results.recordEvent("connect", start, end, request)
+	return conn, true
+}
+
+// wsExchange sends messages over the connection until the run has sent them all or the connection drops
+func wsExchange(ctx context.Context, conn *websocket.Conn, opts WebSocketDTO, claim func() bool, results *collector, stats *wsStats) {
+	opened := time.Now()
+	pending := &wsPending{}
+	replies := make(chan struct{}, wsMaxPending)
+	closed := make(chan int, 1)
+
+	// Replies are matched to the oldest message still waiting for one
+	go func() {
+		for {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				code := websocket.CloseAbnormalClosure
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					code = closeErr.Code
+				}
+				closed <- code
+				return
+			}
+			stats.add(func(s *wsStats) { s.received++ })
+			// Messages pushed by the server have nothing to be matched with
+			if sentAt, ok := pending.pop(); ok {
+				results.recordEvent("message", sentAt, time.Now(), true)
+				select {
+				case replies <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	var ticker *time.Ticker
+	if opts.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+	}
+
+	dropped := false
+	for i := 0; !dropped && claim(); i++ {
+		// At a rate the next message waits for the ticker, unless the run or the connection ends first
+		if ticker != nil && i > 0 {
+			select {
+			case <-ticker.C:
+			case code := <-closed:
+				closed <- code
+				dropped = true
+			case <-ctx.Done():
+			}
+			if dropped {
+				// The connection drop takes the place of the message it would have sent
+				now := time.Now()
+				results.recordEvent("error", now, now, true)
+				break
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		// Without replies the oldest message gives up its place once too many are waiting
+		if pending.len() == wsMaxPending {
+			sentAt, _ := pending.pop()
+			results.recordEvent("timeout", sentAt, time.Now(), true)
+		}
+		pending.push(i, time.Now())
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(opts.Messages[i%len(opts.Messages)])); err != nil {
+			// The message that failed leaves the queue, the ones before it still wait for their replies
+			if sentAt, ok := pending.remove(i); ok {
+				results.recordEvent("error", sentAt, time.Now(), true)
+			}
+			dropped = true
+			break
+		}
+		stats.add(func(s *wsStats) { s.sent++ })
+
+		// Without a rate each message waits for its reply before the next one is sent
+		if ticker == nil {
+			select {
+			case <-replies:
+			case code := <-closed:
+				closed <- code
+				dropped = true
+			case <-time.After(wsReplyTimeout):
+			}
+		}
+	}
+
+	// Messages still waiting get a last chance to be answered before the connection is closed
+	deadline := time.After(wsReplyTimeout)
+	for !dropped && pending.len() > 0 {
+		select {
+		case <-replies:
+		case code := <-closed:
+			closed <- code
+			dropped = true
+		case <-deadline:
+			dropped = true
+		}
+	}
+	for {
+		sentAt, ok := pending.pop()
+		if !ok {
+			break
+		}
+		results.recordEvent("timeout", sentAt, time.Now(), true)
+	}
+
+	select {
+	case code := <-closed:
+		// The server closed the connection before the virtual user was done with it
+		stats.add(func(s *wsStats) { s.disconnects++ })
+		wsRecordClose(code, opened, results, stats)
+		conn.Close()
+	default:
+		wsCloseWith(conn, closed, opened, results, stats)
+	}
+}
+
+// wsClose closes a connection that has no reader running
+func wsClose(conn *websocket.Conn, opened time.Time, results *collector, stats *wsStats) {
+	closed := make(chan int, 1)
+	go func() {
+		code := websocket.CloseAbnormalClosure
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					code = closeErr.Code
+				}
+				closed <- code
+				return
+			}
+		}
+	}()
+	wsCloseWith(conn, closed, opened, results, stats)
+}
+
+// wsCloseWith sends a normal close and waits for the server to acknowledge it
+func wsCloseWith(conn *websocket.Conn, closed chan int, opened time.Time, results *collector, stats *wsStats) {
+	message := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsCloseTimeout))
+
+	code := websocket.CloseAbnormalClosure
+	select {
+	case code = <-closed:
+	case <-time.After(wsCloseTimeout):
+	}
+	conn.Close()
+	wsRecordClose(code, opened, results, stats)
+}
+
+func wsRecordClose(code int, opened time.Time, results *collector, stats *wsStats) {
+	status := "close " + strconv.Itoa(code)
+	stats.add(func(s *wsStats) { s.closeCodes[status]++ })
+	results.recordEvent(status, opened, time.Now(), false)
+}
+
+// wsStats counts what happened to the connections of a WebSocket run
+// wsPending holds the messages of a connection waiting for a reply, oldest first
+type wsPending struct {
+	mu       sync.Mutex
+	messages []wsPendingMessage
+}
+
+type wsPendingMessage struct {
+	id     int
+	sentAt time.Time
+}
+
+func (p *wsPending) push(id int, sentAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, wsPendingMessage{id: id, sentAt: sentAt})
+}
+
+// pop takes the oldest message out, replies are matched to it
+func (p *wsPending) pop() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.messages) == 0 {
+		return time.Time{}, false
+	}
+	oldest := p.messages[0]
+	p.messages = p.messages[1:]
+	return oldest.sentAt, true
+}
+
+// remove takes the message out wherever it is, false when a reply already took it
+func (p *wsPending) remove(id int) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, message := range p.messages {
+		if message.id == id {
+			p.messages = slices.Delete(p.messages, i, i+1)
+			return message.sentAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (p *wsPending) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.messages)
+}
+
+type wsStats struct {
+	mu          sync.Mutex
+	connections int
+	failed      int
+	sent        int
+	received    int
+	disconnects int
+	closeCodes  map[string]int
+}
+
+func (s *wsStats) add(update func(s *wsStats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(s)
+}
+
+func (s *wsStats) report() WebSocketReportDTO {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return WebSocketReportDTO{
+		Connections:       s.connections,
+		FailedConnections: s.failed,
+		MessagesSent:      s.sent,
+		MessagesReceived:  s.received,
+		Disconnects:       s.disconnects,
+		CloseCodes:        s.closeCodes,
+	}
+}
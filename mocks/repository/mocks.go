@@ -17,3 +17,20 @@ func (m *MockRepository) Save(ctx context.Context, run *entity.TestRun) error {
 	args := m.Called(ctx, run)
 	return args.Error(0)
 }
+
+func (m *MockRepository) FindAll(ctx context.Context) ([]*entity.TestRun, error) {
+	args := m.Called(ctx)
+	runs, _ := args.Get(0).([]*entity.TestRun)
+	return runs, args.Error(1)
+}
+
+func (m *MockRepository) FindByID(ctx context.Context, id string) (*entity.TestRun, error) {
+	args := m.Called(ctx, id)
+	testRun, _ := args.Get(0).(*entity.TestRun)
+	return testRun, args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}